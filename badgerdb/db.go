@@ -5,34 +5,94 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strconv"
+	"time"
 
 	"github.com/dgraph-io/badger/v3"
+	"github.com/dgraph-io/ristretto"
 	"github.com/meission/locketdb"
 )
 
 type badgerDB struct {
-	db *badger.DB
+	db       *badger.DB
+	readOnly bool
+	merge    locketdb.MergeOperator
 }
 
-var _ locketdb.DB = (*badgerDB)(nil)
+var (
+	_ locketdb.DB                = (*badgerDB)(nil)
+	_ locketdb.Snapshotter       = (*badgerDB)(nil)
+	_ locketdb.Transactional     = (*badgerDB)(nil)
+	_ locketdb.RangeDeleter      = (*badgerDB)(nil)
+	_ locketdb.Compacter         = (*badgerDB)(nil)
+	_ locketdb.KVTyper           = (*badgerDB)(nil)
+	_ locketdb.MetricsReporter   = (*badgerDB)(nil)
+	_ locketdb.TTLSetter         = (*badgerDB)(nil)
+	_ locketdb.ConditionalWriter = (*badgerDB)(nil)
+	_ locketdb.Merger            = (*badgerDB)(nil)
+)
 
 func init() {
 	locketdb.RegisterEngine(locketdb.BadgerDB, NewDB)
+	locketdb.RegisterDSNEngine(locketdb.BadgerDB, OpenDSN)
+	locketdb.RegisterOptionsEngine(locketdb.BadgerDB, OpenWithOptions)
 }
 
 // NewDB creates a Badger key-value store backed to the
 // directory dir supplied. If dir does not exist, it will be created.
 func NewDB(dbName, dir string) (locketdb.DB, error) {
+	db, err := openDir(defaultOptions(dbName, dir))
+	if err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// defaultOptions returns the options used by NewDB.
+func defaultOptions(dbName, dir string) badger.Options {
 	// Since Badger doesn't support database names, we join both to obtain
 	// the final directory to use for the database.
-	path := filepath.Join(dir, dbName)
+	opts := badger.DefaultOptions(filepath.Join(dir, dbName))
+	opts.SyncWrites = false // note that we have Sync methods
+	opts.Logger = nil       // badger is too chatty by default
+	return opts
+}
 
-	if err := os.MkdirAll(path, 0755); err != nil {
+// OpenWithOptions opens a database with the common options, see
+// locketdb.NewDBWithOptions. FileMode is not supported, badger always
+// creates its files with mode 0666 less the umask.
+func OpenWithOptions(dbName, dir string, opts locketdb.Options) (locketdb.DB, error) {
+	if opts.FileMode != 0 {
+		return nil, locketdb.OptionNotSupported(locketdb.BadgerDB, "FileMode")
+	}
+	o := defaultOptions(dbName, dir)
+	o.ReadOnly = opts.ReadOnly
+	o.SyncWrites = opts.Sync
+	if opts.CacheSize > 0 {
+		o.BlockCacheSize = opts.CacheSize
+	}
+	if opts.ErrorIfMissing {
+		if _, err := os.Stat(filepath.Join(o.Dir, badger.ManifestFilename)); err != nil {
+			return nil, err
+		}
+	}
+	db, err := openDir(o)
+	if err != nil {
 		return nil, err
 	}
-	opts := badger.DefaultOptions(path)
-	opts.SyncWrites = false // note that we have Sync methods
-	opts.Logger = nil       // badger is too chatty by default
+	db.merge = opts.MergeOperator
+	return db, nil
+}
+
+// openDir creates the database directory if it does not exist and opens it.
+// A read-only database must exist already.
+func openDir(opts badger.Options) (*badgerDB, error) {
+	if !opts.ReadOnly {
+		if err := os.MkdirAll(opts.Dir, 0755); err != nil {
+			return nil, err
+		}
+	}
 	return NewDBWithOptions(opts)
 }
 
@@ -44,7 +104,7 @@ func NewDBWithOptions(opts badger.Options) (*badgerDB, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &badgerDB{db: db}, nil
+	return &badgerDB{db: db, readOnly: opts.ReadOnly}, nil
 }
 
 func (b *badgerDB) Get(key []byte) ([]byte, error) {
@@ -91,11 +151,131 @@ func (b *badgerDB) Set(key, value []byte) error {
 	if value == nil {
 		return locketdb.ErrValueNil
 	}
+	if b.readOnly {
+		return locketdb.ErrReadOnly
+	}
 	return b.db.Update(func(txn *badger.Txn) error {
 		return txn.Set(key, value)
 	})
 }
 
+// SetWithTTL uses badger's native expiry, which truncates the expiry time to
+// the second: the key may expire up to one second before ttl elapses.
+func (b *badgerDB) SetWithTTL(key, value []byte, ttl time.Duration) error {
+	if len(key) == 0 {
+		return locketdb.ErrKeyEmpty
+	}
+	if value == nil {
+		return locketdb.ErrValueNil
+	}
+	if ttl <= 0 {
+		return locketdb.ErrInvalidTTL
+	}
+	if b.readOnly {
+		return locketdb.ErrReadOnly
+	}
+	return b.db.Update(func(txn *badger.Txn) error {
+		return txn.SetEntry(badger.NewEntry(key, value).WithTTL(ttl))
+	})
+}
+
+// CompareAndSwap reads and writes key in a transaction, which is retried when
+// badger detects a conflicting write to the key before it commits.
+func (b *badgerDB) CompareAndSwap(key, expected, new []byte) (bool, error) {
+	if len(key) == 0 {
+		return false, locketdb.ErrKeyEmpty
+	}
+	if b.readOnly {
+		return false, locketdb.ErrReadOnly
+	}
+	for {
+		err := b.db.Update(func(txn *badger.Txn) error {
+			var current []byte
+			item, err := txn.Get(key)
+			if err != nil && err != badger.ErrKeyNotFound {
+				return err
+			}
+			if err == nil {
+				if current, err = item.ValueCopy(nil); err != nil {
+					return err
+				}
+				if current == nil {
+					current = []byte{}
+				}
+			}
+			if err := locketdb.CheckExpected(key, current, expected); err != nil {
+				return err
+			}
+			if new == nil {
+				return txn.Delete(key)
+			}
+			return txn.Set(key, new)
+		})
+		if err != badger.ErrConflict {
+			return err == nil, err
+		}
+	}
+}
+
+// Merge reads, merges and writes key in a transaction, retried on conflicts
+// like CompareAndSwap. badger.MergeOperator is not used: it merges a single key
+// in the background and only exposes the result through its own Get.
+func (b *badgerDB) Merge(key, operand []byte) error {
+	if len(key) == 0 {
+		return locketdb.ErrKeyEmpty
+	}
+	if operand == nil {
+		return locketdb.ErrValueNil
+	}
+	if b.merge == nil {
+		return locketdb.ErrNotSupported
+	}
+	if b.readOnly {
+		return locketdb.ErrReadOnly
+	}
+	for {
+		err := b.db.Update(func(txn *badger.Txn) error {
+			current, err := getValue(txn, key)
+			if err != nil {
+				return err
+			}
+			value, err := locketdb.MergeValue(b.merge, current, operand)
+			if err != nil {
+				return err
+			}
+			return txn.Set(key, value)
+		})
+		if err != badger.ErrConflict {
+			return err
+		}
+	}
+}
+
+// getValue returns a copy of the value of key in txn, nil if it does not exist.
+func getValue(txn *badger.Txn, key []byte) ([]byte, error) {
+	item, err := txn.Get(key)
+	if err == badger.ErrKeyNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	value, err := item.ValueCopy(nil)
+	if err == nil && value == nil {
+		value = []byte{}
+	}
+	return value, err
+}
+
+func (b *badgerDB) SetIfAbsent(key, value []byte) (bool, error) {
+	if len(key) == 0 {
+		return false, locketdb.ErrKeyEmpty
+	}
+	if value == nil {
+		return false, locketdb.ErrValueNil
+	}
+	return b.CompareAndSwap(key, nil, value)
+}
+
 func withSync(db *badger.DB, err error) error {
 	if err != nil {
 		return err
@@ -111,6 +291,9 @@ func (b *badgerDB) Delete(key []byte) error {
 	if len(key) == 0 {
 		return locketdb.ErrKeyEmpty
 	}
+	if b.readOnly {
+		return locketdb.ErrReadOnly
+	}
 	return b.db.Update(func(txn *badger.Txn) error {
 		return txn.Delete(key)
 	})
@@ -120,12 +303,108 @@ func (b *badgerDB) DeleteSync(key []byte) error {
 	return withSync(b.db, b.Delete(key))
 }
 
+// DeleteRange uses DropAll or DropPrefix when the range covers the whole
+// database or exactly one prefix. Other ranges are deleted key by key in a
+// single transaction, which fails with locketdb.ErrRangeTooLarge if the range
+// holds too many keys to be deleted atomically.
+func (b *badgerDB) DeleteRange(start, end []byte) error {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return locketdb.ErrKeyEmpty
+	}
+	if b.readOnly {
+		return locketdb.ErrReadOnly
+	}
+	switch {
+	case locketdb.IsEmptyRange(start, end):
+		return nil
+	case start == nil && end == nil:
+		return b.db.DropAll()
+	case locketdb.IsPrefixRange(start, end):
+		return b.db.DropPrefix(start)
+	}
+	err := b.db.Update(func(txn *badger.Txn) error {
+		for _, key := range rangeKeys(txn, start, end) {
+			if err := txn.Delete(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err == badger.ErrTxnTooBig {
+		return fmt.Errorf("%w: %v", locketdb.ErrRangeTooLarge, err)
+	}
+	return err
+}
+
+// rangeKeys returns copies of the keys in [start, end) visible to txn.
+func rangeKeys(txn *badger.Txn, start, end []byte) [][]byte {
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	iter := txn.NewIterator(opts)
+	defer iter.Close()
+
+	var keys [][]byte
+	for iter.Seek(start); iter.Valid(); iter.Next() {
+		key := iter.Item().KeyCopy(nil)
+		if end != nil && bytes.Compare(key, end) >= 0 {
+			break
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// valueLogGCDiscardRatio is the fraction of a value log file that must be
+// garbage for Compact to rewrite it.
+const valueLogGCDiscardRatio = 0.5
+
+// Compact flattens the LSM tree into a single level and then garbage collects
+// the value log until no file is worth rewriting. Badger cannot compact a
+// partial range, so start and end are only validated.
+func (b *badgerDB) Compact(start, end []byte) error {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return locketdb.ErrKeyEmpty
+	}
+	if b.readOnly {
+		return locketdb.ErrReadOnly
+	}
+	if err := b.db.Flatten(runtime.NumCPU()); err != nil {
+		return err
+	}
+	for {
+		err := b.db.RunValueLogGC(valueLogGCDiscardRatio)
+		if err == badger.ErrNoRewrite {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (b *badgerDB) KVType() locketdb.KVType {
+	return locketdb.BadgerDB
+}
+
 func (b *badgerDB) Close() error {
 	return b.db.Close()
 }
 
 func (b *badgerDB) Print() error {
-	return nil
+	fmt.Println(b.db.LevelsToString())
+
+	return b.db.View(func(txn *badger.Txn) error {
+		iter := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer iter.Close()
+		for iter.Rewind(); iter.Valid(); iter.Next() {
+			value, err := iter.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("[%X]:\t[%X]\n", iter.Item().Key(), value)
+		}
+		return nil
+	})
 }
 
 func (b *badgerDB) iteratorOpts(start, end []byte, opts badger.IteratorOptions) (*badgerDBIterator, error) {
@@ -133,22 +412,21 @@ func (b *badgerDB) iteratorOpts(start, end []byte, opts badger.IteratorOptions)
 		return nil, locketdb.ErrKeyEmpty
 	}
 	txn := b.db.NewTransaction(false)
-	iter := txn.NewIterator(opts)
-	iter.Rewind()
-	iter.Seek(start)
-	if opts.Reverse && iter.Valid() && bytes.Equal(iter.Item().Key(), start) {
-		// If we're going in reverse, our starting point was "end",
-		// which is exclusive.
-		iter.Next()
-	}
-	return &badgerDBIterator{
+	return newBadgerDBIterator(txn, start, end, opts, true), nil
+}
+
+func newBadgerDBIterator(txn *badger.Txn, start, end []byte, opts badger.IteratorOptions, ownsTxn bool) *badgerDBIterator {
+	iter := &badgerDBIterator{
 		reverse: opts.Reverse,
 		start:   start,
 		end:     end,
 
-		txn:  txn,
-		iter: iter,
-	}, nil
+		txn:     txn,
+		ownsTxn: ownsTxn,
+		iter:    txn.NewIterator(opts),
+	}
+	iter.First()
+	return iter
 }
 
 func (b *badgerDB) Iterator(start, end []byte) (locketdb.Iterator, error) {
@@ -159,36 +437,136 @@ func (b *badgerDB) Iterator(start, end []byte) (locketdb.Iterator, error) {
 func (b *badgerDB) ReverseIterator(start, end []byte) (locketdb.Iterator, error) {
 	opts := badger.DefaultIteratorOptions
 	opts.Reverse = true
-	return b.iteratorOpts(end, start, opts)
+	return b.iteratorOpts(start, end, opts)
 }
 
+// Stats implements DB. The sizes of the LSM tree and of the value log are those computed by
+// badger, which refreshes them every minute.
 func (b *badgerDB) Stats() map[string]string {
-	return nil
+	stats := make(map[string]string)
+	set := func(value interface{}, names ...string) {
+		stats[locketdb.StatsKey(locketdb.BadgerDB, names...)] = fmt.Sprint(value)
+	}
+
+	lsm, vlog := b.db.Size()
+	set(lsm+vlog, "disk", "size_bytes")
+	set(lsm, "lsm", "size_bytes")
+	set(vlog, "vlog", "size_bytes")
+
+	for _, level := range b.db.Levels() {
+		n := strconv.Itoa(level.Level)
+		set(level.NumTables, "level", n, "tables")
+		set(level.Size, "level", n, "size_bytes")
+		set(level.TargetSize, "level", n, "target_size_bytes")
+		set(level.StaleDatSize, "level", n, "stale_bytes")
+		set(strconv.FormatFloat(level.Score, 'f', 2, 64), "level", n, "score")
+	}
+
+	var tables, keys, uncompressed, index, bloom uint64
+	for _, table := range b.db.Tables() {
+		tables++
+		keys += uint64(table.KeyCount)
+		uncompressed += uint64(table.UncompressedSize)
+		index += uint64(table.IndexSz)
+		bloom += uint64(table.BloomFilterSize)
+	}
+	set(tables, "table", "count")
+	set(keys, "table", "keys")
+	set(uncompressed, "table", "uncompressed_bytes")
+	set(index, "table", "index_bytes")
+	set(bloom, "table", "bloom_bytes")
+
+	caches := map[string]*ristretto.Metrics{
+		"block_cache": b.db.BlockCacheMetrics(),
+		"index_cache": b.db.IndexCacheMetrics(),
+	}
+	for name, m := range caches {
+		if m == nil {
+			continue
+		}
+		set(m.CostAdded()-m.CostEvicted(), name, "size_bytes")
+		set(m.KeysAdded()-m.KeysEvicted(), name, "entries")
+		set(m.Hits(), name, "hits")
+		set(m.Misses(), name, "misses")
+	}
+	return stats
+}
+
+// Metrics implements MetricsReporter. Keys are counted from the tables, including the versions
+// not garbage collected yet, and not from the memtables, whose size badger does not report.
+func (b *badgerDB) Metrics() locketdb.Metrics {
+	var metrics locketdb.Metrics
+	lsm, vlog := b.db.Size()
+	metrics.DiskBytes = locketdb.KnownMetric(uint64(lsm + vlog))
+	var keys uint64
+	for _, table := range b.db.Tables() {
+		keys += uint64(table.KeyCount)
+	}
+	metrics.LiveKeys = locketdb.KnownMetric(keys)
+	if cache := b.db.BlockCacheMetrics(); cache != nil {
+		metrics.CacheHits = locketdb.KnownMetric(cache.Hits())
+		metrics.CacheMisses = locketdb.KnownMetric(cache.Misses())
+	}
+	return metrics
 }
 
 func (b *badgerDB) NewBatch() locketdb.Batch {
-	wb := &badgerDBBatch{
-		db:         b.db,
-		wb:         b.db.NewWriteBatch(),
-		firstFlush: make(chan struct{}, 1),
+	if b.readOnly {
+		return locketdb.NewReadOnlyBatch()
+	}
+	if b.merge != nil {
+		return &badgerDBBatch{db: b.db, merge: b.merge}
 	}
-	wb.firstFlush <- struct{}{}
-	return wb
+	return &badgerDBBatch{db: b.db, wb: b.db.NewWriteBatch()}
 }
 
-var _ locketdb.Batch = (*badgerDBBatch)(nil)
+var (
+	_ locketdb.Batch        = (*badgerDBBatch)(nil)
+	_ locketdb.RangeDeleter = (*badgerDBBatch)(nil)
+	_ locketdb.Merger       = (*badgerDBBatch)(nil)
+)
 
+// badgerDBBatch writes through a WriteBatch, which may span several
+// transactions. Batches of a database with a MergeOperator record their
+// operations instead and apply them at Write in a single transaction, retried
+// on conflicts, so that merges read the values they fold into atomically. Such
+// batches fail with badger.ErrTxnTooBig rather than split a large write.
 type badgerDBBatch struct {
 	db *badger.DB
-	wb *badger.WriteBatch
 
-	// Calling db.Flush twice panics, so we must keep track of whether we've
-	// flushed already on our own. If Write can receive from the firstFlush
-	// channel, then it's the first and only Flush call we should do.
+	// wb is set to nil once the batch has been written or closed. Calling
+	// Flush twice, or Flush after Cancel, panics, so it must not be touched
+	// afterwards. It is nil from the start when merge is set.
 	//
 	// Upstream bug report:
 	// https://github.com/dgraph-io/badger/issues/1394
-	firstFlush chan struct{}
+	wb *badger.WriteBatch
+
+	// merge is the MergeOperator of the database, and ops are the operations
+	// recorded when it is set.
+	merge locketdb.MergeOperator
+	ops   []badgerDBBatchOp
+
+	// closed is set once the batch has been written or closed.
+	closed bool
+
+	// setKeys are the keys set in the batch so far, which DeleteRange must
+	// cover too since a WriteBatch cannot be inspected.
+	setKeys [][]byte
+}
+
+type badgerDBBatchOpType int
+
+const (
+	badgerDBBatchSet badgerDBBatchOpType = iota
+	badgerDBBatchDelete
+	badgerDBBatchMerge
+)
+
+type badgerDBBatchOp struct {
+	opType badgerDBBatchOpType
+	key    []byte
+	value  []byte
 }
 
 func (b *badgerDBBatch) Set(key, value []byte) error {
@@ -198,6 +576,14 @@ func (b *badgerDBBatch) Set(key, value []byte) error {
 	if value == nil {
 		return locketdb.ErrValueNil
 	}
+	if b.closed {
+		return locketdb.ErrBatchClosed
+	}
+	b.setKeys = append(b.setKeys, key)
+	if b.merge != nil {
+		b.ops = append(b.ops, badgerDBBatchOp{badgerDBBatchSet, key, value})
+		return nil
+	}
 	return b.wb.Set(key, value)
 }
 
@@ -205,16 +591,130 @@ func (b *badgerDBBatch) Delete(key []byte) error {
 	if len(key) == 0 {
 		return locketdb.ErrKeyEmpty
 	}
+	if b.closed {
+		return locketdb.ErrBatchClosed
+	}
+	return b.delete(key)
+}
+
+func (b *badgerDBBatch) delete(key []byte) error {
+	if b.merge != nil {
+		b.ops = append(b.ops, badgerDBBatchOp{badgerDBBatchDelete, key, nil})
+		return nil
+	}
 	return b.wb.Delete(key)
 }
 
+// Merge queues operand to be folded into the value of key at Write, in the
+// transaction writing the batch.
+func (b *badgerDBBatch) Merge(key, operand []byte) error {
+	if len(key) == 0 {
+		return locketdb.ErrKeyEmpty
+	}
+	if operand == nil {
+		return locketdb.ErrValueNil
+	}
+	if b.merge == nil {
+		return locketdb.ErrNotSupported
+	}
+	if b.closed {
+		return locketdb.ErrBatchClosed
+	}
+	b.setKeys = append(b.setKeys, key)
+	b.ops = append(b.ops, badgerDBBatchOp{badgerDBBatchMerge, key, operand})
+	return nil
+}
+
+// DeleteRange queues a delete for every key in the range, both those in the
+// database when DeleteRange is called and those set earlier in the batch.
+func (b *badgerDBBatch) DeleteRange(start, end []byte) error {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return locketdb.ErrKeyEmpty
+	}
+	if b.closed {
+		return locketdb.ErrBatchClosed
+	}
+	if locketdb.IsEmptyRange(start, end) {
+		return nil
+	}
+	var keys [][]byte
+	err := b.db.View(func(txn *badger.Txn) error {
+		keys = rangeKeys(txn, start, end)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, key := range b.setKeys {
+		if locketdb.IsKeyInDomain(key, start, end) {
+			keys = append(keys, key)
+		}
+	}
+	for _, key := range keys {
+		if err := b.delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (b *badgerDBBatch) Write() error {
-	select {
-	case <-b.firstFlush:
-		return b.wb.Flush()
-	default:
-		return fmt.Errorf("batch already flushed")
+	if b.closed {
+		return locketdb.ErrBatchClosed
+	}
+	var err error
+	if b.merge != nil {
+		err = b.apply()
+	} else {
+		err = b.wb.Flush()
+	}
+	// Make sure batch cannot be used afterwards. Callers should still call Close(), for errors.
+	b.closed = true
+	b.wb = nil
+	b.ops = nil
+	b.setKeys = nil
+	return err
+}
+
+// apply writes the recorded operations in a transaction, retried on conflicts
+// like badgerDB.Merge.
+func (b *badgerDBBatch) apply() error {
+	for {
+		err := b.db.Update(func(txn *badger.Txn) error {
+			for _, op := range b.ops {
+				var err error
+				switch op.opType {
+				case badgerDBBatchSet:
+					err = txn.Set(op.key, op.value)
+				case badgerDBBatchDelete:
+					err = txn.Delete(op.key)
+				case badgerDBBatchMerge:
+					err = b.mergeOp(txn, op.key, op.value)
+				}
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != badger.ErrConflict {
+			return err
+		}
+	}
+}
+
+// mergeOp folds operand into the value of key in txn, which includes the
+// operations applied before it.
+func (b *badgerDBBatch) mergeOp(txn *badger.Txn, key, operand []byte) error {
+	current, err := getValue(txn, key)
+	if err != nil {
+		return err
 	}
+	value, err := locketdb.MergeValue(b.merge, current, operand)
+	if err != nil {
+		return err
+	}
+	return txn.Set(key, value)
 }
 
 func (b *badgerDBBatch) WriteSync() error {
@@ -222,11 +722,13 @@ func (b *badgerDBBatch) WriteSync() error {
 }
 
 func (b *badgerDBBatch) Close() error {
-	select {
-	case <-b.firstFlush: // a Flush after Cancel panics too
-	default:
+	if b.wb != nil {
+		b.wb.Cancel()
+		b.wb = nil
 	}
-	b.wb.Cancel()
+	b.closed = true
+	b.ops = nil
+	b.setKeys = nil
 	return nil
 }
 
@@ -234,15 +736,30 @@ type badgerDBIterator struct {
 	reverse    bool
 	start, end []byte
 
-	txn  *badger.Txn
-	iter *badger.Iterator
+	txn *badger.Txn
+	// ownsTxn is set when the iterator must discard txn on Close, i.e. when it was not obtained
+	// from a Snapshot.
+	ownsTxn bool
+	iter    *badger.Iterator
+	// back goes the other way than iter, to find the keys Prev and Last move to. It is opened on
+	// first use.
+	back *badger.Iterator
+	// isInvalid is set when Prev or Last find no key.
+	isInvalid bool
 
 	lastErr error
 }
 
+var _ locketdb.SeekIterator = (*badgerDBIterator)(nil)
+
 func (i *badgerDBIterator) Close() error {
 	i.iter.Close()
-	i.txn.Discard()
+	if i.back != nil {
+		i.back.Close()
+	}
+	if i.ownsTxn {
+		i.txn.Discard()
+	}
 	return nil
 }
 
@@ -257,17 +774,93 @@ func (i *badgerDBIterator) Next() {
 }
 
 func (i *badgerDBIterator) Valid() bool {
-	if !i.iter.Valid() {
+	if i.isInvalid || !i.iter.Valid() {
 		return false
 	}
-	if len(i.end) > 0 {
-		key := i.iter.Item().Key()
-		if c := bytes.Compare(key, i.end); (!i.reverse && c >= 0) || (i.reverse && c < 0) {
-			// We're at the end key, or past the end.
-			return false
+	// Prev and Seek may move past either bound.
+	return locketdb.IsKeyInDomain(i.iter.Item().Key(), i.start, i.end)
+}
+
+// Prev finds the previous key with the back iterator, then seeks to it.
+func (i *badgerDBIterator) Prev() {
+	if !i.Valid() {
+		panic("iterator is invalid")
+	}
+	i.jumpTo(i.seekBack(i.iter.Item().KeyCopy(nil), true))
+}
+
+// Seek moves to key, clamped to the domain.
+func (i *badgerDBIterator) Seek(key []byte) {
+	i.isInvalid = false
+	if !i.reverse {
+		if i.start != nil && bytes.Compare(key, i.start) < 0 {
+			key = i.start
 		}
+		seekIterator(i.iter, key, false)
+		return
+	}
+	if i.end != nil && bytes.Compare(key, i.end) >= 0 {
+		i.First()
+		return
+	}
+	if len(key) == 0 {
+		// No key is <= the empty key.
+		i.isInvalid = true
+		return
+	}
+	// In reverse, badger seeks to the greatest key <= key.
+	seekIterator(i.iter, key, false)
+}
+
+func (i *badgerDBIterator) First() {
+	i.isInvalid = false
+	if i.reverse {
+		// The end of the domain is exclusive, and a reverse rewind starts from the last key.
+		seekIterator(i.iter, i.end, true)
+	} else {
+		seekIterator(i.iter, i.start, false)
+	}
+}
+
+func (i *badgerDBIterator) Last() {
+	if i.reverse {
+		i.jumpTo(i.seekBack(i.start, false))
+	} else {
+		i.jumpTo(i.seekBack(i.end, true))
+	}
+}
+
+// seekBack seeks the back iterator to key, or past it if exclusive, and
+// reports whether it found a key.
+func (i *badgerDBIterator) seekBack(key []byte, exclusive bool) bool {
+	if i.back == nil {
+		i.back = i.txn.NewIterator(badger.IteratorOptions{Reverse: !i.reverse})
+	}
+	seekIterator(i.back, key, exclusive)
+	return i.back.Valid()
+}
+
+// jumpTo moves iter to the key of the back iterator, if found.
+func (i *badgerDBIterator) jumpTo(found bool) {
+	if !found {
+		i.isInvalid = true
+		return
+	}
+	i.isInvalid = false
+	i.iter.Seek(i.back.Item().KeyCopy(nil))
+}
+
+// seekIterator seeks iter to key, or to the first key past it if exclusive. An
+// empty key rewinds it.
+func seekIterator(iter *badger.Iterator, key []byte, exclusive bool) {
+	if len(key) == 0 {
+		iter.Rewind()
+		return
+	}
+	iter.Seek(key)
+	if exclusive && iter.Valid() && bytes.Equal(iter.Item().Key(), key) {
+		iter.Next()
 	}
-	return true
 }
 
 func (i *badgerDBIterator) Key() []byte {
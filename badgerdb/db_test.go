@@ -0,0 +1,130 @@
+package badgerdb
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/meission/locketdb"
+	"github.com/meission/locketdb/locketdbtest"
+)
+
+func TestConformance(t *testing.T) {
+	dir := t.TempDir()
+	n := 0
+	locketdbtest.RunConformance(t, func() locketdb.DB {
+		n++
+		db, err := NewDB(fmt.Sprintf("test%d", n), dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return db
+	})
+}
+
+func TestMergeConformance(t *testing.T) {
+	dir := t.TempDir()
+	n := 0
+	locketdbtest.RunMergeConformance(t, func(op locketdb.MergeOperator) locketdb.DB {
+		n++
+		db, err := OpenWithOptions(fmt.Sprintf("test%d", n), dir, locketdb.Options{MergeOperator: op})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return db
+	})
+}
+
+func TestReverseIteratorDomain(t *testing.T) {
+	db, err := NewDB("test", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, key := range []string{"a", "b", "c", "d"} {
+		if err := db.Set([]byte(key), []byte(key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	snap, err := locketdb.NewSnapshot(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer snap.Close()
+	txn, err := locketdb.Begin(db, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer txn.Rollback()
+
+	readers := map[string]func(start, end []byte) (locketdb.Iterator, error){
+		"db":       db.ReverseIterator,
+		"snapshot": snap.ReverseIterator,
+		"txn":      txn.ReverseIterator,
+	}
+	domains := []struct {
+		start, end []byte
+		want       string
+	}{
+		{nil, nil, "dcba"},
+		{nil, []byte("c"), "ba"},
+		{[]byte("b"), nil, "dcb"},
+		{[]byte("b"), []byte("d"), "cb"},
+		{[]byte("b0"), []byte("c0"), "c"},
+		{[]byte("e"), nil, ""},
+	}
+	for name, reverseIterator := range readers {
+		for _, d := range domains {
+			itr, err := reverseIterator(d.start, d.end)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if start, end := itr.Domain(); !bytes.Equal(start, d.start) || !bytes.Equal(end, d.end) {
+				t.Errorf("%s: Domain() = [%q, %q), want [%q, %q)", name, start, end, d.start, d.end)
+			}
+			var got []byte
+			for ; itr.Valid(); itr.Next() {
+				got = append(got, itr.Key()...)
+			}
+			itr.Close()
+			if string(got) != d.want {
+				t.Errorf("%s: ReverseIterator(%q, %q) yields %q, want %q", name, d.start, d.end, got, d.want)
+			}
+		}
+	}
+}
+
+func TestStats(t *testing.T) {
+	db, err := NewDB("test", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.Set([]byte("key"), []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := db.Stats()
+	for _, key := range []string{"badger.disk.size_bytes", "badger.lsm.size_bytes", "badger.vlog.size_bytes",
+		"badger.level.0.tables", "badger.table.count", "badger.block_cache.hits", "badger.block_cache.misses"} {
+		if _, ok := stats[key]; !ok {
+			t.Errorf("Stats() is missing %q", key)
+		}
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	db, err := NewDB("test", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	m := locketdb.MetricsOf(db)
+	if !m.DiskBytes.Known || !m.LiveKeys.Known || !m.CacheHits.Known || !m.CacheMisses.Known {
+		t.Errorf("expected the disk, keys and cache metrics to be known: %+v", m)
+	}
+	if m.MemtableBytes.Known || m.OpenIterators.Known || m.OpenTxns.Known {
+		t.Errorf("expected the metrics badger does not report to be unknown: %+v", m)
+	}
+}
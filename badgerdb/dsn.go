@@ -0,0 +1,39 @@
+package badgerdb
+
+import (
+	"fmt"
+
+	"github.com/meission/locketdb"
+)
+
+// OpenDSN opens a database from a DSN, see locketdb.Open. The supported
+// options are:
+//
+//	sync                  sync every write to disk (boolean)
+//	cache_size            block cache size (size)
+//	index_cache_size      index cache size (size)
+//	mem_table_size        memtable size (size)
+//	value_log_file_size   maximum size of a value log file (size)
+//	value_threshold       values larger than this go to the value log (size)
+//	num_versions_to_keep  versions kept per key (integer)
+func OpenDSN(name string, dir string, params *locketdb.DSNParams) (locketdb.DB, error) {
+	if name == "" {
+		return nil, fmt.Errorf("DSN must include the database path")
+	}
+	opts := defaultOptions(name, dir)
+	params.Bool("sync", &opts.SyncWrites)
+	params.Size("cache_size", &opts.BlockCacheSize)
+	params.Size("index_cache_size", &opts.IndexCacheSize)
+	params.Size("mem_table_size", &opts.MemTableSize)
+	params.Size("value_log_file_size", &opts.ValueLogFileSize)
+	params.Size("value_threshold", &opts.ValueThreshold)
+	params.Int("num_versions_to_keep", &opts.NumVersionsToKeep)
+	if err := params.Err(); err != nil {
+		return nil, err
+	}
+	db, err := openDir(opts)
+	if err != nil {
+		return nil, err
+	}
+	return db, nil
+}
@@ -0,0 +1,76 @@
+package badgerdb
+
+import (
+	"github.com/dgraph-io/badger/v3"
+	"github.com/meission/locketdb"
+)
+
+// badgerDBSnapshot is a read-only badger transaction.
+type badgerDBSnapshot struct {
+	txn *badger.Txn
+}
+
+var _ locketdb.Snapshot = (*badgerDBSnapshot)(nil)
+
+// NewSnapshot implements Snapshotter.
+func (b *badgerDB) NewSnapshot() (locketdb.Snapshot, error) {
+	return &badgerDBSnapshot{txn: b.db.NewTransaction(false)}, nil
+}
+
+func (s *badgerDBSnapshot) Get(key []byte) ([]byte, error) {
+	return txnGet(s.txn, key)
+}
+
+func (s *badgerDBSnapshot) Has(key []byte) (bool, error) {
+	return txnHas(s.txn, key)
+}
+
+func (s *badgerDBSnapshot) Iterator(start, end []byte) (locketdb.Iterator, error) {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return nil, locketdb.ErrKeyEmpty
+	}
+	opts := badger.DefaultIteratorOptions
+	return newBadgerDBIterator(s.txn, start, end, opts, false), nil
+}
+
+func (s *badgerDBSnapshot) ReverseIterator(start, end []byte) (locketdb.Iterator, error) {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return nil, locketdb.ErrKeyEmpty
+	}
+	opts := badger.DefaultIteratorOptions
+	opts.Reverse = true
+	return newBadgerDBIterator(s.txn, start, end, opts, false), nil
+}
+
+func (s *badgerDBSnapshot) Close() error {
+	s.txn.Discard()
+	return nil
+}
+
+func txnGet(txn *badger.Txn, key []byte) ([]byte, error) {
+	if len(key) == 0 {
+		return nil, locketdb.ErrKeyEmpty
+	}
+	item, err := txn.Get(key)
+	if err == badger.ErrKeyNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	val, err := item.ValueCopy(nil)
+	if err == nil && val == nil {
+		val = []byte{}
+	}
+	return val, err
+}
+
+func txnHas(txn *badger.Txn, key []byte) (bool, error) {
+	if len(key) == 0 {
+		return false, locketdb.ErrKeyEmpty
+	}
+	_, err := txn.Get(key)
+	if err != nil && err != badger.ErrKeyNotFound {
+		return false, err
+	}
+	return err != badger.ErrKeyNotFound, nil
+}
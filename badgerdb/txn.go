@@ -0,0 +1,117 @@
+package badgerdb
+
+import (
+	"github.com/dgraph-io/badger/v3"
+	"github.com/meission/locketdb"
+)
+
+// badgerDBTxn is a native badger transaction. Badger detects conflicts itself, and only allows
+// one iterator at a time on a writable transaction.
+type badgerDBTxn struct {
+	// txn is set to nil once the transaction has been committed or rolled back.
+	txn      *badger.Txn
+	writable bool
+}
+
+var _ locketdb.Txn = (*badgerDBTxn)(nil)
+
+// Begin implements Transactional.
+func (b *badgerDB) Begin(writable bool) (locketdb.Txn, error) {
+	if writable && b.readOnly {
+		return nil, locketdb.ErrReadOnly
+	}
+	return &badgerDBTxn{
+		txn:      b.db.NewTransaction(writable),
+		writable: writable,
+	}, nil
+}
+
+func (t *badgerDBTxn) Get(key []byte) ([]byte, error) {
+	if t.txn == nil {
+		return nil, locketdb.ErrTxnClosed
+	}
+	return txnGet(t.txn, key)
+}
+
+func (t *badgerDBTxn) Has(key []byte) (bool, error) {
+	if t.txn == nil {
+		return false, locketdb.ErrTxnClosed
+	}
+	return txnHas(t.txn, key)
+}
+
+func (t *badgerDBTxn) Set(key, value []byte) error {
+	if len(key) == 0 {
+		return locketdb.ErrKeyEmpty
+	}
+	if value == nil {
+		return locketdb.ErrValueNil
+	}
+	if err := t.checkWritable(); err != nil {
+		return err
+	}
+	return t.txn.Set(key, value)
+}
+
+func (t *badgerDBTxn) Delete(key []byte) error {
+	if len(key) == 0 {
+		return locketdb.ErrKeyEmpty
+	}
+	if err := t.checkWritable(); err != nil {
+		return err
+	}
+	return t.txn.Delete(key)
+}
+
+func (t *badgerDBTxn) checkWritable() error {
+	if t.txn == nil {
+		return locketdb.ErrTxnClosed
+	}
+	if !t.writable {
+		return locketdb.ErrTxnReadOnly
+	}
+	return nil
+}
+
+func (t *badgerDBTxn) Iterator(start, end []byte) (locketdb.Iterator, error) {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return nil, locketdb.ErrKeyEmpty
+	}
+	if t.txn == nil {
+		return nil, locketdb.ErrTxnClosed
+	}
+	opts := badger.DefaultIteratorOptions
+	return newBadgerDBIterator(t.txn, start, end, opts, false), nil
+}
+
+func (t *badgerDBTxn) ReverseIterator(start, end []byte) (locketdb.Iterator, error) {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return nil, locketdb.ErrKeyEmpty
+	}
+	if t.txn == nil {
+		return nil, locketdb.ErrTxnClosed
+	}
+	opts := badger.DefaultIteratorOptions
+	opts.Reverse = true
+	return newBadgerDBIterator(t.txn, start, end, opts, false), nil
+}
+
+func (t *badgerDBTxn) Commit() error {
+	if t.txn == nil {
+		return locketdb.ErrTxnClosed
+	}
+	err := t.txn.Commit()
+	t.txn = nil
+	if err == badger.ErrConflict {
+		return locketdb.ErrConflict
+	}
+	return err
+}
+
+func (t *badgerDBTxn) Rollback() error {
+	if t.txn != nil {
+		t.txn.Discard()
+		t.txn = nil
+	}
+	return nil
+}
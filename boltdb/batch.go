@@ -11,8 +11,11 @@ type opType int
 const (
 	opTypeSet opType = iota + 1
 	opTypeDelete
+	opTypeDeleteRange
 )
 
+// operation is a queued write. For opTypeDeleteRange, key and value hold the start and end of the
+// range.
 type operation struct {
 	opType
 	key   []byte
@@ -25,7 +28,10 @@ type boltDBBatch struct {
 	ops []operation
 }
 
-var _ locketdb.Batch = (*boltDBBatch)(nil)
+var (
+	_ locketdb.Batch        = (*boltDBBatch)(nil)
+	_ locketdb.RangeDeleter = (*boltDBBatch)(nil)
+)
 
 func newBoltDBBatch(db *boltDB) *boltDBBatch {
 	return &boltDBBatch{
@@ -61,11 +67,25 @@ func (b *boltDBBatch) Delete(key []byte) error {
 	return nil
 }
 
+// DeleteRange implements RangeDeleter.
+func (b *boltDBBatch) DeleteRange(start, end []byte) error {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return locketdb.ErrKeyEmpty
+	}
+	if b.ops == nil {
+		return locketdb.ErrBatchClosed
+	}
+	b.ops = append(b.ops, operation{opTypeDeleteRange, start, end})
+	return nil
+}
+
 // Write implements Batch.
 func (b *boltDBBatch) Write() error {
 	if b.ops == nil {
 		return locketdb.ErrBatchClosed
 	}
+	b.db.mtx.RLock()
+	defer b.db.mtx.RUnlock()
 	err := b.db.db.Batch(func(tx *bbolt.Tx) error {
 		bkt := tx.Bucket(bucket)
 		for _, op := range b.ops {
@@ -78,6 +98,10 @@ func (b *boltDBBatch) Write() error {
 				if err := bkt.Delete(op.key); err != nil {
 					return err
 				}
+			case opTypeDeleteRange:
+				if err := deleteRange(bkt, op.key, op.value); err != nil {
+					return err
+				}
 			}
 		}
 		return nil
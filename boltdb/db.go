@@ -1,10 +1,13 @@
 package boltdb
 
 import (
-	"errors"
+	"bytes"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/meission/locketdb"
 	"go.etcd.io/bbolt"
@@ -12,6 +15,9 @@ import (
 
 var bucket = []byte("locket")
 
+// lockTimeout is how long OpenWithOptions waits for another process to release the database.
+const lockTimeout = time.Second
+
 // BoltDB is a wrapper around etcd's fork of bolt (https://github.com/etcd-io/bbolt).
 //
 // NOTE: All operations (including Set, Delete) are synchronous by default. One
@@ -20,13 +26,34 @@ var bucket = []byte("locket")
 // A single bucket ([]byte("locket")) is used per a database instance. This could
 // lead to performance issues when/if there will be lots of keys.
 type boltDB struct {
-	db *bbolt.DB
+	// mtx guards db, which Compact replaces: operations hold it shared while they run, and Compact
+	// exclusively. Iterators, snapshots and transactions outlive the call opening them, so they
+	// are counted in open instead, and Compact waits on released until none is left.
+	mtx      sync.RWMutex
+	released *sync.Cond
+	open     int64
+	db       *bbolt.DB
+
+	// mode and opts are kept to reopen the database after Compact.
+	mode os.FileMode
+	opts *bbolt.Options
 }
 
-var _ locketdb.DB = (*boltDB)(nil)
+var (
+	_ locketdb.DB                = (*boltDB)(nil)
+	_ locketdb.Snapshotter       = (*boltDB)(nil)
+	_ locketdb.Transactional     = (*boltDB)(nil)
+	_ locketdb.RangeDeleter      = (*boltDB)(nil)
+	_ locketdb.Compacter         = (*boltDB)(nil)
+	_ locketdb.KVTyper           = (*boltDB)(nil)
+	_ locketdb.MetricsReporter   = (*boltDB)(nil)
+	_ locketdb.ConditionalWriter = (*boltDB)(nil)
+)
 
 func init() {
 	locketdb.RegisterEngine(locketdb.BoltDB, NewDB)
+	locketdb.RegisterDSNEngine(locketdb.BoltDB, OpenDSN)
+	locketdb.RegisterOptionsEngine(locketdb.BoltDB, OpenWithOptions)
 }
 
 // NewDB returns a BoltDB with default options.
@@ -34,29 +61,107 @@ func NewDB(name, dir string) (locketdb.DB, error) {
 	return NewDBWithOpts(name, dir, bbolt.DefaultOptions)
 }
 
-// NewDBWithOpts allows you to supply *bbolt.Options. ReadOnly: true is not
-// supported because NewDBWithOpts creates a global bucket.
+// NewDBWithOpts allows you to supply *bbolt.Options. With ReadOnly: true the
+// database must already exist, and writes return locketdb.ErrReadOnly.
 func NewDBWithOpts(name string, dir string, opts *bbolt.Options) (locketdb.DB, error) {
-	if opts.ReadOnly {
-		return nil, errors.New("ReadOnly: true is not supported")
-	}
+	return open(filepath.Join(dir, name+".db"), os.ModePerm, opts)
+}
 
+// OpenWithOptions opens a database with the common options, see locketdb.NewDBWithOptions.
+// Writes are always synced, and CacheSize is not supported since bbolt relies on the page cache
+// of the operating system. With a MergeOperator, the database is wrapped in a locketdb.MergeDB,
+// see locketdb.OpenMergeDB.
+//
+// bbolt holds an exclusive lock on its file while opened for writing, so opening it while another
+// process has it open for writing, or for reading when opening it for writing, fails after
+// lockTimeout with an error wrapping bbolt.ErrTimeout.
+func OpenWithOptions(name string, dir string, opts locketdb.Options) (locketdb.DB, error) {
+	if opts.CacheSize != 0 {
+		return nil, locketdb.OptionNotSupported(locketdb.BoltDB, "CacheSize")
+	}
 	dbPath := filepath.Join(dir, name+".db")
-	db, err := bbolt.Open(dbPath, os.ModePerm, opts)
+	if opts.ErrorIfMissing {
+		if _, err := os.Stat(dbPath); err != nil {
+			return nil, err
+		}
+	}
+	mode := opts.FileMode
+	if mode == 0 {
+		mode = os.ModePerm
+	}
+	o := *bbolt.DefaultOptions
+	o.ReadOnly = opts.ReadOnly
+	o.Timeout = lockTimeout
+	db, err := open(dbPath, mode, &o)
+	if err != nil {
+		return nil, err
+	}
+	mdb, err := locketdb.OpenMergeDB(db, opts.MergeOperator)
 	if err != nil {
+		db.Close()
 		return nil, err
 	}
+	return mdb, nil
+}
 
-	// create a global bucket
-	err = db.Update(func(tx *bbolt.Tx) error {
-		_, err := tx.CreateBucketIfNotExists(bucket)
-		return err
-	})
+func open(dbPath string, mode os.FileMode, opts *bbolt.Options) (locketdb.DB, error) {
+	db, err := bbolt.Open(dbPath, mode, opts)
+	if err == bbolt.ErrTimeout {
+		return nil, fmt.Errorf("%s is locked by another process: %w", dbPath, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.ReadOnly {
+		// the global bucket cannot be created, it must exist already
+		err = db.View(func(tx *bbolt.Tx) error {
+			if tx.Bucket(bucket) == nil {
+				return fmt.Errorf("bucket %q not found, not a locketdb database", bucket)
+			}
+			return nil
+		})
+	} else {
+		// create a global bucket
+		err = db.Update(func(tx *bbolt.Tx) error {
+			_, err := tx.CreateBucketIfNotExists(bucket)
+			return err
+		})
+	}
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	bdb := &boltDB{db: db, mode: mode, opts: opts}
+	bdb.released = sync.NewCond(&bdb.mtx)
+	return bdb, nil
+}
+
+// begin starts the bbolt transaction of an iterator, snapshot or transaction, which keeps the
+// database from being compacted until it is given to release. The transaction is started without
+// holding mtx, since a writable one waits for the other writable transactions to end.
+func (bdb *boltDB) begin(writable bool) (*bbolt.Tx, error) {
+	bdb.mtx.RLock()
+	atomic.AddInt64(&bdb.open, 1)
+	db := bdb.db
+	bdb.mtx.RUnlock()
+
+	tx, err := db.Begin(writable)
 	if err != nil {
+		bdb.release()
 		return nil, err
 	}
+	return tx, nil
+}
 
-	return &boltDB{db: db}, nil
+// release lets Compact run once every transaction started by begin is released. mtx is taken so
+// that Compact cannot miss the signal between checking open and waiting on released.
+func (bdb *boltDB) release() {
+	bdb.mtx.RLock()
+	atomic.AddInt64(&bdb.open, -1)
+	bdb.mtx.RUnlock()
+	bdb.released.Broadcast()
 }
 
 // Get implements DB.
@@ -64,6 +169,8 @@ func (bdb *boltDB) Get(key []byte) (value []byte, err error) {
 	if len(key) == 0 {
 		return nil, locketdb.ErrKeyEmpty
 	}
+	bdb.mtx.RLock()
+	defer bdb.mtx.RUnlock()
 	err = bdb.db.View(func(tx *bbolt.Tx) error {
 		b := tx.Bucket(bucket)
 		if v := b.Get(key); v != nil {
@@ -94,6 +201,11 @@ func (bdb *boltDB) Set(key, value []byte) error {
 	if value == nil {
 		return locketdb.ErrValueNil
 	}
+	if bdb.opts.ReadOnly {
+		return locketdb.ErrReadOnly
+	}
+	bdb.mtx.RLock()
+	defer bdb.mtx.RUnlock()
 	return bdb.db.Update(func(tx *bbolt.Tx) error {
 		b := tx.Bucket(bucket)
 		return b.Put(key, value)
@@ -110,6 +222,11 @@ func (bdb *boltDB) Delete(key []byte) error {
 	if len(key) == 0 {
 		return locketdb.ErrKeyEmpty
 	}
+	if bdb.opts.ReadOnly {
+		return locketdb.ErrReadOnly
+	}
+	bdb.mtx.RLock()
+	defer bdb.mtx.RUnlock()
 	return bdb.db.Update(func(tx *bbolt.Tx) error {
 		return tx.Bucket(bucket).Delete(key)
 	})
@@ -120,13 +237,154 @@ func (bdb *boltDB) DeleteSync(key []byte) error {
 	return bdb.Delete(key)
 }
 
+// CompareAndSwap implements ConditionalWriter within a single bbolt transaction.
+func (bdb *boltDB) CompareAndSwap(key, expected, new []byte) (bool, error) {
+	if len(key) == 0 {
+		return false, locketdb.ErrKeyEmpty
+	}
+	if bdb.opts.ReadOnly {
+		return false, locketdb.ErrReadOnly
+	}
+	bdb.mtx.RLock()
+	defer bdb.mtx.RUnlock()
+	err := bdb.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(bucket)
+		if err := locketdb.CheckExpected(key, b.Get(key), expected); err != nil {
+			return err
+		}
+		if new == nil {
+			return b.Delete(key)
+		}
+		return b.Put(key, new)
+	})
+	return err == nil, err
+}
+
+// SetIfAbsent implements ConditionalWriter.
+func (bdb *boltDB) SetIfAbsent(key, value []byte) (bool, error) {
+	if len(key) == 0 {
+		return false, locketdb.ErrKeyEmpty
+	}
+	if value == nil {
+		return false, locketdb.ErrValueNil
+	}
+	return bdb.CompareAndSwap(key, nil, value)
+}
+
+// DeleteRange implements RangeDeleter.
+func (bdb *boltDB) DeleteRange(start, end []byte) error {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return locketdb.ErrKeyEmpty
+	}
+	if bdb.opts.ReadOnly {
+		return locketdb.ErrReadOnly
+	}
+	if locketdb.IsEmptyRange(start, end) {
+		return nil
+	}
+	bdb.mtx.RLock()
+	defer bdb.mtx.RUnlock()
+	return bdb.db.Update(func(tx *bbolt.Tx) error {
+		return deleteRange(tx.Bucket(bucket), start, end)
+	})
+}
+
+// deleteRange deletes every key in [start, end) with a single cursor. The cursor is re-positioned
+// after each deletion, since deleting moves the following keys under it.
+func deleteRange(bkt *bbolt.Bucket, start, end []byte) error {
+	c := bkt.Cursor()
+	seek := func() []byte {
+		if start == nil {
+			k, _ := c.First()
+			return k
+		}
+		k, _ := c.Seek(start)
+		return k
+	}
+	for k := seek(); k != nil && (end == nil || bytes.Compare(k, end) < 0); k = seek() {
+		if err := c.Delete(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compactTxMaxSize bounds the size of the transactions copying data during Compact.
+const compactTxMaxSize = 64 << 20
+
+// Compact implements Compacter. bbolt never shrinks its file, so the whole database is copied
+// into a fresh file which then replaces the original one. bbolt cannot compact a partial range,
+// so start and end are only validated.
+//
+// Compact waits for every iterator, snapshot and transaction to be closed, and blocks the other
+// operations while it copies the database, so that no write is lost. A goroutine must therefore
+// not call Compact while holding one of them, nor use the database while holding a writable
+// transaction and another goroutine compacts it.
+func (bdb *boltDB) Compact(start, end []byte) error {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return locketdb.ErrKeyEmpty
+	}
+	if bdb.opts.ReadOnly {
+		return locketdb.ErrReadOnly
+	}
+	bdb.mtx.Lock()
+	defer bdb.mtx.Unlock()
+	for atomic.LoadInt64(&bdb.open) > 0 {
+		bdb.released.Wait()
+	}
+
+	path := bdb.db.Path()
+	tmpPath := path + ".compact"
+	dst, err := bbolt.Open(tmpPath, bdb.mode, bdb.opts)
+	if err != nil {
+		return err
+	}
+	if err := bbolt.Compact(dst, bdb.db, compactTxMaxSize); err != nil {
+		dst.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	// From here on, the database is reopened whatever fails, so that it stays usable. The
+	// original file is only replaced once it is closed and the copy is complete.
+	err = bdb.db.Close()
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+	}
+	db, openErr := bbolt.Open(path, bdb.mode, bdb.opts)
+	if openErr != nil {
+		if err != nil {
+			return err
+		}
+		return openErr
+	}
+	bdb.db = db
+	return err
+}
+
+// KVType implements KVTyper.
+func (bdb *boltDB) KVType() locketdb.KVType {
+	return locketdb.BoltDB
+}
+
 // Close implements DB.
 func (bdb *boltDB) Close() error {
+	bdb.mtx.RLock()
+	defer bdb.mtx.RUnlock()
 	return bdb.db.Close()
 }
 
 // Print implements DB.
 func (bdb *boltDB) Print() error {
+	bdb.mtx.RLock()
+	defer bdb.mtx.RUnlock()
 	stats := bdb.db.Stats()
 	fmt.Printf("%v\n", stats)
 
@@ -141,18 +399,56 @@ func (bdb *boltDB) Print() error {
 	})
 }
 
+// Metrics implements MetricsReporter. Open transactions include the read transactions of
+// iterators and snapshots. Keys are not reported, since bbolt can only count them by walking the
+// whole B+tree. Bolt writes in place and relies on the page cache of the OS, so it has no memtable,
+// block cache nor compactions.
+func (bdb *boltDB) Metrics() locketdb.Metrics {
+	bdb.mtx.RLock()
+	defer bdb.mtx.RUnlock()
+	metrics := locketdb.Metrics{
+		MemtableBytes:          locketdb.KnownMetric(0),
+		PendingCompactionBytes: locketdb.KnownMetric(0),
+		OpenTxns:               locketdb.KnownMetric(uint64(bdb.db.Stats().OpenTxN)),
+	}
+	if info, err := os.Stat(bdb.db.Path()); err == nil {
+		metrics.DiskBytes = locketdb.KnownMetric(uint64(info.Size()))
+	}
+	return metrics
+}
+
 // Stats implements DB.
 func (bdb *boltDB) Stats() map[string]string {
+	bdb.mtx.RLock()
+	defer bdb.mtx.RUnlock()
 	stats := bdb.db.Stats()
 	m := make(map[string]string)
+	set := func(value interface{}, names ...string) {
+		m[locketdb.StatsKey(locketdb.BoltDB, names...)] = fmt.Sprint(value)
+	}
+
+	if info, err := os.Stat(bdb.db.Path()); err == nil {
+		set(info.Size(), "disk", "size_bytes")
+	}
 
 	// Freelist stats
+	set(stats.FreePageN, "freelist", "free_pages")
+	set(stats.PendingPageN, "freelist", "pending_pages")
+	set(stats.FreeAlloc, "freelist", "free_bytes")
+	set(stats.FreelistInuse, "freelist", "inuse_bytes")
+
+	// Transaction stats
+	set(stats.TxN, "txn", "read_count")
+	set(stats.OpenTxN, "txn", "open_read")
+	set(stats.TxStats.PageCount, "txn", "page_allocs")
+	set(stats.TxStats.PageAlloc, "txn", "page_alloc_bytes")
+	set(stats.TxStats.Write, "txn", "page_writes")
+
+	// Keys reported before StatsKey.
 	m["FreePageN"] = fmt.Sprintf("%v", stats.FreePageN)
 	m["PendingPageN"] = fmt.Sprintf("%v", stats.PendingPageN)
 	m["FreeAlloc"] = fmt.Sprintf("%v", stats.FreeAlloc)
 	m["FreelistInuse"] = fmt.Sprintf("%v", stats.FreelistInuse)
-
-	// Transaction stats
 	m["TxN"] = fmt.Sprintf("%v", stats.TxN)
 	m["OpenTxN"] = fmt.Sprintf("%v", stats.OpenTxN)
 
@@ -161,6 +457,9 @@ func (bdb *boltDB) Stats() map[string]string {
 
 // NewBatch implements DB.
 func (bdb *boltDB) NewBatch() locketdb.Batch {
+	if bdb.opts.ReadOnly {
+		return locketdb.NewReadOnlyBatch()
+	}
 	return newBoltDBBatch(bdb)
 }
 
@@ -170,11 +469,11 @@ func (bdb *boltDB) Iterator(start, end []byte) (locketdb.Iterator, error) {
 	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
 		return nil, locketdb.ErrKeyEmpty
 	}
-	tx, err := bdb.db.Begin(false)
+	tx, err := bdb.begin(false)
 	if err != nil {
 		return nil, err
 	}
-	return newBoltDBIterator(tx, start, end, false), nil
+	return newBoltDBIterator(tx, start, end, false, bdb), nil
 }
 
 // WARNING: Any concurrent writes or reads will block until the iterator is
@@ -183,9 +482,9 @@ func (bdb *boltDB) ReverseIterator(start, end []byte) (locketdb.Iterator, error)
 	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
 		return nil, locketdb.ErrKeyEmpty
 	}
-	tx, err := bdb.db.Begin(false)
+	tx, err := bdb.begin(false)
 	if err != nil {
 		return nil, err
 	}
-	return newBoltDBIterator(tx, start, end, true), nil
+	return newBoltDBIterator(tx, start, end, true, bdb), nil
 }
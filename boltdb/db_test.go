@@ -0,0 +1,349 @@
+package boltdb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/meission/locketdb"
+	"github.com/meission/locketdb/locketdbtest"
+	"go.etcd.io/bbolt"
+)
+
+func TestConformance(t *testing.T) {
+	dir := t.TempDir()
+	n := 0
+	locketdbtest.RunConformance(t, func() locketdb.DB {
+		n++
+		db, err := NewDB(fmt.Sprintf("test%d", n), dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return db
+	}, "Snapshot") // writes while holding a snapshot, see TestSnapshot
+}
+
+func TestSnapshot(t *testing.T) {
+	// A write that grows the memory map waits for the open read transactions, so the snapshot
+	// must not be held by the writing goroutine unless the map is large enough up front.
+	opts := *bbolt.DefaultOptions
+	opts.InitialMmapSize = 1 << 24
+	db, err := NewDBWithOpts("test", t.TempDir(), &opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if err := db.Set([]byte("a"), []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	snap, err := locketdb.NewSnapshot(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := make([]byte, 4096)
+	for i := 0; i < 1000; i++ {
+		if err := db.Set([]byte(fmt.Sprintf("k%04d", i)), value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Set([]byte("a"), []byte("2")); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, err := snap.Get([]byte("a")); err != nil || string(v) != "1" {
+		t.Errorf("snapshot Get(a) = %q, %v; want %q", v, err, "1")
+	}
+	itr, err := snap.Iterator(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for ; itr.Valid(); itr.Next() {
+		n++
+	}
+	itr.Close()
+	if n != 1 {
+		t.Errorf("snapshot iterated %d keys, want 1", n)
+	}
+	if err := snap.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := db.Get([]byte("a")); err != nil || string(v) != "2" {
+		t.Errorf("Get(a) = %q, %v; want %q", v, err, "2")
+	}
+}
+
+func TestSnapshotBlocksGrowingWrites(t *testing.T) {
+	db, err := NewDB("test", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	snap, err := locketdb.NewSnapshot(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		value := make([]byte, 4096)
+		for i := 0; i < 100; i++ {
+			if err := db.Set([]byte(fmt.Sprintf("k%04d", i)), value); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("writes growing the file completed while a snapshot was open: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if err := snap.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStats(t *testing.T) {
+	db, err := NewDB("test", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.Set([]byte("key"), []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := db.Stats()
+	for _, key := range []string{"bbolt.disk.size_bytes", "bbolt.freelist.free_pages", "bbolt.txn.read_count",
+		"FreePageN", "PendingPageN", "FreeAlloc", "FreelistInuse", "TxN", "OpenTxN"} {
+		if _, ok := stats[key]; !ok {
+			t.Errorf("Stats() is missing %q", key)
+		}
+	}
+}
+
+func TestOpenLocked(t *testing.T) {
+	dir := t.TempDir()
+	db, err := OpenWithOptions("test", dir, locketdb.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// The file lock is held per open file, so a second open conflicts as another process would.
+	start := time.Now()
+	_, err = OpenWithOptions("test", dir, locketdb.Options{ReadOnly: true})
+	if !errors.Is(err, bbolt.ErrTimeout) || !strings.Contains(err.Error(), "locked by another process") {
+		t.Fatalf("opening a locked database read-only: got %v, want a lock timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*lockTimeout {
+		t.Errorf("opening a locked database took %v", elapsed)
+	}
+}
+
+func TestMergeConformance(t *testing.T) {
+	dir := t.TempDir()
+	n := 0
+	locketdbtest.RunMergeConformance(t, func(op locketdb.MergeOperator) locketdb.DB {
+		n++
+		db, err := NewDB(fmt.Sprintf("test%d", n), dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mdb, err := locketdb.NewMergeDB(db, op)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return mdb
+	}, "MergeSnapshot") // writes while holding a snapshot, see TestSnapshot
+}
+
+func TestMergeCompactGrowsFile(t *testing.T) {
+	db, err := OpenWithOptions("test", t.TempDir(),
+		locketdb.Options{MergeOperator: locketdb.Uint64Add})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// Folding an operand per key writes as many values, more than the file has room for, which
+	// must not be held back by reading the operands.
+	const n = 20000
+	operand := locketdb.EncodeUint64(1)
+	for i := 0; i < n; i++ {
+		if err := locketdb.Merge(db, []byte(fmt.Sprintf("key%05d", i)), operand); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := locketdb.Compact(db, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, i := range []int{0, n / 2, n - 1} {
+		value, err := db.Get([]byte(fmt.Sprintf("key%05d", i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(value, operand) {
+			t.Errorf("key%05d: expected %x, got %x", i, operand, value)
+		}
+	}
+}
+
+func TestCompactShrinksFile(t *testing.T) {
+	dir := t.TempDir()
+	db, err := NewDB("test", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	value := make([]byte, 4096)
+	for i := 0; i < 1000; i++ {
+		if err := db.Set([]byte(fmt.Sprintf("%04d", i)), value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := locketdb.DeleteRange(db, []byte("0010"), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "test.db")
+	before, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := locketdb.Compact(db, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	after, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.Size() >= before.Size() {
+		t.Errorf("file size after Compact = %d, want less than %d", after.Size(), before.Size())
+	}
+	if ok, err := db.Has([]byte("0009")); err != nil || !ok {
+		t.Errorf("Has(0009) after Compact = %v, %v; want true", ok, err)
+	}
+}
+
+func TestCompactConcurrentWrites(t *testing.T) {
+	db, err := NewDB("test", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	done := make(chan struct{})
+	written := make(chan int)
+	go func() {
+		n := 0
+		defer func() { written <- n }()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if err := db.Set([]byte(fmt.Sprintf("k%06d", n)), []byte("v")); err != nil {
+				t.Error(err)
+				return
+			}
+			itr, err := db.Iterator(nil, nil)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			itr.Close()
+			n++
+		}
+	}()
+	for i := 0; i < 5; i++ {
+		time.Sleep(10 * time.Millisecond)
+		if err := locketdb.Compact(db, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(done)
+	n := <-written
+
+	for i := 0; i < n; i++ {
+		if ok, err := db.Has([]byte(fmt.Sprintf("k%06d", i))); err != nil || !ok {
+			t.Fatalf("write %d of %d lost by Compact: %v, %v", i, n, ok, err)
+		}
+	}
+}
+
+func TestCompactWaitsForSnapshots(t *testing.T) {
+	db, err := NewDB("test", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.Set([]byte("a"), []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+
+	snap, err := locketdb.NewSnapshot(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compacted := make(chan error, 1)
+	go func() {
+		compacted <- locketdb.Compact(db, nil, nil)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	// The goroutine holding the snapshot can still use the database while Compact waits.
+	if v, err := db.Get([]byte("a")); err != nil || string(v) != "1" {
+		t.Fatalf("Get(a) = %q, %v; want %q", v, err, "1")
+	}
+	select {
+	case err := <-compacted:
+		t.Fatalf("Compact returned while a snapshot was open: %v", err)
+	default:
+	}
+	if err := snap.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-compacted; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	db, err := NewDB("test", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, key := range []string{"a", "b"} {
+		if err := db.Set([]byte(key), []byte("v")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	itr, err := db.Iterator(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer itr.Close()
+
+	m := locketdb.MetricsOf(db)
+	if m.LiveKeys.Known || m.OpenTxns != locketdb.KnownMetric(1) {
+		t.Errorf("LiveKeys = %v, OpenTxns = %v, want unknown and the read transaction of the iterator", m.LiveKeys, m.OpenTxns)
+	}
+	if !m.DiskBytes.Known || m.DiskBytes.Value == 0 || m.CacheHits.Known {
+		t.Errorf("DiskBytes = %v, CacheHits = %v, want known and unknown", m.DiskBytes, m.CacheHits)
+	}
+}
@@ -0,0 +1,49 @@
+package boltdb
+
+import (
+	"fmt"
+
+	"github.com/meission/locketdb"
+	"go.etcd.io/bbolt"
+)
+
+// OpenDSN opens a database from a DSN, see locketdb.Open. The supported options are:
+//
+//	sync               sync every commit to disk (boolean, default true)
+//	no_grow_sync       skip the sync when growing the file (boolean)
+//	no_freelist_sync   do not persist the freelist (boolean)
+//	freelist_type      "array" (default) or "map"
+//	initial_mmap_size  initial size of the memory map (size)
+//	timeout            how long to wait for the file lock (duration)
+func OpenDSN(name string, dir string, params *locketdb.DSNParams) (locketdb.DB, error) {
+	if name == "" {
+		return nil, fmt.Errorf("DSN must include the database path")
+	}
+	opts := *bbolt.DefaultOptions
+	var (
+		sync         = !opts.NoSync
+		freelistType string
+		mmapSize     int64
+	)
+	params.Bool("sync", &sync)
+	params.Bool("no_grow_sync", &opts.NoGrowSync)
+	params.Bool("no_freelist_sync", &opts.NoFreelistSync)
+	params.String("freelist_type", &freelistType)
+	params.Size("initial_mmap_size", &mmapSize)
+	params.Duration("timeout", &opts.Timeout)
+	if err := params.Err(); err != nil {
+		return nil, err
+	}
+
+	opts.NoSync = !sync
+	opts.InitialMmapSize = int(mmapSize)
+	switch freelistType {
+	case "":
+	case string(bbolt.FreelistArrayType), string(bbolt.FreelistMapType):
+		opts.FreelistType = bbolt.FreelistType(freelistType)
+	default:
+		return nil, fmt.Errorf("invalid value %q for %s option %q, expected %s or %s",
+			freelistType, locketdb.BoltDB, "freelist_type", bbolt.FreelistArrayType, bbolt.FreelistMapType)
+	}
+	return NewDBWithOpts(name, dir, &opts)
+}
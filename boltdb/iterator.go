@@ -11,6 +11,9 @@ import (
 // start / end keys (nil & nil will result in doing full scan).
 type boltDBIterator struct {
 	tx *bbolt.Tx
+	// owner is set when the iterator must roll back tx on Close and release it, i.e. when it was
+	// not obtained from a snapshot or a transaction.
+	owner *boltDB
 
 	iter  *bbolt.Cursor
 	start []byte
@@ -23,40 +26,21 @@ type boltDBIterator struct {
 	isReverse bool
 }
 
-var _ locketdb.Iterator = (*boltDBIterator)(nil)
+var _ locketdb.SeekIterator = (*boltDBIterator)(nil)
 
 // newBoltDBIterator creates a new boltDBIterator.
-func newBoltDBIterator(tx *bbolt.Tx, start, end []byte, isReverse bool) *boltDBIterator {
-	iter := tx.Bucket(bucket).Cursor()
-
-	var ck, cv []byte
-	if isReverse {
-		switch {
-		case end == nil:
-			ck, cv = iter.Last()
-		default:
-			_, _ = iter.Seek(end) // after key
-			ck, cv = iter.Prev()  // return to end key
-		}
-	} else {
-		switch {
-		case start == nil:
-			ck, cv = iter.First()
-		default:
-			ck, cv = iter.Seek(start)
-		}
-	}
-
-	return &boltDBIterator{
-		tx:           tx,
-		iter:         iter,
-		start:        start,
-		end:          end,
-		currentKey:   ck,
-		currentValue: cv,
-		isReverse:    isReverse,
-		isInvalid:    false,
+func newBoltDBIterator(tx *bbolt.Tx, start, end []byte, isReverse bool, owner *boltDB) *boltDBIterator {
+	iter := &boltDBIterator{
+		tx:        tx,
+		owner:     owner,
+		iter:      tx.Bucket(bucket).Cursor(),
+		start:     start,
+		end:       end,
+		isReverse: isReverse,
+		isInvalid: false,
 	}
+	iter.First()
+	return iter
 }
 
 // Domain implements Iterator.
@@ -81,16 +65,10 @@ func (iter *boltDBIterator) Valid() bool {
 		return false
 	}
 
-	if iter.isReverse {
-		if iter.start != nil && bytes.Compare(iter.currentKey, iter.start) < 0 {
-			iter.isInvalid = true
-			return false
-		}
-	} else {
-		if iter.end != nil && bytes.Compare(iter.end, iter.currentKey) <= 0 {
-			iter.isInvalid = true
-			return false
-		}
+	// Prev and Seek may move past either bound.
+	if !locketdb.IsKeyInDomain(iter.currentKey, iter.start, iter.end) {
+		iter.isInvalid = true
+		return false
 	}
 	return true
 }
@@ -105,6 +83,80 @@ func (iter *boltDBIterator) Next() {
 	}
 }
 
+// Prev implements SeekIterator.
+func (iter *boltDBIterator) Prev() {
+	iter.assertIsValid()
+	if iter.isReverse {
+		iter.currentKey, iter.currentValue = iter.iter.Next()
+	} else {
+		iter.currentKey, iter.currentValue = iter.iter.Prev()
+	}
+}
+
+// Seek implements SeekIterator.
+func (iter *boltDBIterator) Seek(key []byte) {
+	iter.isInvalid = false
+	if !iter.isReverse {
+		if iter.start != nil && bytes.Compare(key, iter.start) < 0 {
+			key = iter.start
+		}
+		iter.currentKey, iter.currentValue = iter.iter.Seek(key)
+		return
+	}
+	if iter.end != nil && bytes.Compare(key, iter.end) >= 0 {
+		iter.currentKey, iter.currentValue = iter.seekEnd()
+		return
+	}
+	// The greatest key <= key is either key itself or the one before the first key after it.
+	ck, cv := iter.iter.Seek(key)
+	switch {
+	case ck == nil:
+		ck, cv = iter.iter.Last()
+	case !bytes.Equal(ck, key):
+		ck, cv = iter.iter.Prev()
+	}
+	iter.currentKey, iter.currentValue = ck, cv
+}
+
+// First implements SeekIterator.
+func (iter *boltDBIterator) First() {
+	iter.isInvalid = false
+	if iter.isReverse {
+		iter.currentKey, iter.currentValue = iter.seekEnd()
+	} else {
+		iter.currentKey, iter.currentValue = iter.seekStart()
+	}
+}
+
+// Last implements SeekIterator.
+func (iter *boltDBIterator) Last() {
+	iter.isInvalid = false
+	if iter.isReverse {
+		iter.currentKey, iter.currentValue = iter.seekStart()
+	} else {
+		iter.currentKey, iter.currentValue = iter.seekEnd()
+	}
+}
+
+// seekStart moves the cursor to the smallest key of the domain.
+func (iter *boltDBIterator) seekStart() ([]byte, []byte) {
+	if iter.start == nil {
+		return iter.iter.First()
+	}
+	return iter.iter.Seek(iter.start)
+}
+
+// seekEnd moves the cursor to the greatest key of the domain.
+func (iter *boltDBIterator) seekEnd() ([]byte, []byte) {
+	if iter.end == nil {
+		return iter.iter.Last()
+	}
+	if ck, _ := iter.iter.Seek(iter.end); ck == nil {
+		return iter.iter.Last()
+	}
+	return iter.iter.Prev()
+}
+
 // Key implements Iterator.
 func (iter *boltDBIterator) Key() []byte {
 	iter.assertIsValid()
@@ -128,7 +180,14 @@ func (iter *boltDBIterator) Error() error {
 
 // Close implements Iterator.
 func (iter *boltDBIterator) Close() error {
-	return iter.tx.Rollback()
+	if iter.owner == nil {
+		return nil
+	}
+	err := iter.tx.Rollback()
+	if err == nil {
+		iter.owner.release()
+	}
+	return err
 }
 
 func (iter *boltDBIterator) assertIsValid() {
@@ -0,0 +1,73 @@
+package boltdb
+
+import (
+	"github.com/meission/locketdb"
+	"go.etcd.io/bbolt"
+)
+
+// boltDBSnapshot is a read-only bbolt transaction. Like any bbolt transaction it must not be
+// used from several goroutines at once.
+//
+// WARNING: bbolt cannot grow the memory map while a read transaction is open, so writes that
+// need to grow the database file will block until the snapshot is closed. Writing from the
+// goroutine holding the snapshot can therefore deadlock unless bbolt.Options.InitialMmapSize is
+// large enough for the data written in the meantime.
+type boltDBSnapshot struct {
+	db *boltDB
+	tx *bbolt.Tx
+}
+
+var _ locketdb.Snapshot = (*boltDBSnapshot)(nil)
+
+// NewSnapshot implements Snapshotter.
+func (bdb *boltDB) NewSnapshot() (locketdb.Snapshot, error) {
+	tx, err := bdb.begin(false)
+	if err != nil {
+		return nil, err
+	}
+	return &boltDBSnapshot{db: bdb, tx: tx}, nil
+}
+
+// Get implements Snapshot.
+func (s *boltDBSnapshot) Get(key []byte) ([]byte, error) {
+	if len(key) == 0 {
+		return nil, locketdb.ErrKeyEmpty
+	}
+	if v := s.tx.Bucket(bucket).Get(key); v != nil {
+		return append([]byte{}, v...), nil
+	}
+	return nil, nil
+}
+
+// Has implements Snapshot.
+func (s *boltDBSnapshot) Has(key []byte) (bool, error) {
+	if len(key) == 0 {
+		return false, locketdb.ErrKeyEmpty
+	}
+	return s.tx.Bucket(bucket).Get(key) != nil, nil
+}
+
+// Iterator implements Snapshot.
+func (s *boltDBSnapshot) Iterator(start, end []byte) (locketdb.Iterator, error) {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return nil, locketdb.ErrKeyEmpty
+	}
+	return newBoltDBIterator(s.tx, start, end, false, nil), nil
+}
+
+// ReverseIterator implements Snapshot.
+func (s *boltDBSnapshot) ReverseIterator(start, end []byte) (locketdb.Iterator, error) {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return nil, locketdb.ErrKeyEmpty
+	}
+	return newBoltDBIterator(s.tx, start, end, true, nil), nil
+}
+
+// Close implements Snapshot.
+func (s *boltDBSnapshot) Close() error {
+	err := s.tx.Rollback()
+	if err == nil {
+		s.db.release()
+	}
+	return err
+}
@@ -0,0 +1,138 @@
+package boltdb
+
+import (
+	"github.com/meission/locketdb"
+	"go.etcd.io/bbolt"
+)
+
+// boltDBTxn is a native bbolt transaction. bbolt allows a single writable transaction at a time,
+// so writable transactions never conflict: they hold the database write lock until they are
+// committed or rolled back.
+//
+// WARNING: Any other write, including one made directly on the DB, blocks until a writable
+// transaction ends. Writing to the DB from the goroutine holding the transaction deadlocks.
+type boltDBTxn struct {
+	db *boltDB
+	// tx is set to nil once the transaction has been committed or rolled back.
+	tx *bbolt.Tx
+}
+
+var _ locketdb.Txn = (*boltDBTxn)(nil)
+
+// Begin implements Transactional.
+func (bdb *boltDB) Begin(writable bool) (locketdb.Txn, error) {
+	if writable && bdb.opts.ReadOnly {
+		return nil, locketdb.ErrReadOnly
+	}
+	tx, err := bdb.begin(writable)
+	if err != nil {
+		return nil, err
+	}
+	return &boltDBTxn{db: bdb, tx: tx}, nil
+}
+
+// Get implements Txn.
+func (t *boltDBTxn) Get(key []byte) ([]byte, error) {
+	if len(key) == 0 {
+		return nil, locketdb.ErrKeyEmpty
+	}
+	if t.tx == nil {
+		return nil, locketdb.ErrTxnClosed
+	}
+	if v := t.tx.Bucket(bucket).Get(key); v != nil {
+		return append([]byte{}, v...), nil
+	}
+	return nil, nil
+}
+
+// Has implements Txn.
+func (t *boltDBTxn) Has(key []byte) (bool, error) {
+	bytes, err := t.Get(key)
+	if err != nil {
+		return false, err
+	}
+	return bytes != nil, nil
+}
+
+// Set implements Txn.
+func (t *boltDBTxn) Set(key, value []byte) error {
+	if len(key) == 0 {
+		return locketdb.ErrKeyEmpty
+	}
+	if value == nil {
+		return locketdb.ErrValueNil
+	}
+	if err := t.checkWritable(); err != nil {
+		return err
+	}
+	return t.tx.Bucket(bucket).Put(key, value)
+}
+
+// Delete implements Txn.
+func (t *boltDBTxn) Delete(key []byte) error {
+	if len(key) == 0 {
+		return locketdb.ErrKeyEmpty
+	}
+	if err := t.checkWritable(); err != nil {
+		return err
+	}
+	return t.tx.Bucket(bucket).Delete(key)
+}
+
+func (t *boltDBTxn) checkWritable() error {
+	if t.tx == nil {
+		return locketdb.ErrTxnClosed
+	}
+	if !t.tx.Writable() {
+		return locketdb.ErrTxnReadOnly
+	}
+	return nil
+}
+
+// Iterator implements Txn.
+func (t *boltDBTxn) Iterator(start, end []byte) (locketdb.Iterator, error) {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return nil, locketdb.ErrKeyEmpty
+	}
+	if t.tx == nil {
+		return nil, locketdb.ErrTxnClosed
+	}
+	return newBoltDBIterator(t.tx, start, end, false, nil), nil
+}
+
+// ReverseIterator implements Txn.
+func (t *boltDBTxn) ReverseIterator(start, end []byte) (locketdb.Iterator, error) {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return nil, locketdb.ErrKeyEmpty
+	}
+	if t.tx == nil {
+		return nil, locketdb.ErrTxnClosed
+	}
+	return newBoltDBIterator(t.tx, start, end, true, nil), nil
+}
+
+// Commit implements Txn.
+func (t *boltDBTxn) Commit() error {
+	if t.tx == nil {
+		return locketdb.ErrTxnClosed
+	}
+	tx := t.tx
+	t.tx = nil
+	defer t.db.release()
+	if !tx.Writable() {
+		// Read-only bbolt transactions cannot be committed, only released.
+		return tx.Rollback()
+	}
+	return tx.Commit()
+}
+
+// Rollback implements Txn.
+func (t *boltDBTxn) Rollback() error {
+	if t.tx == nil {
+		return nil
+	}
+	tx := t.tx
+	t.tx = nil
+	defer t.db.release()
+	return tx.Rollback()
+}
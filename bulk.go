@@ -0,0 +1,90 @@
+package locketdb
+
+// defaultBulkBatchSize bounds the size of the keys and values of each batch written by Restore,
+// and by Migrate unless configured otherwise.
+const defaultBulkBatchSize = 4 << 20
+
+// rangeReader is the read interface shared by DB and Snapshot.
+type rangeReader interface {
+	Iterator(start, end []byte) (Iterator, error)
+	ReverseIterator(start, end []byte) (Iterator, error)
+}
+
+// consistentView returns a snapshot of db if it can provide one, or db itself otherwise, and a
+// function releasing it. Wrappers such as PrefixDB are Snapshotters whatever the database they
+// wrap, so ErrNotSupported also falls back to db.
+func consistentView(db DB) (rangeReader, func() error, error) {
+	snap, err := NewSnapshot(db)
+	if err == ErrNotSupported {
+		return db, func() error { return nil }, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return snap, snap.Close, nil
+}
+
+// bulkWriter writes keys to a database through a sequence of batches, each holding up to
+// batchSize bytes of keys and values.
+type bulkWriter struct {
+	db        DB
+	batchSize int
+	// flushed, if not nil, is called after each batch is written.
+	flushed func()
+
+	batch Batch
+	size  int
+}
+
+func newBulkWriter(db DB, batchSize int) *bulkWriter {
+	if batchSize <= 0 {
+		batchSize = defaultBulkBatchSize
+	}
+	return &bulkWriter{db: db, batchSize: batchSize}
+}
+
+// set queues a key, writing the current batch once it is full.
+func (bw *bulkWriter) set(key, value []byte) error {
+	if bw.batch == nil {
+		bw.batch = bw.db.NewBatch()
+	}
+	if err := bw.batch.Set(key, value); err != nil {
+		return err
+	}
+	bw.size += len(key) + len(value)
+	if bw.size >= bw.batchSize {
+		return bw.flush(false)
+	}
+	return nil
+}
+
+// flush writes the current batch, if any.
+func (bw *bulkWriter) flush(sync bool) error {
+	if bw.batch == nil {
+		return nil
+	}
+	var err error
+	if sync {
+		err = bw.batch.WriteSync()
+	} else {
+		err = bw.batch.Write()
+	}
+	bw.batch.Close()
+	bw.batch = nil
+	bw.size = 0
+	if err != nil {
+		return err
+	}
+	if bw.flushed != nil {
+		bw.flushed()
+	}
+	return nil
+}
+
+// close discards the current batch, if any.
+func (bw *bulkWriter) close() {
+	if bw.batch != nil {
+		bw.batch.Close()
+		bw.batch = nil
+	}
+}
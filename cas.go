@@ -0,0 +1,130 @@
+package locketdb
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"sync"
+)
+
+// ConditionalWriter is implemented by databases that can write a key depending on its current
+// value, atomically, which is enough to build leases, locks and counters without transactions.
+//
+// When the condition does not hold, nothing is written and the methods return false with a
+// *ConflictError, which matches ErrConflict with errors.Is.
+type ConditionalWriter interface {
+	// CompareAndSwap sets key to new if its current value is expected. A nil expected requires
+	// the key not to exist, and a nil new deletes the key.
+	// CONTRACT: key, expected, new readonly []byte
+	CompareAndSwap(key, expected, new []byte) (bool, error)
+
+	// SetIfAbsent sets key to value if the key does not exist.
+	// CONTRACT: key, value readonly []byte
+	SetIfAbsent(key, value []byte) (bool, error)
+}
+
+// ConflictError is returned by conditional writes whose condition does not hold.
+type ConflictError struct {
+	Key []byte
+	// Current is the value found instead of the expected one, nil if the key does not exist.
+	Current []byte
+}
+
+// Error implements error.
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%v: key %X", ErrConflict, e.Key)
+}
+
+// Is makes errors.Is(err, ErrConflict) report true for a *ConflictError.
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+// CompareAndSwap sets key to new in db if its current value is expected, or returns
+// ErrNotSupported if the backend cannot write conditionally. See ConditionalWriter.
+func CompareAndSwap(db DB, key, expected, new []byte) (bool, error) {
+	cw, ok := db.(ConditionalWriter)
+	if !ok {
+		return false, ErrNotSupported
+	}
+	return cw.CompareAndSwap(key, expected, new)
+}
+
+// SetIfAbsent sets key to value in db if the key does not exist, or returns ErrNotSupported if
+// the backend cannot write conditionally. See ConditionalWriter.
+func SetIfAbsent(db DB, key, value []byte) (bool, error) {
+	cw, ok := db.(ConditionalWriter)
+	if !ok {
+		return false, ErrNotSupported
+	}
+	return cw.SetIfAbsent(key, value)
+}
+
+// CheckExpected returns a *ConflictError unless current, the value of key or nil if it does not
+// exist, is expected as understood by CompareAndSwap. It is meant for backends implementing
+// ConditionalWriter.
+func CheckExpected(key, current, expected []byte) error {
+	if (current == nil) != (expected == nil) || !bytes.Equal(current, expected) {
+		conflict := &ConflictError{Key: cp(key)}
+		if current != nil {
+			conflict.Current = cp(current)
+		}
+		return conflict
+	}
+	return nil
+}
+
+// keyLockStripes is the number of mutexes of a KeyLocks.
+const keyLockStripes = 256
+
+// KeyLocks is a striped lock manager, guarding each key with one of a fixed set of mutexes
+// selected by its hash. Unrelated keys may share a mutex, so a goroutine must not lock two keys
+// at once. The zero value is ready to use.
+type KeyLocks struct {
+	stripes [keyLockStripes]sync.Mutex
+}
+
+func (l *KeyLocks) stripe(key []byte) *sync.Mutex {
+	h := fnv.New32a()
+	h.Write(key)
+	return &l.stripes[h.Sum32()%keyLockStripes]
+}
+
+// Lock locks the mutex guarding key.
+func (l *KeyLocks) Lock(key []byte) {
+	l.stripe(key).Lock()
+}
+
+// Unlock unlocks the mutex guarding key.
+func (l *KeyLocks) Unlock(key []byte) {
+	l.stripe(key).Unlock()
+}
+
+// CompareAndSwapLocked implements CompareAndSwap for backends without transactions, by reading
+// and writing key through db while holding its lock in locks. Backends should use a single
+// KeyLocks per database.
+//
+// The swap is atomic with respect to the other conditional writes sharing locks. Plain writes to
+// the same key racing with it are not ordered, and may be overwritten.
+func CompareAndSwapLocked(db DB, locks *KeyLocks, key, expected, new []byte) (bool, error) {
+	if len(key) == 0 {
+		return false, ErrKeyEmpty
+	}
+	locks.Lock(key)
+	defer locks.Unlock(key)
+
+	current, err := db.Get(key)
+	if err != nil {
+		return false, err
+	}
+	if err := CheckExpected(key, current, expected); err != nil {
+		return false, err
+	}
+	switch {
+	case new != nil:
+		err = db.Set(key, new)
+	case expected != nil:
+		err = db.Delete(key)
+	}
+	return err == nil, err
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+
+	"github.com/meission/locketdb"
+)
+
+func runGet(e *env, args []string) error {
+	fs := e.newFlagSet()
+	if err := e.parse(fs, args, 1); err != nil {
+		return err
+	}
+	key, err := e.keyEnc.decode(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("invalid key: %w", err)
+	}
+
+	db, err := e.open(true)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	value, err := db.Get(key)
+	if err != nil {
+		return err
+	}
+	if value == nil {
+		return locketdb.ErrKeyNotFound
+	}
+	fmt.Fprintln(e.stdout, e.valueEnc.encode(value))
+	return nil
+}
+
+func runSet(e *env, args []string) error {
+	fs := e.newFlagSet()
+	if err := e.parse(fs, args, 2); err != nil {
+		return err
+	}
+	key, err := e.keyEnc.decode(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("invalid key: %w", err)
+	}
+	value, err := e.valueEnc.decode(fs.Arg(1))
+	if err != nil {
+		return fmt.Errorf("invalid value: %w", err)
+	}
+
+	db, err := e.open(false)
+	if err != nil {
+		return err
+	}
+	if err := db.SetSync(key, value); err != nil {
+		db.Close()
+		return err
+	}
+	return db.Close()
+}
+
+func runDelete(e *env, args []string) error {
+	fs := e.newFlagSet()
+	if err := e.parse(fs, args, 1); err != nil {
+		return err
+	}
+	key, err := e.keyEnc.decode(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("invalid key: %w", err)
+	}
+
+	db, err := e.open(false)
+	if err != nil {
+		return err
+	}
+	if err := db.DeleteSync(key); err != nil {
+		db.Close()
+		return err
+	}
+	return db.Close()
+}
+
+// rangeFlags are the flags selecting the domain of scan and count.
+type rangeFlags struct {
+	start, end string
+}
+
+func (r *rangeFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&r.start, "start", "", "first `key` of the range (inclusive), the first key of the database if empty")
+	fs.StringVar(&r.end, "end", "", "last `key` of the range (exclusive), past the last key of the database if empty")
+}
+
+// domain decodes the bounds of the range, an empty flag giving a nil bound.
+func (r *rangeFlags) domain(enc encoding) (start, end []byte, err error) {
+	if r.start != "" {
+		if start, err = enc.decode(r.start); err != nil {
+			return nil, nil, fmt.Errorf("invalid start key: %w", err)
+		}
+	}
+	if r.end != "" {
+		if end, err = enc.decode(r.end); err != nil {
+			return nil, nil, fmt.Errorf("invalid end key: %w", err)
+		}
+	}
+	return start, end, nil
+}
+
+func runScan(e *env, args []string) error {
+	var (
+		r       rangeFlags
+		reverse bool
+		limit   int
+	)
+	fs := e.newFlagSet()
+	r.register(fs)
+	fs.BoolVar(&reverse, "reverse", false, "scan in descending order")
+	fs.IntVar(&limit, "limit", 0, "maximum number of keys to print, 0 for no limit")
+	if err := e.parse(fs, args, 0); err != nil {
+		return err
+	}
+	start, end, err := r.domain(e.keyEnc)
+	if err != nil {
+		return err
+	}
+
+	db, err := e.open(true)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var itr locketdb.Iterator
+	if reverse {
+		itr, err = db.ReverseIterator(start, end)
+	} else {
+		itr, err = db.Iterator(start, end)
+	}
+	if err != nil {
+		return err
+	}
+	defer itr.Close()
+
+	for n := 0; itr.Valid() && (limit <= 0 || n < limit); n++ {
+		fmt.Fprintf(e.stdout, "%s\t%s\n", e.keyEnc.encode(itr.Key()), e.valueEnc.encode(itr.Value()))
+		itr.Next()
+	}
+	return itr.Error()
+}
+
+func runCount(e *env, args []string) error {
+	var r rangeFlags
+	fs := e.newFlagSet()
+	r.register(fs)
+	if err := e.parse(fs, args, 0); err != nil {
+		return err
+	}
+	start, end, err := r.domain(e.keyEnc)
+	if err != nil {
+		return err
+	}
+
+	db, err := e.open(true)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	itr, err := db.Iterator(start, end)
+	if err != nil {
+		return err
+	}
+	defer itr.Close()
+
+	n := 0
+	for ; itr.Valid(); itr.Next() {
+		n++
+	}
+	if err := itr.Error(); err != nil {
+		return err
+	}
+	fmt.Fprintln(e.stdout, n)
+	return nil
+}
+
+func runStats(e *env, args []string) error {
+	fs := e.newFlagSet()
+	if err := e.parse(fs, args, 0); err != nil {
+		return err
+	}
+
+	db, err := e.open(true)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	stats := db.Stats()
+	keys := make([]string, 0, len(stats))
+	for k := range stats {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(e.stdout, "%s: %s\n", k, stats[k])
+	}
+	return nil
+}
+
+func runMigrate(e *env, args []string) error {
+	var (
+		to        env
+		batchSize string
+		opts      locketdb.MigrateOptions
+	)
+	fs := e.newFlagSet()
+	fs.StringVar(&to.kvType, "to-type", "", "backend `kvType` of the destination")
+	fs.StringVar(&to.dir, "to-dir", ".", "`directory` holding the destination")
+	fs.StringVar(&to.name, "to-name", "", "destination database `name`")
+	fs.StringVar(&batchSize, "batch-size", "4MB", "maximum `size` of the keys and values of each batch")
+	fs.BoolVar(&opts.Resume, "resume", false, "continue an interrupted migration after the last key of the destination")
+	fs.BoolVar(&opts.Verify, "verify", true, "compare both databases once copied")
+	if err := e.parse(fs, args, 0); err != nil {
+		return err
+	}
+	if to.kvType == "" || to.name == "" {
+		return e.usageErr(fs, "-to-type and -to-name are required")
+	}
+	size, err := locketdb.ParseSize(batchSize)
+	if err != nil {
+		return e.usageErr(fs, "invalid -batch-size %q: %v", batchSize, err)
+	}
+	opts.BatchSize = int(size)
+	opts.Progress = func(p locketdb.MigrateProgress) {
+		fmt.Fprintf(e.stderr, "copied %d keys, %d bytes, up to %s\n", p.Keys, p.Bytes, e.keyEnc.encode(p.LastKey))
+	}
+
+	src, err := e.open(true)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := to.open(false)
+	if err != nil {
+		return err
+	}
+	if err := locketdb.Migrate(src, dst, opts); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// encoding converts keys and values between their command-line and stored forms.
+type encoding string
+
+const (
+	encodingUTF8   encoding = "utf8"
+	encodingHex    encoding = "hex"
+	encodingBase64 encoding = "base64"
+)
+
+var encodings = []encoding{encodingUTF8, encodingHex, encodingBase64}
+
+// String implements flag.Value.
+func (e *encoding) String() string {
+	return string(*e)
+}
+
+// Set implements flag.Value.
+func (e *encoding) Set(s string) error {
+	for _, enc := range encodings {
+		if strings.EqualFold(s, string(enc)) {
+			*e = enc
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown encoding %q, expected one of utf8,hex,base64", s)
+}
+
+// decode converts a command-line argument into bytes.
+func (e encoding) decode(s string) ([]byte, error) {
+	switch e {
+	case encodingHex:
+		return hex.DecodeString(s)
+	case encodingBase64:
+		return base64.StdEncoding.DecodeString(s)
+	default:
+		return []byte(s), nil
+	}
+}
+
+// encode converts bytes into their printed form.
+func (e encoding) encode(bz []byte) string {
+	switch e {
+	case encodingHex:
+		return hex.EncodeToString(bz)
+	case encodingBase64:
+		return base64.StdEncoding.EncodeToString(bz)
+	default:
+		return string(bz)
+	}
+}
@@ -0,0 +1,177 @@
+// Command locketctl inspects and edits locketdb databases of any registered backend.
+//
+// Usage:
+//
+//	locketctl -type <kvType> -dir <dir> -name <name> [flags] <command> [arguments]
+//
+// The commands are:
+//
+//	get <key>                 print the value of a key
+//	set <key> <value>         set the value of a key
+//	delete <key>              delete a key
+//	scan [flags]              print the keys and values of a range, one tab-separated pair per line
+//	count [flags]             print the number of keys in a range
+//	stats                     print the backend statistics
+//	migrate [flags]           copy every key into another database, see locketdb.Migrate
+//
+// Keys and values, both given and printed, are encoded as selected with -key-encoding and
+// -value-encoding: utf8 (the default), hex or base64. Read commands open the database read-only,
+// except a memdb database, which cannot be opened read-only and always starts empty.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/meission/locketdb"
+	_ "github.com/meission/locketdb/badgerdb"
+	_ "github.com/meission/locketdb/boltdb"
+	_ "github.com/meission/locketdb/goleveldb"
+	_ "github.com/meission/locketdb/memdb"
+	_ "github.com/meission/locketdb/pebble"
+)
+
+// errUsage is returned for invalid command lines, after the usage has been printed.
+var errUsage = errors.New("invalid usage")
+
+func main() {
+	err := run(os.Args[1:], os.Stdout, os.Stderr)
+	switch {
+	case err == nil:
+	case errors.Is(err, flag.ErrHelp):
+	case errors.Is(err, errUsage):
+		os.Exit(2)
+	default:
+		fmt.Fprintf(os.Stderr, "locketctl: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// env holds the global flags and the outputs shared by every command.
+type env struct {
+	kvType   string
+	dir      string
+	name     string
+	keyEnc   encoding
+	valueEnc encoding
+	// cmd is the command being run.
+	cmd command
+
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// open opens the database selected by the global flags. A read-only database must exist already.
+// memdb databases are never opened read-only, since they only exist while open.
+func (e *env) open(readOnly bool) (locketdb.DB, error) {
+	if locketdb.KVType(e.kvType) == locketdb.MemDB {
+		readOnly = false
+	}
+	return locketdb.NewDBWithOptions(e.name, locketdb.KVType(e.kvType), e.dir, locketdb.Options{
+		ReadOnly: readOnly,
+	})
+}
+
+type command struct {
+	name    string
+	args    string
+	summary string
+	run     func(e *env, args []string) error
+}
+
+var commands = []command{
+	{"get", "<key>", "print the value of a key", runGet},
+	{"set", "<key> <value>", "set the value of a key", runSet},
+	{"delete", "<key>", "delete a key", runDelete},
+	{"scan", "[flags]", "print the keys and values of a range", runScan},
+	{"count", "[flags]", "print the number of keys in a range", runCount},
+	{"stats", "", "print the backend statistics", runStats},
+	{"migrate", "-to-type <kvType> -to-dir <dir> -to-name <name> [flags]", "copy every key into another database", runMigrate},
+}
+
+func run(args []string, stdout, stderr io.Writer) error {
+	e := &env{
+		keyEnc:   encodingUTF8,
+		valueEnc: encodingUTF8,
+		stdout:   stdout,
+		stderr:   stderr,
+	}
+	fs := flag.NewFlagSet("locketctl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&e.kvType, "type", "", "backend `kvType`, one of golevel,pebble,bbolt,badger,memdb")
+	fs.StringVar(&e.dir, "dir", ".", "`directory` holding the database")
+	fs.StringVar(&e.name, "name", "", "database `name`")
+	fs.Var(&e.keyEnc, "key-encoding", "encoding of keys: utf8, hex or base64")
+	fs.Var(&e.valueEnc, "value-encoding", "encoding of values: utf8, hex or base64")
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: locketctl -type <kvType> -dir <dir> -name <name> [flags] <command> [arguments]\n\n")
+		fmt.Fprintf(stderr, "Commands:\n")
+		for _, c := range commands {
+			fmt.Fprintf(stderr, "  %-24s %s\n", strings.TrimSpace(c.name+" "+c.args), c.summary)
+		}
+		fmt.Fprintf(stderr, "\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return errUsage
+	}
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errUsage
+	}
+	if e.kvType == "" || e.name == "" {
+		fmt.Fprintf(stderr, "-type and -name are required\n")
+		fs.Usage()
+		return errUsage
+	}
+	name := fs.Arg(0)
+	for _, c := range commands {
+		if c.name == name {
+			e.cmd = c
+			return c.run(e, fs.Args()[1:])
+		}
+	}
+	fmt.Fprintf(stderr, "unknown command %q\n", name)
+	fs.Usage()
+	return errUsage
+}
+
+// usageErr prints the usage of a command and returns errUsage.
+func (e *env) usageErr(fs *flag.FlagSet, format string, args ...interface{}) error {
+	fmt.Fprintf(e.stderr, format+"\n", args...)
+	fs.Usage()
+	return errUsage
+}
+
+// newFlagSet returns the flag set of the command being run.
+func (e *env) newFlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet(e.cmd.name, flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(e.stderr, "Usage: locketctl [global flags] %s %s\n", e.cmd.name, e.cmd.args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parse parses the flags of a command and checks its number of positional arguments.
+func (e *env) parse(fs *flag.FlagSet, args []string, nargs int) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return errUsage
+	}
+	if fs.NArg() != nargs {
+		return e.usageErr(fs, "%s takes %d argument(s), got %d", fs.Name(), nargs, fs.NArg())
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/meission/locketdb"
+)
+
+// locketctl runs the command line args against a goleveldb database in dir, and returns its
+// standard output.
+func locketctl(t *testing.T, dir string, args ...string) (string, error) {
+	t.Helper()
+	var stdout bytes.Buffer
+	args = append([]string{"-type", string(locketdb.GoLevelDB), "-dir", dir, "-name", "test"}, args...)
+	err := run(args, &stdout, ioutil.Discard)
+	return stdout.String(), err
+}
+
+func TestCommands(t *testing.T) {
+	dir := t.TempDir()
+	for _, kv := range [][2]string{{"a", "1"}, {"b", "2"}, {"c", "3"}, {"d", "4"}} {
+		if _, err := locketctl(t, dir, "set", kv[0], kv[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := locketctl(t, dir, "delete", "d"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"get", "b"}, "2\n"},
+		{[]string{"-value-encoding", "hex", "get", "b"}, "32\n"},
+		{[]string{"-key-encoding", "base64", "get", "Yg=="}, "2\n"},
+		{[]string{"scan"}, "a\t1\nb\t2\nc\t3\n"},
+		{[]string{"scan", "-start", "b"}, "b\t2\nc\t3\n"},
+		{[]string{"scan", "-end", "c"}, "a\t1\nb\t2\n"},
+		{[]string{"scan", "-reverse", "-limit", "2"}, "c\t3\nb\t2\n"},
+		{[]string{"-key-encoding", "hex", "scan", "-start", "62", "-end", "63"}, "62\t2\n"},
+		{[]string{"count"}, "3\n"},
+		{[]string{"count", "-start", "b"}, "2\n"},
+	} {
+		got, err := locketctl(t, dir, tc.args...)
+		if err != nil {
+			t.Errorf("%v: %v", tc.args, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("%v: expected %q, got %q", tc.args, tc.want, got)
+		}
+	}
+
+	if _, err := locketctl(t, dir, "get", "d"); !errors.Is(err, locketdb.ErrKeyNotFound) {
+		t.Errorf("expected ErrKeyNotFound, got %v", err)
+	}
+	if out, err := locketctl(t, dir, "stats"); err != nil || out == "" {
+		t.Errorf("expected stats, got %q, %v", out, err)
+	}
+}
+
+func TestUsageErrors(t *testing.T) {
+	dir := t.TempDir()
+	for _, args := range [][]string{
+		{},
+		{"nosuchcommand"},
+		{"get"},
+		{"set", "a"},
+		{"scan", "extra"},
+		{"-key-encoding", "rot13", "get", "a"},
+	} {
+		if _, err := locketctl(t, dir, args...); !errors.Is(err, errUsage) {
+			t.Errorf("%v: expected errUsage, got %v", args, err)
+		}
+	}
+	if _, err := locketctl(t, dir, "-key-encoding", "hex", "get", "zz"); err == nil {
+		t.Error("expected an invalid hex key to fail")
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	dir := t.TempDir()
+	for _, k := range []string{"a", "b", "c"} {
+		if _, err := locketctl(t, dir, "set", k, "v"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := locketctl(t, dir, "migrate", "-to-type", string(locketdb.Pebble), "-to-dir", dir, "-to-name", "dst"); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout bytes.Buffer
+	args := []string{"-type", string(locketdb.Pebble), "-dir", dir, "-name", "dst", "count"}
+	if err := run(args, &stdout, ioutil.Discard); err != nil {
+		t.Fatal(err)
+	}
+	if got := stdout.String(); got != "3\n" {
+		t.Errorf("expected 3 keys in the destination, got %q", got)
+	}
+
+	if _, err := locketctl(t, dir, "migrate", "-to-type", string(locketdb.Pebble)); !errors.Is(err, errUsage) {
+		t.Errorf("expected errUsage without -to-name, got %v", err)
+	}
+}
+
+func TestMemDB(t *testing.T) {
+	for _, args := range [][]string{{"count"}, {"scan"}, {"stats"}} {
+		args = append([]string{"-type", string(locketdb.MemDB), "-name", "test"}, args...)
+		if err := run(args, ioutil.Discard, ioutil.Discard); err != nil {
+			t.Errorf("%v: %v", args, err)
+		}
+	}
+}
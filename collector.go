@@ -0,0 +1,221 @@
+package locketdb
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Op names an operation recorded by an InstrumentedDB.
+type Op string
+
+// These are the operations recorded by an InstrumentedDB.
+const (
+	OpGet             Op = "get"
+	OpHas             Op = "has"
+	OpSet             Op = "set"
+	OpSetSync         Op = "set_sync"
+	OpSetWithTTL      Op = "set_with_ttl"
+	OpCompareAndSwap  Op = "compare_and_swap"
+	OpSetIfAbsent     Op = "set_if_absent"
+	OpMerge           Op = "merge"
+	OpDelete          Op = "delete"
+	OpDeleteSync      Op = "delete_sync"
+	OpDeleteRange     Op = "delete_range"
+	OpCompact         Op = "compact"
+	OpIterator        Op = "iterator"
+	OpReverseIterator Op = "reverse_iterator"
+	OpBatchWrite      Op = "batch_write"
+	OpBatchWriteSync  Op = "batch_write_sync"
+)
+
+// Observation is an operation recorded by an InstrumentedDB.
+type Observation struct {
+	Op Op
+	// Namespace is the prefix of the PrefixDB the operation was made through, empty if none.
+	Namespace string
+	Duration  time.Duration
+	// Bytes is the size of the keys and values read or written by the operation.
+	Bytes int
+	Err   error
+}
+
+// Collector receives the operations recorded by an InstrumentedDB. Observe is called
+// concurrently, once each operation is done, and should return quickly.
+type Collector interface {
+	Observe(o Observation)
+}
+
+// DefaultLatencyBuckets are the upper bounds of the latency buckets of a HistogramCollector
+// created without buckets, from 50µs to 2.5s.
+var DefaultLatencyBuckets = []time.Duration{
+	50 * time.Microsecond, 100 * time.Microsecond, 250 * time.Microsecond, 500 * time.Microsecond,
+	time.Millisecond, 2500 * time.Microsecond, 5 * time.Millisecond, 10 * time.Millisecond,
+	25 * time.Millisecond, 50 * time.Millisecond, 100 * time.Millisecond, 250 * time.Millisecond,
+	500 * time.Millisecond, time.Second, 2500 * time.Millisecond,
+}
+
+// HistogramCollector is a Collector counting the operations, errors and bytes, and keeping a
+// histogram of the latencies, of each operation and namespace. It exports them in the Prometheus
+// text format, and serves them over HTTP, for instance on /metrics.
+type HistogramCollector struct {
+	buckets []time.Duration
+
+	mtx    sync.Mutex
+	series map[seriesKey]*OpSeries
+}
+
+var (
+	_ Collector    = (*HistogramCollector)(nil)
+	_ http.Handler = (*HistogramCollector)(nil)
+)
+
+type seriesKey struct {
+	op        Op
+	namespace string
+}
+
+// OpSeries holds what a HistogramCollector recorded for an operation and a namespace.
+type OpSeries struct {
+	Op        Op
+	Namespace string
+
+	Count  uint64
+	Errors uint64
+	Bytes  uint64
+
+	// Buckets counts, for each latency bucket of the collector, the operations that took at most
+	// its duration. Counts are cumulative, as in Prometheus histograms.
+	Buckets []uint64
+	// Sum is the total duration of the operations.
+	Sum time.Duration
+}
+
+// NewHistogramCollector returns a HistogramCollector with the given latency buckets, in
+// ascending order. No buckets uses DefaultLatencyBuckets.
+func NewHistogramCollector(buckets ...time.Duration) *HistogramCollector {
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+	return &HistogramCollector{
+		buckets: append([]time.Duration(nil), buckets...),
+		series:  make(map[seriesKey]*OpSeries),
+	}
+}
+
+// Observe implements Collector.
+func (c *HistogramCollector) Observe(o Observation) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	key := seriesKey{op: o.Op, namespace: o.Namespace}
+	s, ok := c.series[key]
+	if !ok {
+		s = &OpSeries{
+			Op:        o.Op,
+			Namespace: o.Namespace,
+			Buckets:   make([]uint64, len(c.buckets)),
+		}
+		c.series[key] = s
+	}
+	s.Count++
+	if o.Err != nil {
+		s.Errors++
+	}
+	s.Bytes += uint64(o.Bytes)
+	s.Sum += o.Duration
+	for i := len(c.buckets) - 1; i >= 0 && o.Duration <= c.buckets[i]; i-- {
+		s.Buckets[i]++
+	}
+}
+
+// Series returns a copy of the recorded series, sorted by operation and namespace.
+func (c *HistogramCollector) Series() []OpSeries {
+	c.mtx.Lock()
+	series := make([]OpSeries, 0, len(c.series))
+	for _, s := range c.series {
+		cs := *s
+		cs.Buckets = append([]uint64(nil), s.Buckets...)
+		series = append(series, cs)
+	}
+	c.mtx.Unlock()
+
+	sort.Slice(series, func(i, j int) bool {
+		if series[i].Op != series[j].Op {
+			return series[i].Op < series[j].Op
+		}
+		return series[i].Namespace < series[j].Namespace
+	})
+	return series
+}
+
+// WritePrometheus writes the recorded series to w in the Prometheus text format, as the
+// locketdb_operations_total, locketdb_operation_errors_total and locketdb_operation_bytes_total
+// counters and the locketdb_operation_duration_seconds histogram, labelled with op and namespace.
+// Namespaces that are not valid UTF-8 are written in hexadecimal, prefixed with 0x.
+func (c *HistogramCollector) WritePrometheus(w io.Writer) error {
+	series := c.Series()
+	bw := bufio.NewWriter(w)
+
+	counters := []struct {
+		name, help string
+		value      func(s *OpSeries) uint64
+	}{
+		{"locketdb_operations_total", "Number of operations.", func(s *OpSeries) uint64 { return s.Count }},
+		{"locketdb_operation_errors_total", "Number of operations that failed.", func(s *OpSeries) uint64 { return s.Errors }},
+		{"locketdb_operation_bytes_total", "Size of the keys and values read or written.", func(s *OpSeries) uint64 { return s.Bytes }},
+	}
+	for _, counter := range counters {
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s counter\n", counter.name, counter.help, counter.name)
+		for i := range series {
+			fmt.Fprintf(bw, "%s{%s} %d\n", counter.name, seriesLabels(&series[i]), counter.value(&series[i]))
+		}
+	}
+
+	const histogram = "locketdb_operation_duration_seconds"
+	fmt.Fprintf(bw, "# HELP %s Latency of the operations.\n# TYPE %s histogram\n", histogram, histogram)
+	for i := range series {
+		s := &series[i]
+		labels := seriesLabels(s)
+		for b, bound := range c.buckets {
+			fmt.Fprintf(bw, "%s_bucket{%s,le=\"%s\"} %d\n", histogram, labels, formatSeconds(bound), s.Buckets[b])
+		}
+		fmt.Fprintf(bw, "%s_bucket{%s,le=\"+Inf\"} %d\n", histogram, labels, s.Count)
+		fmt.Fprintf(bw, "%s_sum{%s} %s\n", histogram, labels, formatSeconds(s.Sum))
+		fmt.Fprintf(bw, "%s_count{%s} %d\n", histogram, labels, s.Count)
+	}
+	return bw.Flush()
+}
+
+// ServeHTTP implements http.Handler, serving the recorded series in the Prometheus text format.
+func (c *HistogramCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := c.WritePrometheus(w); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func seriesLabels(s *OpSeries) string {
+	namespace := s.Namespace
+	if !utf8.ValidString(namespace) {
+		namespace = fmt.Sprintf("0x%X", namespace)
+	}
+	return fmt.Sprintf("op=\"%s\",namespace=\"%s\"", escapeLabel(string(s.Op)), escapeLabel(namespace))
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+func formatSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'g', -1, 64)
+}
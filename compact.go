@@ -0,0 +1,20 @@
+package locketdb
+
+// Compacter is implemented by databases that can reclaim the space used by deleted and
+// overwritten keys on demand. The domain follows the same rules as DB.Iterator; backends that
+// cannot compact a partial range compact the whole database instead.
+type Compacter interface {
+	// Compact compacts the underlying storage for the domain [start, end).
+	// CONTRACT: start, end readonly []byte
+	Compact(start, end []byte) error
+}
+
+// Compact compacts the domain [start, end) of db, or returns ErrNotSupported if the backend
+// cannot be compacted.
+func Compact(db DB, start, end []byte) error {
+	c, ok := db.(Compacter)
+	if !ok {
+		return ErrNotSupported
+	}
+	return c.Compact(start, end)
+}
@@ -0,0 +1,364 @@
+package locketdb
+
+import (
+	"context"
+	"time"
+)
+
+// ContextDB wraps a database to bind its operations to a context. Once the context is canceled or
+// its deadline is exceeded, every operation fails fast with the error of the context, and the
+// iterators, including those of its snapshots and transactions, stop at their next move and
+// report it from Error. Batches check the context on every operation, and before being written.
+//
+// Operations already running in the underlying database are not interrupted. The helpers taking a
+// DB, such as Diff or RangeHash, honour the context when given a ContextDB; DumpContext,
+// RestoreContext and MigrateContext are shorthands for that.
+type ContextDB struct {
+	ctx context.Context
+	db  DB
+}
+
+var (
+	_ DB                = (*ContextDB)(nil)
+	_ Snapshotter       = (*ContextDB)(nil)
+	_ Transactional     = (*ContextDB)(nil)
+	_ RangeDeleter      = (*ContextDB)(nil)
+	_ Compacter         = (*ContextDB)(nil)
+	_ KVTyper           = (*ContextDB)(nil)
+	_ MetricsReporter   = (*ContextDB)(nil)
+	_ TTLSetter         = (*ContextDB)(nil)
+	_ ConditionalWriter = (*ContextDB)(nil)
+	_ Merger            = (*ContextDB)(nil)
+)
+
+// NewContextDB binds the operations of db to ctx.
+func NewContextDB(ctx context.Context, db DB) *ContextDB {
+	return &ContextDB{
+		ctx: ctx,
+		db:  db,
+	}
+}
+
+// Context returns the context the operations are bound to.
+func (cdb *ContextDB) Context() context.Context {
+	return cdb.ctx
+}
+
+// WithContext returns a ContextDB binding the same database to another context, for instance
+// one per request. Both share the underlying database, so only one of them must be closed.
+func (cdb *ContextDB) WithContext(ctx context.Context) *ContextDB {
+	return NewContextDB(ctx, cdb.db)
+}
+
+// Get implements DB.
+func (cdb *ContextDB) Get(key []byte) ([]byte, error) {
+	if err := cdb.ctx.Err(); err != nil {
+		return nil, err
+	}
+	return cdb.db.Get(key)
+}
+
+// Has implements DB.
+func (cdb *ContextDB) Has(key []byte) (bool, error) {
+	if err := cdb.ctx.Err(); err != nil {
+		return false, err
+	}
+	return cdb.db.Has(key)
+}
+
+// Set implements DB.
+func (cdb *ContextDB) Set(key []byte, value []byte) error {
+	if err := cdb.ctx.Err(); err != nil {
+		return err
+	}
+	return cdb.db.Set(key, value)
+}
+
+// SetSync implements DB.
+func (cdb *ContextDB) SetSync(key []byte, value []byte) error {
+	if err := cdb.ctx.Err(); err != nil {
+		return err
+	}
+	return cdb.db.SetSync(key, value)
+}
+
+// SetWithTTL implements TTLSetter. It returns ErrNotSupported if the underlying database is not a
+// TTLSetter.
+func (cdb *ContextDB) SetWithTTL(key, value []byte, ttl time.Duration) error {
+	if err := cdb.ctx.Err(); err != nil {
+		return err
+	}
+	return SetWithTTL(cdb.db, key, value, ttl)
+}
+
+// CompareAndSwap implements ConditionalWriter. It returns ErrNotSupported if the underlying
+// database is not a ConditionalWriter.
+func (cdb *ContextDB) CompareAndSwap(key, expected, new []byte) (bool, error) {
+	if err := cdb.ctx.Err(); err != nil {
+		return false, err
+	}
+	return CompareAndSwap(cdb.db, key, expected, new)
+}
+
+// SetIfAbsent implements ConditionalWriter. It returns ErrNotSupported if the underlying database
+// is not a ConditionalWriter.
+func (cdb *ContextDB) SetIfAbsent(key, value []byte) (bool, error) {
+	if err := cdb.ctx.Err(); err != nil {
+		return false, err
+	}
+	return SetIfAbsent(cdb.db, key, value)
+}
+
+// Merge implements Merger. It returns ErrNotSupported if the underlying database is not a Merger.
+func (cdb *ContextDB) Merge(key, operand []byte) error {
+	if err := cdb.ctx.Err(); err != nil {
+		return err
+	}
+	return Merge(cdb.db, key, operand)
+}
+
+// Delete implements DB.
+func (cdb *ContextDB) Delete(key []byte) error {
+	if err := cdb.ctx.Err(); err != nil {
+		return err
+	}
+	return cdb.db.Delete(key)
+}
+
+// DeleteSync implements DB.
+func (cdb *ContextDB) DeleteSync(key []byte) error {
+	if err := cdb.ctx.Err(); err != nil {
+		return err
+	}
+	return cdb.db.DeleteSync(key)
+}
+
+// DeleteRange implements RangeDeleter. It returns ErrNotSupported if the underlying database is
+// not a RangeDeleter.
+func (cdb *ContextDB) DeleteRange(start, end []byte) error {
+	if err := cdb.ctx.Err(); err != nil {
+		return err
+	}
+	return DeleteRange(cdb.db, start, end)
+}
+
+// Compact implements Compacter. It returns ErrNotSupported if the underlying database is not a
+// Compacter.
+func (cdb *ContextDB) Compact(start, end []byte) error {
+	if err := cdb.ctx.Err(); err != nil {
+		return err
+	}
+	return Compact(cdb.db, start, end)
+}
+
+// Iterator implements DB.
+func (cdb *ContextDB) Iterator(start, end []byte) (Iterator, error) {
+	if err := cdb.ctx.Err(); err != nil {
+		return nil, err
+	}
+	itr, err := cdb.db.Iterator(start, end)
+	if err != nil {
+		return nil, err
+	}
+	return newContextIterator(cdb.ctx, itr), nil
+}
+
+// ReverseIterator implements DB.
+func (cdb *ContextDB) ReverseIterator(start, end []byte) (Iterator, error) {
+	if err := cdb.ctx.Err(); err != nil {
+		return nil, err
+	}
+	itr, err := cdb.db.ReverseIterator(start, end)
+	if err != nil {
+		return nil, err
+	}
+	return newContextIterator(cdb.ctx, itr), nil
+}
+
+// NewBatch implements DB.
+func (cdb *ContextDB) NewBatch() Batch {
+	return newContextBatch(cdb.ctx, cdb.db.NewBatch())
+}
+
+// NewSnapshot implements Snapshotter. It returns ErrNotSupported if the underlying database is
+// not a Snapshotter.
+func (cdb *ContextDB) NewSnapshot() (Snapshot, error) {
+	if err := cdb.ctx.Err(); err != nil {
+		return nil, err
+	}
+	snap, err := NewSnapshot(cdb.db)
+	if err != nil {
+		return nil, err
+	}
+	return &contextDBSnapshot{ctx: cdb.ctx, source: snap}, nil
+}
+
+// Begin implements Transactional. It returns ErrNotSupported if the underlying database is not
+// Transactional.
+func (cdb *ContextDB) Begin(writable bool) (Txn, error) {
+	if err := cdb.ctx.Err(); err != nil {
+		return nil, err
+	}
+	txn, err := Begin(cdb.db, writable)
+	if err != nil {
+		return nil, err
+	}
+	return &contextDBTxn{ctx: cdb.ctx, source: txn}, nil
+}
+
+// KVType implements KVTyper, reporting the type of the underlying database.
+func (cdb *ContextDB) KVType() KVType {
+	return TypeOf(cdb.db)
+}
+
+// Metrics implements MetricsReporter, reporting the metrics of the underlying database.
+func (cdb *ContextDB) Metrics() Metrics {
+	return MetricsOf(cdb.db)
+}
+
+// Close implements DB. It closes the underlying database even if the context is done.
+func (cdb *ContextDB) Close() error {
+	return cdb.db.Close()
+}
+
+// Print implements DB.
+func (cdb *ContextDB) Print() error {
+	if err := cdb.ctx.Err(); err != nil {
+		return err
+	}
+	return cdb.db.Print()
+}
+
+// Stats implements DB.
+func (cdb *ContextDB) Stats() map[string]string {
+	return cdb.db.Stats()
+}
+
+type contextDBSnapshot struct {
+	ctx    context.Context
+	source Snapshot
+}
+
+var _ Snapshot = (*contextDBSnapshot)(nil)
+
+// Get implements Snapshot.
+func (cs *contextDBSnapshot) Get(key []byte) ([]byte, error) {
+	if err := cs.ctx.Err(); err != nil {
+		return nil, err
+	}
+	return cs.source.Get(key)
+}
+
+// Has implements Snapshot.
+func (cs *contextDBSnapshot) Has(key []byte) (bool, error) {
+	if err := cs.ctx.Err(); err != nil {
+		return false, err
+	}
+	return cs.source.Has(key)
+}
+
+// Iterator implements Snapshot.
+func (cs *contextDBSnapshot) Iterator(start, end []byte) (Iterator, error) {
+	if err := cs.ctx.Err(); err != nil {
+		return nil, err
+	}
+	itr, err := cs.source.Iterator(start, end)
+	if err != nil {
+		return nil, err
+	}
+	return newContextIterator(cs.ctx, itr), nil
+}
+
+// ReverseIterator implements Snapshot.
+func (cs *contextDBSnapshot) ReverseIterator(start, end []byte) (Iterator, error) {
+	if err := cs.ctx.Err(); err != nil {
+		return nil, err
+	}
+	itr, err := cs.source.ReverseIterator(start, end)
+	if err != nil {
+		return nil, err
+	}
+	return newContextIterator(cs.ctx, itr), nil
+}
+
+// Close implements Snapshot.
+func (cs *contextDBSnapshot) Close() error {
+	return cs.source.Close()
+}
+
+type contextDBTxn struct {
+	ctx    context.Context
+	source Txn
+}
+
+var _ Txn = (*contextDBTxn)(nil)
+
+// Get implements Txn.
+func (ct *contextDBTxn) Get(key []byte) ([]byte, error) {
+	if err := ct.ctx.Err(); err != nil {
+		return nil, err
+	}
+	return ct.source.Get(key)
+}
+
+// Has implements Txn.
+func (ct *contextDBTxn) Has(key []byte) (bool, error) {
+	if err := ct.ctx.Err(); err != nil {
+		return false, err
+	}
+	return ct.source.Has(key)
+}
+
+// Set implements Txn.
+func (ct *contextDBTxn) Set(key, value []byte) error {
+	if err := ct.ctx.Err(); err != nil {
+		return err
+	}
+	return ct.source.Set(key, value)
+}
+
+// Delete implements Txn.
+func (ct *contextDBTxn) Delete(key []byte) error {
+	if err := ct.ctx.Err(); err != nil {
+		return err
+	}
+	return ct.source.Delete(key)
+}
+
+// Iterator implements Txn.
+func (ct *contextDBTxn) Iterator(start, end []byte) (Iterator, error) {
+	if err := ct.ctx.Err(); err != nil {
+		return nil, err
+	}
+	itr, err := ct.source.Iterator(start, end)
+	if err != nil {
+		return nil, err
+	}
+	return newContextIterator(ct.ctx, itr), nil
+}
+
+// ReverseIterator implements Txn.
+func (ct *contextDBTxn) ReverseIterator(start, end []byte) (Iterator, error) {
+	if err := ct.ctx.Err(); err != nil {
+		return nil, err
+	}
+	itr, err := ct.source.ReverseIterator(start, end)
+	if err != nil {
+		return nil, err
+	}
+	return newContextIterator(ct.ctx, itr), nil
+}
+
+// Commit implements Txn. If the context is done, nothing is written and the transaction must
+// still be rolled back.
+func (ct *contextDBTxn) Commit() error {
+	if err := ct.ctx.Err(); err != nil {
+		return err
+	}
+	return ct.source.Commit()
+}
+
+// Rollback implements Txn.
+func (ct *contextDBTxn) Rollback() error {
+	return ct.source.Rollback()
+}
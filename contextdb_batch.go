@@ -0,0 +1,83 @@
+package locketdb
+
+import "context"
+
+type contextDBBatch struct {
+	ctx    context.Context
+	source Batch
+}
+
+var (
+	_ Batch        = (*contextDBBatch)(nil)
+	_ RangeDeleter = (*contextDBBatch)(nil)
+	_ Merger       = (*contextDBBatch)(nil)
+)
+
+func newContextBatch(ctx context.Context, source Batch) contextDBBatch {
+	return contextDBBatch{
+		ctx:    ctx,
+		source: source,
+	}
+}
+
+// Set implements Batch.
+func (cb contextDBBatch) Set(key, value []byte) error {
+	if err := cb.ctx.Err(); err != nil {
+		return err
+	}
+	return cb.source.Set(key, value)
+}
+
+// Delete implements Batch.
+func (cb contextDBBatch) Delete(key []byte) error {
+	if err := cb.ctx.Err(); err != nil {
+		return err
+	}
+	return cb.source.Delete(key)
+}
+
+// Merge implements Merger. It returns ErrNotSupported if the underlying batch is not a Merger.
+func (cb contextDBBatch) Merge(key, operand []byte) error {
+	if err := cb.ctx.Err(); err != nil {
+		return err
+	}
+	m, ok := cb.source.(Merger)
+	if !ok {
+		return ErrNotSupported
+	}
+	return m.Merge(key, operand)
+}
+
+// DeleteRange implements RangeDeleter. It returns ErrNotSupported if the underlying batch is not
+// a RangeDeleter.
+func (cb contextDBBatch) DeleteRange(start, end []byte) error {
+	if err := cb.ctx.Err(); err != nil {
+		return err
+	}
+	rd, ok := cb.source.(RangeDeleter)
+	if !ok {
+		return ErrNotSupported
+	}
+	return rd.DeleteRange(start, end)
+}
+
+// Write implements Batch. If the context is done, nothing is written.
+func (cb contextDBBatch) Write() error {
+	if err := cb.ctx.Err(); err != nil {
+		return err
+	}
+	return cb.source.Write()
+}
+
+// WriteSync implements Batch. If the context is done, nothing is written.
+func (cb contextDBBatch) WriteSync() error {
+	if err := cb.ctx.Err(); err != nil {
+		return err
+	}
+	return cb.source.WriteSync()
+}
+
+// Close implements Batch.
+func (cb contextDBBatch) Close() error {
+	return cb.source.Close()
+}
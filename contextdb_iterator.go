@@ -0,0 +1,120 @@
+package locketdb
+
+import "context"
+
+// contextDBIterator checks its context before every move of the underlying iterator. Once the
+// context is done, the iterator is invalid and Error returns the error of the context.
+type contextDBIterator struct {
+	ctx    context.Context
+	source Iterator
+	err    error
+}
+
+var _ SeekIterator = (*contextDBIterator)(nil)
+
+func newContextIterator(ctx context.Context, source Iterator) *contextDBIterator {
+	return &contextDBIterator{
+		ctx:    ctx,
+		source: source,
+	}
+}
+
+// Domain implements Iterator.
+func (itr *contextDBIterator) Domain() (start []byte, end []byte) {
+	return itr.source.Domain()
+}
+
+// Valid implements Iterator.
+func (itr *contextDBIterator) Valid() bool {
+	return itr.err == nil && itr.source.Valid()
+}
+
+// Next implements Iterator.
+func (itr *contextDBIterator) Next() {
+	itr.assertIsValid()
+	if itr.check() {
+		itr.source.Next()
+	}
+}
+
+// Prev implements SeekIterator.
+func (itr *contextDBIterator) Prev() {
+	itr.assertIsValid()
+	if source, ok := itr.seekSource(); ok {
+		source.Prev()
+	}
+}
+
+// Seek implements SeekIterator. Like First and Last, it makes the iterator fail with
+// ErrNotSupported if the underlying iterator is not a SeekIterator. It does not make an iterator
+// stopped by its context valid again.
+func (itr *contextDBIterator) Seek(key []byte) {
+	if source, ok := itr.seekSource(); ok {
+		source.Seek(key)
+	}
+}
+
+// First implements SeekIterator.
+func (itr *contextDBIterator) First() {
+	if source, ok := itr.seekSource(); ok {
+		source.First()
+	}
+}
+
+// Last implements SeekIterator.
+func (itr *contextDBIterator) Last() {
+	if source, ok := itr.seekSource(); ok {
+		source.Last()
+	}
+}
+
+// check records the error of the context, if it is done, and reports whether the iterator may
+// move.
+func (itr *contextDBIterator) check() bool {
+	if itr.err == nil {
+		itr.err = itr.ctx.Err()
+	}
+	return itr.err == nil
+}
+
+func (itr *contextDBIterator) seekSource() (SeekIterator, bool) {
+	if !itr.check() {
+		return nil, false
+	}
+	source, ok := itr.source.(SeekIterator)
+	if !ok {
+		itr.err = ErrNotSupported
+	}
+	return source, ok
+}
+
+// Key implements Iterator.
+func (itr *contextDBIterator) Key() []byte {
+	itr.assertIsValid()
+	return itr.source.Key()
+}
+
+// Value implements Iterator.
+func (itr *contextDBIterator) Value() []byte {
+	itr.assertIsValid()
+	return itr.source.Value()
+}
+
+// Error implements Iterator.
+func (itr *contextDBIterator) Error() error {
+	if itr.err != nil {
+		return itr.err
+	}
+	return itr.source.Error()
+}
+
+// Close implements Iterator.
+func (itr *contextDBIterator) Close() error {
+	return itr.source.Close()
+}
+
+func (itr *contextDBIterator) assertIsValid() {
+	if !itr.Valid() {
+		panic("iterator is invalid")
+	}
+}
@@ -0,0 +1,173 @@
+package locketdb_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/meission/locketdb"
+	"github.com/meission/locketdb/locketdbtest"
+	"github.com/meission/locketdb/memdb"
+)
+
+func newContextDB(t *testing.T, ctx context.Context, keys int) *locketdb.ContextDB {
+	t.Helper()
+	db, err := memdb.NewDB("test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < keys; i++ {
+		if err := db.Set([]byte(fmt.Sprintf("key%04d", i)), []byte(fmt.Sprintf("value%d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return locketdb.NewContextDB(ctx, db)
+}
+
+func TestContextDBConformance(t *testing.T) {
+	locketdbtest.RunConformance(t, func() locketdb.DB {
+		return newContextDB(t, context.Background(), 0)
+	})
+}
+
+func TestContextDBCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	db := newContextDB(t, ctx, 10)
+	defer db.Close()
+
+	batch := db.NewBatch()
+	defer batch.Close()
+	if err := batch.Set([]byte("batched"), []byte("v")); err != nil {
+		t.Fatal(err)
+	}
+	snap, err := db.NewSnapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer snap.Close()
+	itr, err := db.Iterator(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer itr.Close()
+	itr.Next()
+
+	cancel()
+
+	if err := db.Set([]byte("k"), []byte("v")); !errors.Is(err, context.Canceled) {
+		t.Errorf("Set: got %v, want context.Canceled", err)
+	}
+	if _, err := db.Get([]byte("key0000")); !errors.Is(err, context.Canceled) {
+		t.Errorf("Get: got %v, want context.Canceled", err)
+	}
+	if _, err := db.Iterator(nil, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("Iterator: got %v, want context.Canceled", err)
+	}
+	if _, err := snap.Get([]byte("key0000")); !errors.Is(err, context.Canceled) {
+		t.Errorf("Snapshot.Get: got %v, want context.Canceled", err)
+	}
+	if err := batch.Write(); !errors.Is(err, context.Canceled) {
+		t.Errorf("Batch.Write: got %v, want context.Canceled", err)
+	}
+
+	// The iterator stays on its key until it is moved.
+	if !itr.Valid() || !bytes.Equal(itr.Key(), []byte("key0001")) {
+		t.Fatal("expected the iterator to stay on key0001")
+	}
+	itr.Next()
+	if itr.Valid() {
+		t.Error("expected the iterator to be invalid after Next")
+	}
+	if err := itr.Error(); !errors.Is(err, context.Canceled) {
+		t.Errorf("Iterator.Error: got %v, want context.Canceled", err)
+	}
+	if seek, ok := itr.(locketdb.SeekIterator); ok {
+		seek.First()
+		if seek.Valid() {
+			t.Error("expected First not to revive an iterator stopped by its context")
+		}
+	}
+
+	// Nothing was written, and the database is still usable with another context.
+	live := db.WithContext(context.Background())
+	if ok, err := live.Has([]byte("batched")); err != nil || ok {
+		t.Errorf("Has(batched) = %v, %v, want false", ok, err)
+	}
+	if err := live.Set([]byte("k"), []byte("v")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestContextDBTxn(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	db := newContextDB(t, ctx, 0)
+	defer db.Close()
+
+	txn, err := db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer txn.Rollback()
+	if err := txn.Set([]byte("k"), []byte("v")); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if err := txn.Commit(); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Commit: got %v, want context.Canceled", err)
+	}
+	if ok, err := db.WithContext(context.Background()).Has([]byte("k")); err != nil || ok {
+		t.Errorf("Has(k) = %v, %v, want false", ok, err)
+	}
+}
+
+func TestContextBulkHelpers(t *testing.T) {
+	src := newContextDB(t, context.Background(), 100)
+	defer src.Close()
+	var dump bytes.Buffer
+	if err := locketdb.Dump(src, &dump); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := locketdb.DumpContext(ctx, src, &bytes.Buffer{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("DumpContext: got %v, want context.Canceled", err)
+	}
+
+	dst, err := memdb.NewDB("dst", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+	if err := locketdb.RestoreContext(ctx, dst, bytes.NewReader(dump.Bytes())); !errors.Is(err, context.Canceled) {
+		t.Errorf("RestoreContext: got %v, want context.Canceled", err)
+	}
+	if err := locketdb.MigrateContext(ctx, src, dst, locketdb.MigrateOptions{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("MigrateContext: got %v, want context.Canceled", err)
+	}
+	if n := countKeys(t, dst); n != 0 {
+		t.Errorf("%d keys written after cancellation, want 0", n)
+	}
+
+	// A migration canceled halfway can be resumed.
+	ctx, cancel = context.WithCancel(context.Background())
+	err = locketdb.MigrateContext(ctx, src, dst, locketdb.MigrateOptions{
+		BatchSize: 256,
+		Progress: func(p locketdb.MigrateProgress) {
+			if p.Keys >= 30 {
+				cancel()
+			}
+		},
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("MigrateContext: got %v, want context.Canceled", err)
+	}
+	if n := countKeys(t, dst); n == 0 || n >= 100 {
+		t.Fatalf("%d keys copied before cancellation, want some", n)
+	}
+	if err := locketdb.Migrate(src, dst, locketdb.MigrateOptions{Resume: true, Verify: true}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,58 @@
+package locketdb
+
+import (
+	"bytes"
+	"errors"
+)
+
+// ErrRangeTooLarge is returned by DeleteRange when a backend cannot delete a domain holding that
+// many keys atomically.
+var ErrRangeTooLarge = errors.New("range too large to delete atomically")
+
+// RangeDeleter is implemented by databases and batches that can delete every key within a domain
+// at once. The domain follows the same rules as DB.Iterator: end is exclusive, a nil start
+// deletes from the first key and a nil end deletes up to the last key (inclusive). Empty keys
+// are not valid.
+//
+// On a DB, DeleteRange deletes the keys in the domain atomically when it is called. Backends
+// without range tombstones, goleveldb and badger unless the domain is a whole prefix, delete the
+// keys one by one in a single write, and fail with ErrRangeTooLarge rather than delete part of a
+// domain holding too many of them: callers can then delete it as several smaller domains.
+//
+// On a Batch, the deletion is applied atomically with the other operations of the batch when it
+// is written, but backends without range tombstones resolve the keys to delete when DeleteRange is
+// called: keys written to the database between DeleteRange and Write are kept. They fail with
+// ErrRangeTooLarge on the same domains as on a DB, when DeleteRange or Write is called.
+//
+// pebble cannot write an open-ended range tombstone, so it resolves a nil end when DeleteRange is
+// called: the range stops after the last key present at that time, and greater keys written
+// afterwards, including before a batch is written, are kept.
+type RangeDeleter interface {
+	// DeleteRange deletes all keys in the domain [start, end).
+	// CONTRACT: start, end readonly []byte
+	DeleteRange(start, end []byte) error
+}
+
+// DeleteRange deletes all keys in [start, end) from db, or returns ErrNotSupported if the backend
+// cannot delete ranges.
+func DeleteRange(db DB, start, end []byte) error {
+	rd, ok := db.(RangeDeleter)
+	if !ok {
+		return ErrNotSupported
+	}
+	return rd.DeleteRange(start, end)
+}
+
+// IsEmptyRange reports whether the domain [start, end) cannot contain any key.
+func IsEmptyRange(start, end []byte) bool {
+	return start != nil && end != nil && bytes.Compare(start, end) >= 0
+}
+
+// IsPrefixRange reports whether the domain [start, end) holds exactly the keys prefixed by start,
+// as computed by IteratePrefix.
+func IsPrefixRange(start, end []byte) bool {
+	if len(start) == 0 {
+		return false
+	}
+	return bytes.Equal(cpIncr(start), end)
+}
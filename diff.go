@@ -0,0 +1,389 @@
+package locketdb
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"hash/fnv"
+	"math"
+)
+
+// DiffKind tells how a key differs between two databases.
+type DiffKind int
+
+const (
+	// DiffAdded is a key present in the second database only.
+	DiffAdded DiffKind = iota + 1
+	// DiffRemoved is a key present in the first database only.
+	DiffRemoved
+	// DiffChanged is a key present in both databases with different values.
+	DiffChanged
+)
+
+// String implements fmt.Stringer.
+func (k DiffKind) String() string {
+	switch k {
+	case DiffAdded:
+		return "added"
+	case DiffRemoved:
+		return "removed"
+	case DiffChanged:
+		return "changed"
+	default:
+		return "unknown"
+	}
+}
+
+// Difference is a key that differs between two databases a and b.
+type Difference struct {
+	Kind DiffKind
+	Key  []byte
+	// A and B are the values of the key in a and b, nil where it is absent.
+	A, B []byte
+}
+
+// Diff returns the keys that differ between a and b within [start, end), in ascending order. The
+// domain follows the same rules as DB.Iterator. Both databases are read from a snapshot if they
+// are Snapshotters.
+func Diff(a, b DB, start, end []byte) ([]Difference, error) {
+	viewA, releaseA, err := consistentView(a)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseA()
+	viewB, releaseB, err := consistentView(b)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseB()
+	return diffRange(viewA, viewB, start, end)
+}
+
+func diffRange(a, b rangeReader, start, end []byte) ([]Difference, error) {
+	var diffs []Difference
+	err := walkDiff(a, b, start, end, func(d Difference) bool {
+		diffs = append(diffs, d)
+		return true
+	})
+	return diffs, err
+}
+
+// walkDiff walks a and b over [start, end) in lockstep, calling fn with each difference until it
+// returns false.
+func walkDiff(a, b rangeReader, start, end []byte, fn func(Difference) bool) error {
+	itrA, err := a.Iterator(start, end)
+	if err != nil {
+		return err
+	}
+	defer itrA.Close()
+	itrB, err := b.Iterator(start, end)
+	if err != nil {
+		return err
+	}
+	defer itrB.Close()
+
+	for itrA.Valid() || itrB.Valid() {
+		var d Difference
+		c := 0
+		switch {
+		case !itrA.Valid():
+			c = 1
+		case !itrB.Valid():
+			c = -1
+		default:
+			c = bytes.Compare(itrA.Key(), itrB.Key())
+		}
+		switch {
+		case c < 0:
+			d = Difference{Kind: DiffRemoved, Key: cp(itrA.Key()), A: cp(itrA.Value())}
+			itrA.Next()
+		case c > 0:
+			d = Difference{Kind: DiffAdded, Key: cp(itrB.Key()), B: cp(itrB.Value())}
+			itrB.Next()
+		default:
+			if !bytes.Equal(itrA.Value(), itrB.Value()) {
+				d = Difference{Kind: DiffChanged, Key: cp(itrA.Key()), A: cp(itrA.Value()), B: cp(itrB.Value())}
+			}
+			itrA.Next()
+			itrB.Next()
+		}
+		if d.Kind != 0 && !fn(d) {
+			break
+		}
+	}
+	if err := itrA.Error(); err != nil {
+		return err
+	}
+	return itrB.Error()
+}
+
+// RangeDigest summarizes the keys and values of a domain.
+type RangeDigest struct {
+	// Keys is the number of keys in the domain.
+	Keys uint64
+	// Hash is the root of the Merkle tree of the domain, see RangeTree.
+	Hash [sha256.Size]byte
+}
+
+// KeyRange is a domain [Start, End), following the same rules as DB.Iterator.
+type KeyRange struct {
+	Start, End []byte
+}
+
+// RangeTree is a node of the Merkle tree of the keys and values of a domain, as built by
+// BuildRangeTree.
+//
+// The leaves split the domain after every key whose FNV-1a hash is a multiple of the leaf size,
+// and the nodes of each level above group the nodes below after every key whose hash is also a
+// multiple of the leaf size times rangeTreeFanout, and so on. The boundaries depend on the keys
+// alone, so two databases holding the same data in a domain have identical trees, and trees
+// still line up around the keys that differ. A leaf hashes its keys and values in ascending
+// order, each preceded by its length as an uvarint, and a node combines the digests of its
+// children with CombineDigests.
+type RangeTree struct {
+	KeyRange
+	RangeDigest
+	// Children are the nodes of the level below, nil for a leaf.
+	Children []*RangeTree
+
+	// boundary is the FNV-1a hash of the last key of the node when it ends at a boundary, and 0
+	// when it ends at the end of the domain.
+	boundary uint64
+}
+
+// rangeTreeFanout is the expected number of children of a RangeTree node.
+const rangeTreeFanout = 16
+
+// defaultLeafKeys is the expected number of keys of a RangeTree leaf when none is given.
+const defaultLeafKeys = 1024
+
+// CombineDigests returns the digest of a RangeTree node from those of its children.
+func CombineDigests(children []RangeDigest) RangeDigest {
+	var digest RangeDigest
+	h := sha256.New()
+	var buf [binary.MaxVarintLen64]byte
+	for _, child := range children {
+		digest.Keys += child.Keys
+		h.Write(buf[:binary.PutUvarint(buf[:], child.Keys)])
+		h.Write(child.Hash[:])
+	}
+	copy(digest.Hash[:], h.Sum(nil))
+	return digest
+}
+
+// BuildRangeTree reads the keys and values of db within [start, end) once, and returns the root of
+// their Merkle tree with leaves of leafKeys keys on average. Zero uses a default of 1024 keys.
+// Only trees built with the same leaf size can be compared. db is read from a snapshot if it is a
+// Snapshotter.
+func BuildRangeTree(db DB, start, end []byte, leafKeys int) (*RangeTree, error) {
+	view, release, err := consistentView(db)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return buildRangeTree(view, start, end, leafKeys)
+}
+
+func buildRangeTree(db rangeReader, start, end []byte, leafKeys int) (*RangeTree, error) {
+	if leafKeys < 1 {
+		leafKeys = defaultLeafKeys
+	}
+	itr, err := db.Iterator(start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer itr.Close()
+
+	var nodes []*RangeTree
+	leafStart := start
+	h := sha256.New()
+	var keys uint64
+	var buf [binary.MaxVarintLen64]byte
+	closeLeaf := func(end []byte, boundary uint64) {
+		leaf := &RangeTree{KeyRange: KeyRange{Start: leafStart, End: end}, boundary: boundary}
+		leaf.Keys = keys
+		copy(leaf.Hash[:], h.Sum(nil))
+		nodes = append(nodes, leaf)
+		leafStart, keys = end, 0
+		h.Reset()
+	}
+	for ; itr.Valid(); itr.Next() {
+		key, value := itr.Key(), itr.Value()
+		h.Write(buf[:binary.PutUvarint(buf[:], uint64(len(key)))])
+		h.Write(key)
+		h.Write(buf[:binary.PutUvarint(buf[:], uint64(len(value)))])
+		h.Write(value)
+		keys++
+		if kh := keyHash(key); kh%uint64(leafKeys) == 0 {
+			closeLeaf(append(cp(key), 0), kh)
+		}
+	}
+	if err := itr.Error(); err != nil {
+		return nil, err
+	}
+	// The last leaf runs up to the end of the domain, even if it is empty, so that the leaves of
+	// two trees over the same domain line up.
+	closeLeaf(end, 0)
+
+	for modulus := uint64(leafKeys); len(nodes) > 1; {
+		if modulus > math.MaxUint64/rangeTreeFanout {
+			nodes = []*RangeTree{newRangeNode(nodes)}
+			break
+		}
+		modulus *= rangeTreeFanout
+		var level []*RangeTree
+		first := 0
+		for i, node := range nodes {
+			if i == len(nodes)-1 || (node.boundary != 0 && node.boundary%modulus == 0) {
+				level = append(level, newRangeNode(nodes[first:i+1]))
+				first = i + 1
+			}
+		}
+		nodes = level
+	}
+	return nodes[0], nil
+}
+
+// newRangeNode returns the RangeTree node grouping children.
+func newRangeNode(children []*RangeTree) *RangeTree {
+	digests := make([]RangeDigest, len(children))
+	for i, child := range children {
+		digests[i] = child.RangeDigest
+	}
+	last := children[len(children)-1]
+	return &RangeTree{
+		KeyRange:    KeyRange{Start: children[0].Start, End: last.End},
+		RangeDigest: CombineDigests(digests),
+		Children:    children,
+		boundary:    last.boundary,
+	}
+}
+
+// keyHash returns the FNV-1a hash of key, which places the boundaries of a RangeTree.
+func keyHash(key []byte) uint64 {
+	h := fnv.New64a()
+	h.Write(key)
+	return h.Sum64()
+}
+
+// DiffRanges returns the smallest domains, in ascending order, over which the trees t and other,
+// built over the same domain with the same leaf size, may hold different data. It only descends
+// into the subtrees whose digests differ, so the trees may be fetched lazily, for instance from
+// a remote database, level by level.
+func (t *RangeTree) DiffRanges(other *RangeTree) []KeyRange {
+	var ranges []KeyRange
+	diffRangeTrees([]*RangeTree{t}, []*RangeTree{other}, &ranges)
+	return ranges
+}
+
+// diffRangeTrees compares two sequences of nodes covering the same domain. The nodes are grouped
+// into the smallest runs ending at the same key on both sides: runs of one identical node are
+// skipped, runs of leaves are reported as differing, and the other runs are compared again one
+// level lower.
+func diffRangeTrees(a, b []*RangeTree, ranges *[]KeyRange) {
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		firstA, firstB := i, j
+		endA, endB := a[i].End, b[j].End
+		i++
+		j++
+		for c := compareEnd(endA, endB); c != 0; c = compareEnd(endA, endB) {
+			if c < 0 {
+				endA = a[i].End
+				i++
+			} else {
+				endB = b[j].End
+				j++
+			}
+		}
+		runA, runB := a[firstA:i], b[firstB:j]
+		if len(runA) == 1 && len(runB) == 1 && runA[0].RangeDigest == runB[0].RangeDigest {
+			continue
+		}
+		lowerA, leavesA := expandRangeTrees(runA)
+		lowerB, leavesB := expandRangeTrees(runB)
+		if leavesA && leavesB {
+			*ranges = append(*ranges, KeyRange{Start: runA[0].Start, End: endA})
+			continue
+		}
+		diffRangeTrees(lowerA, lowerB, ranges)
+	}
+}
+
+// expandRangeTrees replaces the nodes by their children, keeping leaves, and reports whether the
+// nodes were all leaves.
+func expandRangeTrees(nodes []*RangeTree) ([]*RangeTree, bool) {
+	var lower []*RangeTree
+	leaves := true
+	for _, node := range nodes {
+		if node.Children == nil {
+			lower = append(lower, node)
+			continue
+		}
+		lower = append(lower, node.Children...)
+		leaves = false
+	}
+	return lower, leaves
+}
+
+// compareEnd compares the ends of two domains, a nil end being past every key.
+func compareEnd(a, b []byte) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return 1
+	case b == nil:
+		return -1
+	}
+	return bytes.Compare(a, b)
+}
+
+// RangeHash returns the digest of the keys and values of db within [start, end), the root of
+// their RangeTree with the default leaf size. Two databases hold the same data in a domain if and
+// only if, barring hash collisions, their digests of the domain are equal. To locate the
+// differences, compare their trees with RangeTree.DiffRanges, as BisectDiff does.
+func RangeHash(db DB, start, end []byte) (RangeDigest, error) {
+	tree, err := BuildRangeTree(db, start, end, 0)
+	if err != nil {
+		return RangeDigest{}, err
+	}
+	return tree.RangeDigest, nil
+}
+
+// BisectDiff returns the same differences as Diff, but finds them by building the RangeTree of a
+// and b over [start, end), with leaves of leafKeys keys on average, and walking with Diff only the
+// domains where the trees differ. Zero uses a default of 1024 keys.
+//
+// Both databases are read once to build their trees, which pays off over Diff when the trees are
+// cheaper to obtain than the data, for instance when they are kept or computed remotely. Both
+// databases are read from a snapshot if they are Snapshotters.
+func BisectDiff(a, b DB, start, end []byte, leafKeys int) ([]Difference, error) {
+	viewA, releaseA, err := consistentView(a)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseA()
+	viewB, releaseB, err := consistentView(b)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseB()
+
+	treeA, err := buildRangeTree(viewA, start, end, leafKeys)
+	if err != nil {
+		return nil, err
+	}
+	treeB, err := buildRangeTree(viewB, start, end, leafKeys)
+	if err != nil {
+		return nil, err
+	}
+	var diffs []Difference
+	for _, r := range treeA.DiffRanges(treeB) {
+		d, err := diffRange(viewA, viewB, r.Start, r.End)
+		if err != nil {
+			return nil, err
+		}
+		diffs = append(diffs, d...)
+	}
+	return diffs, nil
+}
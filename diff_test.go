@@ -0,0 +1,163 @@
+package locketdb_test
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/meission/locketdb"
+	"github.com/meission/locketdb/memdb"
+)
+
+func TestDiff(t *testing.T) {
+	a, err := memdb.NewDB("a", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := locketdb.NewDB("b", locketdb.GoLevelDB, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	for i := 0; i < 5000; i++ {
+		k, v := []byte(fmt.Sprintf("key%04d", i)), []byte(fmt.Sprintf("value%d", i))
+		if err := a.Set(k, v); err != nil {
+			t.Fatal(err)
+		}
+		if err := b.Set(k, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	digestA, err := locketdb.RangeHash(a, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digestB, err := locketdb.RangeHash(b, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if digestA != digestB || digestA.Keys != 5000 {
+		t.Fatalf("expected equal digests of 5000 keys, got %+v and %+v", digestA, digestB)
+	}
+
+	mustNoErr := func(err error) {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	mustNoErr(a.Delete([]byte("key0000")))
+	mustNoErr(b.Set([]byte("key1234"), []byte("changed")))
+	mustNoErr(b.Delete([]byte("key3000")))
+	mustNoErr(b.Set([]byte("key9999"), []byte("new")))
+
+	want := []locketdb.Difference{
+		{Kind: locketdb.DiffAdded, Key: []byte("key0000"), B: []byte("value0")},
+		{Kind: locketdb.DiffChanged, Key: []byte("key1234"), A: []byte("value1234"), B: []byte("changed")},
+		{Kind: locketdb.DiffRemoved, Key: []byte("key3000"), A: []byte("value3000")},
+		{Kind: locketdb.DiffAdded, Key: []byte("key9999"), B: []byte("new")},
+	}
+	diffs, err := locketdb.Diff(a, b, nil, nil)
+	mustNoErr(err)
+	if !reflect.DeepEqual(diffs, want) {
+		t.Errorf("Diff: expected %v, got %v", want, diffs)
+	}
+	diffs, err = locketdb.BisectDiff(a, b, nil, nil, 16)
+	mustNoErr(err)
+	if !reflect.DeepEqual(diffs, want) {
+		t.Errorf("BisectDiff: expected %v, got %v", want, diffs)
+	}
+
+	diffs, err = locketdb.Diff(a, b, []byte("key1000"), []byte("key3000"))
+	mustNoErr(err)
+	if !reflect.DeepEqual(diffs, want[1:2]) {
+		t.Errorf("Diff over a domain: expected %v, got %v", want[1:2], diffs)
+	}
+	digestA, err = locketdb.RangeHash(a, []byte("key2000"), []byte("key3000"))
+	mustNoErr(err)
+	digestB, err = locketdb.RangeHash(b, []byte("key2000"), []byte("key3000"))
+	mustNoErr(err)
+	if digestA != digestB {
+		t.Errorf("expected equal digests over an unchanged domain")
+	}
+}
+
+func TestRangeTree(t *testing.T) {
+	newDB := func() locketdb.DB {
+		db, err := memdb.NewDB("test", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 5000; i++ {
+			if err := db.Set([]byte(fmt.Sprintf("key%04d", i)), []byte(fmt.Sprintf("value%d", i))); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return db
+	}
+	a, b := newDB(), newDB()
+	tree, err := locketdb.BuildRangeTree(a, nil, nil, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tree.Keys != 5000 || len(tree.Children) < 2 || tree.Children[0].Children == nil {
+		t.Fatalf("expected a tree of several levels over 5000 keys, got %d keys and %d children",
+			tree.Keys, len(tree.Children))
+	}
+	var check func(node *locketdb.RangeTree)
+	check = func(node *locketdb.RangeTree) {
+		if node.Children == nil {
+			return
+		}
+		digests := make([]locketdb.RangeDigest, len(node.Children))
+		for i, child := range node.Children {
+			digests[i] = child.RangeDigest
+			if i > 0 && !reflect.DeepEqual(child.Start, node.Children[i-1].End) {
+				t.Errorf("child %d starts at %q, after a sibling ending at %q", i, child.Start, node.Children[i-1].End)
+			}
+			check(child)
+		}
+		if locketdb.CombineDigests(digests) != node.RangeDigest {
+			t.Errorf("node [%q, %q) does not combine the digests of its children", node.Start, node.End)
+		}
+	}
+	check(tree)
+
+	if err := b.Set([]byte("key2500"), []byte("changed")); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Set([]byte("key1000a"), []byte("inserted")); err != nil {
+		t.Fatal(err)
+	}
+	other, err := locketdb.BuildRangeTree(b, nil, nil, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tree.RangeDigest == other.RangeDigest {
+		t.Fatal("expected different digests")
+	}
+	ranges := tree.DiffRanges(other)
+	covered := 0
+	for _, r := range ranges {
+		diffs, err := locketdb.Diff(a, b, r.Start, r.End)
+		if err != nil {
+			t.Fatal(err)
+		}
+		covered += len(diffs)
+		itr, err := a.Iterator(r.Start, r.End)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n := 0
+		for ; itr.Valid(); itr.Next() {
+			n++
+		}
+		itr.Close()
+		if n > 16*8 {
+			t.Errorf("differing range [%q, %q) holds %d keys, expected a few leaves at most", r.Start, r.End, n)
+		}
+	}
+	if covered != 2 {
+		t.Errorf("expected the differing ranges to cover both differences, got %d in %v", covered, ranges)
+	}
+}
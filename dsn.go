@@ -0,0 +1,235 @@
+package locketdb
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DSNEngine opens a database from a DSN. name and dir are derived from the DSN path the same way
+// NewDB expects them, and params holds the query parameters, which the engine must read through
+// the typed accessors of DSNParams before checking DSNParams.Err.
+type DSNEngine func(name string, dir string, params *DSNParams) (DB, error)
+
+var dsnEngines = map[KVType]DSNEngine{}
+
+// RegisterDSNEngine makes a backend available to Open under the DSN scheme backend.
+func RegisterDSNEngine(backend KVType, engine DSNEngine) {
+	dsnEngines[backend] = engine
+}
+
+// Open opens a database described by a DSN of the form
+//
+//	<kvType>://<path>?<option>=<value>&...
+//
+// for instance "pebble:///var/data/foo?cache_size=256MB&sync=true". The last element of the path
+// is the database name and the rest its directory, so the DSN above opens the same database as
+// NewDB("foo", Pebble, "/var/data"). Relative paths are written without the slashes, as in
+// "golevel:data/foo". The options are specific to each backend; unknown options are rejected.
+func Open(dsn string) (DB, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DSN %q: %w", dsn, err)
+	}
+	kvType := KVType(u.Scheme)
+	engine, ok := dsnEngines[kvType]
+	if !ok {
+		keys := make([]string, 0, len(dsnEngines))
+		for k := range dsnEngines {
+			keys = append(keys, string(k))
+		}
+		sort.Strings(keys)
+		return nil, fmt.Errorf("unknown kvType %q in DSN, expected one of %v",
+			u.Scheme, strings.Join(keys, ","))
+	}
+	if u.Host != "" {
+		return nil, fmt.Errorf("invalid DSN %q: unexpected host %q, use %s:///absolute/path or %s:relative/path",
+			dsn, u.Host, kvType, kvType)
+	}
+
+	path := u.Path
+	if path == "" {
+		path = u.Opaque
+	}
+	var name, dir string
+	if path != "" {
+		dir, name = filepath.Split(filepath.FromSlash(path))
+	}
+
+	params := &DSNParams{kvType: kvType, values: u.Query()}
+	db, err := engine(name, dir, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize database: %w", err)
+	}
+	return db, nil
+}
+
+// DSNParams gives typed access to the query parameters of a DSN. Every accessor records the
+// option as valid for the backend, leaves dst untouched when the option is absent, and remembers
+// the first parsing error, reported by Err.
+type DSNParams struct {
+	kvType KVType
+	values url.Values
+	valid  []string
+	err    error
+}
+
+func (p *DSNParams) lookup(key string) (string, bool) {
+	p.valid = append(p.valid, key)
+	vs, ok := p.values[key]
+	if !ok || len(vs) == 0 {
+		return "", false
+	}
+	return vs[len(vs)-1], true
+}
+
+func (p *DSNParams) fail(key, value, kind string, err error) {
+	if p.err == nil {
+		p.err = fmt.Errorf("invalid value %q for %s option %q, expected %s: %v",
+			value, p.kvType, key, kind, err)
+	}
+}
+
+// String reads a string option.
+func (p *DSNParams) String(key string, dst *string) {
+	if v, ok := p.lookup(key); ok {
+		*dst = v
+	}
+}
+
+// Bool reads a boolean option, as accepted by strconv.ParseBool.
+func (p *DSNParams) Bool(key string, dst *bool) {
+	v, ok := p.lookup(key)
+	if !ok {
+		return
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		p.fail(key, v, "a boolean", err)
+		return
+	}
+	*dst = b
+}
+
+// Int reads an integer option.
+func (p *DSNParams) Int(key string, dst *int) {
+	v, ok := p.lookup(key)
+	if !ok {
+		return
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		p.fail(key, v, "an integer", err)
+		return
+	}
+	*dst = i
+}
+
+// Float reads a floating point option.
+func (p *DSNParams) Float(key string, dst *float64) {
+	v, ok := p.lookup(key)
+	if !ok {
+		return
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		p.fail(key, v, "a number", err)
+		return
+	}
+	*dst = f
+}
+
+// Size reads a size in bytes, with an optional KB, MB, GB or TB suffix. Suffixes are binary
+// multiples, "KiB" and "K" are accepted as synonyms of "KB".
+func (p *DSNParams) Size(key string, dst *int64) {
+	v, ok := p.lookup(key)
+	if !ok {
+		return
+	}
+	size, err := ParseSize(v)
+	if err != nil {
+		p.fail(key, v, "a size such as 64MB", err)
+		return
+	}
+	*dst = size
+}
+
+// Duration reads a duration, as accepted by time.ParseDuration.
+func (p *DSNParams) Duration(key string, dst *time.Duration) {
+	v, ok := p.lookup(key)
+	if !ok {
+		return
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		p.fail(key, v, "a duration such as 1s", err)
+		return
+	}
+	*dst = d
+}
+
+// Err returns the first parsing error, or an error naming the options no accessor asked for
+// together with the list of valid ones.
+func (p *DSNParams) Err() error {
+	if p.err != nil {
+		return p.err
+	}
+	valid := make(map[string]bool, len(p.valid))
+	for _, k := range p.valid {
+		valid[k] = true
+	}
+	var unknown []string
+	for k := range p.values {
+		if !valid[k] {
+			unknown = append(unknown, k)
+		}
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+	sort.Strings(unknown)
+	keys := append([]string(nil), p.valid...)
+	sort.Strings(keys)
+	if len(keys) == 0 {
+		return fmt.Errorf("unknown %s options %s, %s takes no options",
+			p.kvType, strings.Join(unknown, ","), p.kvType)
+	}
+	return fmt.Errorf("unknown %s options %s, expected one of %s",
+		p.kvType, strings.Join(unknown, ","), strings.Join(keys, ","))
+}
+
+var sizeUnits = []struct {
+	suffix string
+	shift  uint
+}{
+	{"KIB", 10}, {"MIB", 20}, {"GIB", 30}, {"TIB", 40},
+	{"KB", 10}, {"MB", 20}, {"GB", 30}, {"TB", 40},
+	{"K", 10}, {"M", 20}, {"G", 30}, {"T", 40},
+	{"B", 0},
+}
+
+// ParseSize parses a size in bytes such as "4096", "64KB" or "1GiB". Suffixes are
+// case-insensitive binary multiples.
+func ParseSize(s string) (int64, error) {
+	str := strings.ToUpper(strings.TrimSpace(s))
+	var shift uint
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(str, u.suffix) {
+			str = strings.TrimSpace(strings.TrimSuffix(str, u.suffix))
+			shift = u.shift
+			break
+		}
+	}
+	n, err := strconv.ParseInt(str, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 || n > (1<<63-1)>>shift {
+		return 0, fmt.Errorf("size %q out of range", s)
+	}
+	return n << shift, nil
+}
@@ -0,0 +1,86 @@
+package locketdb_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/meission/locketdb"
+	_ "github.com/meission/locketdb/goleveldb"
+	_ "github.com/meission/locketdb/memdb"
+)
+
+func TestOpen(t *testing.T) {
+	dir := t.TempDir()
+	db, err := locketdb.Open("golevel://" + dir + "/foo?cache_size=1MB&sync=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Set([]byte("k"), []byte("v")); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The same database is reachable through NewDB.
+	db, err = locketdb.NewDB("foo", locketdb.GoLevelDB, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	value, err := db.Get([]byte("k"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(value) != "v" {
+		t.Fatalf("expected %q, got %q", "v", value)
+	}
+}
+
+func TestOpenErrors(t *testing.T) {
+	for dsn, want := range map[string]string{
+		"nosuchdb:///tmp/foo":                 "unknown kvType",
+		"golevel://host/foo":                  "unexpected host",
+		"golevel:///tmp/foo?cache=1MB":        "expected one of cache_size,compression,open_files_cache,sync,write_buffer",
+		"golevel:///tmp/foo?cache_size=lots":  `invalid value "lots"`,
+		"golevel:///tmp/foo?compression=zstd": "expected snappy or none",
+		"memdb:foo?sync=true":                 "memdb takes no options",
+	} {
+		db, err := locketdb.Open(dsn)
+		if err == nil {
+			db.Close()
+			t.Errorf("%s: expected error", dsn)
+			continue
+		}
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: expected error containing %q, got %q", dsn, want, err)
+		}
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	for s, want := range map[string]int64{
+		"0":     0,
+		"4096":  4096,
+		"64KB":  64 << 10,
+		"64kib": 64 << 10,
+		"256MB": 256 << 20,
+		"1G":    1 << 30,
+		"2 TiB": 2 << 40,
+		"512B":  512,
+	} {
+		got, err := locketdb.ParseSize(s)
+		if err != nil {
+			t.Errorf("%s: %v", s, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("%s: expected %d, got %d", s, want, got)
+		}
+	}
+	for _, s := range []string{"", "MB", "-1KB", "1.5GB", "9999999TB"} {
+		if _, err := locketdb.ParseSize(s); err == nil {
+			t.Errorf("%s: expected error", s)
+		}
+	}
+}
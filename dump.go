@@ -0,0 +1,287 @@
+package locketdb
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// A dump is a backend-neutral copy of a database, written by Dump and read by Restore. It is
+// made of a header, one record per key in ascending key order, and a trailer:
+//
+//	header:  "LOCKETDB" | version (1 byte) | uvarint len(kvType) | kvType | crc
+//	record:  uvarint len(key) | uvarint len(value) | key | value | crc
+//	trailer: uvarint 0 | uvarint number of records | crc
+//
+// where kvType is the backend type of the dumped database, empty if unknown, and crc is the
+// big-endian CRC-32 (Castagnoli) of the bytes of the header, record or trailer preceding it.
+// Keys are never empty, so the zero length of the trailer tells it apart from the records.
+const (
+	dumpMagic   = "LOCKETDB"
+	dumpVersion = 1
+)
+
+// maxDumpEntrySize bounds the length of keys and values read by Restore, so that a corrupted
+// length cannot make it allocate unbounded memory.
+const maxDumpEntrySize = 1 << 30
+
+var (
+	// ErrDumpCorrupted is returned by Restore when a dump fails its checksums or is malformed.
+	ErrDumpCorrupted = errors.New("dump is corrupted")
+
+	dumpCRCTable = crc32.MakeTable(crc32.Castagnoli)
+)
+
+// Dump writes every key of db to w, in the format described above. If db is a Snapshotter, the
+// keys are read from a snapshot, so the dump is consistent even if db is written concurrently.
+func Dump(db DB, w io.Writer) error {
+	view, release, err := consistentView(db)
+	if err != nil {
+		return err
+	}
+	defer release()
+	itr, err := view.Iterator(nil, nil)
+	if err != nil {
+		return err
+	}
+	defer itr.Close()
+
+	dw := &dumpWriter{w: bufio.NewWriter(w)}
+	dw.writeHeader(TypeOf(db))
+	var n uint64
+	for ; itr.Valid() && dw.err == nil; itr.Next() {
+		dw.writeRecord(itr.Key(), itr.Value())
+		n++
+	}
+	if err := itr.Error(); err != nil {
+		return err
+	}
+	dw.writeTrailer(n)
+	if dw.err != nil {
+		return dw.err
+	}
+	return dw.w.Flush()
+}
+
+// DumpContext is like Dump, but stops with the error of ctx once it is done. See ContextDB.
+func DumpContext(ctx context.Context, db DB, w io.Writer) error {
+	return Dump(NewContextDB(ctx, db), w)
+}
+
+// dumpWriter writes the parts of a dump, remembering the first error.
+type dumpWriter struct {
+	w   *bufio.Writer
+	buf []byte
+	err error
+}
+
+func (dw *dumpWriter) uvarint(x uint64) {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], x)
+	dw.buf = append(dw.buf, tmp[:n]...)
+}
+
+// flush writes the buffered part followed by its checksum.
+func (dw *dumpWriter) flush() {
+	var crc [4]byte
+	binary.BigEndian.PutUint32(crc[:], crc32.Checksum(dw.buf, dumpCRCTable))
+	dw.buf = append(dw.buf, crc[:]...)
+	if dw.err == nil {
+		_, dw.err = dw.w.Write(dw.buf)
+	}
+	dw.buf = dw.buf[:0]
+}
+
+func (dw *dumpWriter) writeHeader(kvType KVType) {
+	dw.buf = append(dw.buf, dumpMagic...)
+	dw.buf = append(dw.buf, dumpVersion)
+	dw.uvarint(uint64(len(kvType)))
+	dw.buf = append(dw.buf, kvType...)
+	dw.flush()
+}
+
+func (dw *dumpWriter) writeRecord(key, value []byte) {
+	dw.uvarint(uint64(len(key)))
+	dw.uvarint(uint64(len(value)))
+	dw.buf = append(dw.buf, key...)
+	dw.buf = append(dw.buf, value...)
+	dw.flush()
+}
+
+func (dw *dumpWriter) writeTrailer(n uint64) {
+	dw.uvarint(0)
+	dw.uvarint(n)
+	dw.flush()
+}
+
+// Restore writes the keys of a dump read from r into db, through batches holding up to a few
+// megabytes each.
+//
+// Keys already in db are overwritten, and keys absent from the dump are left untouched. Restore
+// is not atomic: if it fails, for instance on a truncated or corrupted dump, the batches written
+// until then remain in db.
+func Restore(db DB, r io.Reader) error {
+	dr := &dumpReader{r: bufio.NewReader(r)}
+	if _, err := dr.readHeader(); err != nil {
+		return err
+	}
+
+	bw := newBulkWriter(db, defaultBulkBatchSize)
+	defer bw.close()
+	var n uint64
+	for {
+		key, value, err := dr.readRecord()
+		if err != nil {
+			return err
+		}
+		if key == nil {
+			break
+		}
+		if err := bw.set(key, value); err != nil {
+			return err
+		}
+		n++
+	}
+
+	count, err := dr.readTrailer()
+	if err != nil {
+		return err
+	}
+	if count != n {
+		return fmt.Errorf("%w: trailer counts %d records, read %d", ErrDumpCorrupted, count, n)
+	}
+	return bw.flush(true)
+}
+
+// RestoreContext is like Restore, but stops with the error of ctx once it is done, leaving the
+// batches written until then in db. See ContextDB.
+func RestoreContext(ctx context.Context, db DB, r io.Reader) error {
+	return Restore(NewContextDB(ctx, db), r)
+}
+
+// dumpReader reads the parts of a dump, checksumming the bytes read.
+type dumpReader struct {
+	r   *bufio.Reader
+	crc uint32
+	// err is the last error of r, to tell it apart from malformed varints.
+	err error
+}
+
+// ReadByte implements io.ByteReader, for binary.ReadUvarint.
+func (dr *dumpReader) ReadByte() (byte, error) {
+	b, err := dr.r.ReadByte()
+	if err != nil {
+		dr.err = unexpectedEOF(err)
+		return 0, dr.err
+	}
+	dr.crc = crc32.Update(dr.crc, dumpCRCTable, []byte{b})
+	return b, nil
+}
+
+func (dr *dumpReader) readFull(n uint64) ([]byte, error) {
+	if n > maxDumpEntrySize {
+		return nil, fmt.Errorf("%w: entry of %d bytes", ErrDumpCorrupted, n)
+	}
+	bz := make([]byte, n)
+	if _, err := io.ReadFull(dr.r, bz); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	dr.crc = crc32.Update(dr.crc, dumpCRCTable, bz)
+	return bz, nil
+}
+
+func (dr *dumpReader) readUvarint() (uint64, error) {
+	x, err := binary.ReadUvarint(dr)
+	if err != nil {
+		if dr.err != nil {
+			return 0, dr.err
+		}
+		return 0, fmt.Errorf("%w: %v", ErrDumpCorrupted, err)
+	}
+	return x, nil
+}
+
+// checkCRC reads the checksum ending the current part and compares it with the bytes read.
+func (dr *dumpReader) checkCRC() error {
+	want := dr.crc
+	dr.crc = 0
+	var crc [4]byte
+	if _, err := io.ReadFull(dr.r, crc[:]); err != nil {
+		return unexpectedEOF(err)
+	}
+	if binary.BigEndian.Uint32(crc[:]) != want {
+		return fmt.Errorf("%w: checksum mismatch", ErrDumpCorrupted)
+	}
+	return nil
+}
+
+func (dr *dumpReader) readHeader() (KVType, error) {
+	magic, err := dr.readFull(uint64(len(dumpMagic) + 1))
+	if err != nil {
+		return "", err
+	}
+	if string(magic[:len(dumpMagic)]) != dumpMagic {
+		return "", fmt.Errorf("%w: not a locketdb dump", ErrDumpCorrupted)
+	}
+	if v := magic[len(dumpMagic)]; v != dumpVersion {
+		return "", fmt.Errorf("unsupported dump version %d", v)
+	}
+	n, err := dr.readUvarint()
+	if err != nil {
+		return "", err
+	}
+	kvType, err := dr.readFull(n)
+	if err != nil {
+		return "", err
+	}
+	if err := dr.checkCRC(); err != nil {
+		return "", err
+	}
+	return KVType(kvType), nil
+}
+
+// readRecord reads the next record, or returns a nil key once it reaches the trailer.
+func (dr *dumpReader) readRecord() (key, value []byte, err error) {
+	keyLen, err := dr.readUvarint()
+	if err != nil || keyLen == 0 {
+		return nil, nil, err
+	}
+	valueLen, err := dr.readUvarint()
+	if err != nil {
+		return nil, nil, err
+	}
+	if key, err = dr.readFull(keyLen); err != nil {
+		return nil, nil, err
+	}
+	if value, err = dr.readFull(valueLen); err != nil {
+		return nil, nil, err
+	}
+	if err := dr.checkCRC(); err != nil {
+		return nil, nil, err
+	}
+	return key, value, nil
+}
+
+// readTrailer reads the rest of the trailer, whose zero key length was consumed by readRecord.
+func (dr *dumpReader) readTrailer() (uint64, error) {
+	n, err := dr.readUvarint()
+	if err != nil {
+		return 0, err
+	}
+	if err := dr.checkCRC(); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// unexpectedEOF turns io.EOF into io.ErrUnexpectedEOF, since a dump always ends with a trailer.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,109 @@
+package locketdb_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/meission/locketdb"
+	"github.com/meission/locketdb/memdb"
+)
+
+func TestDumpRestore(t *testing.T) {
+	src, err := locketdb.NewDB("src", locketdb.GoLevelDB, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	want := map[string]string{"empty": ""}
+	for i := 0; i < 1000; i++ {
+		k, v := fmt.Sprintf("key%04d", i), fmt.Sprintf("value%d", i)
+		want[k] = v
+	}
+	for k, v := range want {
+		if err := src.Set([]byte(k), []byte(v)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var dump bytes.Buffer
+	if err := locketdb.Dump(src, &dump); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(dump.Bytes()[:20], []byte(locketdb.GoLevelDB)) {
+		t.Errorf("expected the header to record %s", locketdb.GoLevelDB)
+	}
+
+	for _, kvType := range []locketdb.KVType{locketdb.Pebble, locketdb.BoltDB, locketdb.MemDB} {
+		t.Run(string(kvType), func(t *testing.T) {
+			dst, err := locketdb.NewDB("dst", kvType, t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer dst.Close()
+			if err := locketdb.Restore(dst, bytes.NewReader(dump.Bytes())); err != nil {
+				t.Fatal(err)
+			}
+			got := make(map[string]string)
+			itr, err := dst.Iterator(nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for ; itr.Valid(); itr.Next() {
+				got[string(itr.Key())] = string(itr.Value())
+			}
+			itr.Close()
+			if len(got) != len(want) {
+				t.Fatalf("expected %d keys, got %d", len(want), len(got))
+			}
+			for k, v := range want {
+				if got[k] != v {
+					t.Errorf("%s: expected %q, got %q", k, v, got[k])
+				}
+			}
+		})
+	}
+}
+
+func TestRestoreCorrupted(t *testing.T) {
+	src, err := memdb.NewDB("src", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{"a", "b", "c"} {
+		if err := src.Set([]byte(k), []byte("value")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var dump bytes.Buffer
+	if err := locketdb.Dump(src, &dump); err != nil {
+		t.Fatal(err)
+	}
+	bz := dump.Bytes()
+
+	restore := func(bz []byte) error {
+		dst, err := memdb.NewDB("dst", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer dst.Close()
+		return locketdb.Restore(dst, bytes.NewReader(bz))
+	}
+	if err := restore(bz); err != nil {
+		t.Fatal(err)
+	}
+	for i := range bz {
+		flipped := append([]byte(nil), bz...)
+		flipped[i] ^= 0x01
+		if err := restore(flipped); err == nil {
+			t.Errorf("expected an error with byte %d flipped", i)
+		}
+	}
+	for i := range bz {
+		if err := restore(bz[:i]); !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, locketdb.ErrDumpCorrupted) {
+			t.Errorf("expected an error truncated at %d bytes, got %v", i, err)
+		}
+	}
+}
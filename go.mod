@@ -5,6 +5,7 @@ go 1.16
 require (
 	github.com/cockroachdb/pebble v0.0.0-20210713174350-b8f537d8e17c
 	github.com/dgraph-io/badger/v3 v3.2103.1
+	github.com/dgraph-io/ristretto v0.1.0
 	github.com/kr/pretty v0.2.0 // indirect
 	github.com/syndtr/goleveldb v1.0.0
 	go.etcd.io/bbolt v1.3.6
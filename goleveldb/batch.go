@@ -3,7 +3,6 @@ package goleveldb
 import (
 	"github.com/meission/locketdb"
 	"github.com/syndtr/goleveldb/leveldb"
-	"github.com/syndtr/goleveldb/leveldb/opt"
 )
 
 type goLevelDBBatch struct {
@@ -11,7 +10,10 @@ type goLevelDBBatch struct {
 	batch *leveldb.Batch
 }
 
-var _ locketdb.Batch = (*goLevelDBBatch)(nil)
+var (
+	_ locketdb.Batch        = (*goLevelDBBatch)(nil)
+	_ locketdb.RangeDeleter = (*goLevelDBBatch)(nil)
+)
 
 func newGoLevelDBBatch(db *goLevelDB) *goLevelDBBatch {
 	return &goLevelDBBatch{
@@ -47,6 +49,48 @@ func (b *goLevelDBBatch) Delete(key []byte) error {
 	return nil
 }
 
+// DeleteRange implements RangeDeleter. It queues a delete for every key in the range, both those
+// in the database when DeleteRange is called and those set earlier in the batch. Like
+// goLevelDB.DeleteRange, it fails with locketdb.ErrRangeTooLarge, leaving the batch as it was,
+// if the database holds more than deleteRangeLimit keys in the range.
+func (b *goLevelDBBatch) DeleteRange(start, end []byte) error {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return locketdb.ErrKeyEmpty
+	}
+	if b.batch == nil {
+		return locketdb.ErrBatchClosed
+	}
+	if locketdb.IsEmptyRange(start, end) {
+		return nil
+	}
+	queued := &rangeKeys{start: start, end: end}
+	if err := b.batch.Replay(queued); err != nil {
+		return err
+	}
+	keys, err := b.db.keysInRange(start, end)
+	if err != nil {
+		return err
+	}
+	for _, key := range append(queued.keys, keys...) {
+		b.batch.Delete(key)
+	}
+	return nil
+}
+
+// rangeKeys is a leveldb.BatchReplay collecting the keys put within [start, end).
+type rangeKeys struct {
+	start, end []byte
+	keys       [][]byte
+}
+
+func (r *rangeKeys) Put(key, value []byte) {
+	if locketdb.IsKeyInDomain(key, r.start, r.end) {
+		r.keys = append(r.keys, cp(key))
+	}
+}
+
+func (r *rangeKeys) Delete(key []byte) {}
+
 // Write implements Batch.
 func (b *goLevelDBBatch) Write() error {
 	return b.write(false)
@@ -61,7 +105,7 @@ func (b *goLevelDBBatch) write(sync bool) error {
 	if b.batch == nil {
 		return locketdb.ErrBatchClosed
 	}
-	err := b.db.db.Write(b.batch, &opt.WriteOptions{Sync: sync})
+	err := b.db.db.Write(b.batch, b.db.writeOptions(sync))
 	if err != nil {
 		return err
 	}
@@ -3,6 +3,8 @@ package goleveldb
 import (
 	"fmt"
 	"path/filepath"
+	"strconv"
+	"sync"
 
 	"github.com/meission/locketdb"
 	"github.com/syndtr/goleveldb/leveldb"
@@ -13,12 +15,32 @@ import (
 
 type goLevelDB struct {
 	db *leveldb.DB
+	// sync makes every write durable, as if the Sync variants were used.
+	sync bool
+	// readOnly makes every write fail with ErrReadOnly.
+	readOnly bool
+
+	// txnMtx serializes the commits of optimistic transactions.
+	txnMtx sync.Mutex
+	// keyLocks serializes the conditional writes of each key.
+	keyLocks locketdb.KeyLocks
 }
 
-var _ locketdb.DB = (*goLevelDB)(nil)
+var (
+	_ locketdb.DB                = (*goLevelDB)(nil)
+	_ locketdb.Snapshotter       = (*goLevelDB)(nil)
+	_ locketdb.Transactional     = (*goLevelDB)(nil)
+	_ locketdb.RangeDeleter      = (*goLevelDB)(nil)
+	_ locketdb.Compacter         = (*goLevelDB)(nil)
+	_ locketdb.KVTyper           = (*goLevelDB)(nil)
+	_ locketdb.MetricsReporter   = (*goLevelDB)(nil)
+	_ locketdb.ConditionalWriter = (*goLevelDB)(nil)
+)
 
 func init() {
 	locketdb.RegisterEngine(locketdb.GoLevelDB, NewDB)
+	locketdb.RegisterDSNEngine(locketdb.GoLevelDB, OpenDSN)
+	locketdb.RegisterOptionsEngine(locketdb.GoLevelDB, OpenWithOptions)
 }
 func NewDB(name string, dir string) (locketdb.DB, error) {
 	return NewDBWithOpts(name, dir, nil)
@@ -30,7 +52,31 @@ func NewDBWithOpts(name string, dir string, o *opt.Options) (*goLevelDB, error)
 	if err != nil {
 		return nil, err
 	}
-	return &goLevelDB{db: db}, nil
+	return &goLevelDB{db: db, readOnly: o.GetReadOnly()}, nil
+}
+
+// OpenWithOptions opens a database with the common options, see locketdb.NewDBWithOptions.
+// FileMode is not supported, goleveldb always creates its files with mode 0644. With a
+// MergeOperator, the database is wrapped in a locketdb.MergeDB, see locketdb.OpenMergeDB.
+func OpenWithOptions(name string, dir string, opts locketdb.Options) (locketdb.DB, error) {
+	if opts.FileMode != 0 {
+		return nil, locketdb.OptionNotSupported(locketdb.GoLevelDB, "FileMode")
+	}
+	db, err := NewDBWithOpts(name, dir, &opt.Options{
+		ReadOnly:           opts.ReadOnly,
+		BlockCacheCapacity: int(opts.CacheSize),
+		ErrorIfMissing:     opts.ErrorIfMissing,
+	})
+	if err != nil {
+		return nil, err
+	}
+	db.sync = opts.Sync
+	mdb, err := locketdb.OpenMergeDB(db, opts.MergeOperator)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return mdb, nil
 }
 
 // Get implements DB.
@@ -62,7 +108,10 @@ func (db *goLevelDB) Set(key []byte, value []byte) error {
 	if value == nil {
 		return locketdb.ErrValueNil
 	}
-	return db.db.Put(key, value, nil)
+	if db.readOnly {
+		return locketdb.ErrReadOnly
+	}
+	return db.db.Put(key, value, db.writeOptions(false))
 }
 
 // SetSync implements DB.
@@ -73,7 +122,10 @@ func (db *goLevelDB) SetSync(key []byte, value []byte) error {
 	if value == nil {
 		return locketdb.ErrValueNil
 	}
-	return db.db.Put(key, value, &opt.WriteOptions{Sync: true})
+	if db.readOnly {
+		return locketdb.ErrReadOnly
+	}
+	return db.db.Put(key, value, db.writeOptions(true))
 }
 
 // Delete implements DB.
@@ -81,7 +133,10 @@ func (db *goLevelDB) Delete(key []byte) error {
 	if len(key) == 0 {
 		return locketdb.ErrKeyEmpty
 	}
-	return db.db.Delete(key, nil)
+	if db.readOnly {
+		return locketdb.ErrReadOnly
+	}
+	return db.db.Delete(key, db.writeOptions(false))
 }
 
 // DeleteSync implements DB.
@@ -89,13 +144,104 @@ func (db *goLevelDB) DeleteSync(key []byte) error {
 	if len(key) == 0 {
 		return locketdb.ErrKeyEmpty
 	}
-	return db.db.Delete(key, &opt.WriteOptions{Sync: true})
+	if db.readOnly {
+		return locketdb.ErrReadOnly
+	}
+	return db.db.Delete(key, db.writeOptions(true))
+}
+
+// CompareAndSwap implements ConditionalWriter. The conditional writes of a key are serialized by
+// a striped lock, plain writes racing with them are not.
+func (db *goLevelDB) CompareAndSwap(key, expected, new []byte) (bool, error) {
+	if len(key) == 0 {
+		return false, locketdb.ErrKeyEmpty
+	}
+	if db.readOnly {
+		return false, locketdb.ErrReadOnly
+	}
+	return locketdb.CompareAndSwapLocked(db, &db.keyLocks, key, expected, new)
+}
+
+// SetIfAbsent implements ConditionalWriter.
+func (db *goLevelDB) SetIfAbsent(key, value []byte) (bool, error) {
+	if len(key) == 0 {
+		return false, locketdb.ErrKeyEmpty
+	}
+	if value == nil {
+		return false, locketdb.ErrValueNil
+	}
+	return db.CompareAndSwap(key, nil, value)
+}
+
+// deleteRangeLimit is the largest number of keys DeleteRange deletes, in a single batch.
+const deleteRangeLimit = 100000
+
+// DeleteRange implements RangeDeleter. goleveldb has no range tombstones, so a delete is written
+// for every key of the range, in a single batch. It fails with locketdb.ErrRangeTooLarge rather
+// than hold more than deleteRangeLimit deletes in memory, and keys written to the range after
+// DeleteRange is called are kept.
+func (db *goLevelDB) DeleteRange(start, end []byte) error {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return locketdb.ErrKeyEmpty
+	}
+	if db.readOnly {
+		return locketdb.ErrReadOnly
+	}
+	if locketdb.IsEmptyRange(start, end) {
+		return nil
+	}
+	keys, err := db.keysInRange(start, end)
+	if err != nil || len(keys) == 0 {
+		return err
+	}
+	batch := new(leveldb.Batch)
+	for _, key := range keys {
+		batch.Delete(key)
+	}
+	return db.db.Write(batch, db.writeOptions(false))
+}
+
+// keysInRange returns copies of the keys in [start, end), or fails with
+// locketdb.ErrRangeTooLarge if there are more than deleteRangeLimit of them.
+func (db *goLevelDB) keysInRange(start, end []byte) ([][]byte, error) {
+	itr := db.db.NewIterator(&util.Range{Start: start, Limit: end}, nil)
+	defer itr.Release()
+	var keys [][]byte
+	for itr.Next() {
+		if len(keys) >= deleteRangeLimit {
+			return nil, fmt.Errorf("%w: more than %d keys", locketdb.ErrRangeTooLarge, deleteRangeLimit)
+		}
+		keys = append(keys, cp(itr.Key()))
+	}
+	return keys, itr.Error()
+}
+
+// Compact implements Compacter.
+func (db *goLevelDB) Compact(start, end []byte) error {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return locketdb.ErrKeyEmpty
+	}
+	if db.readOnly {
+		return locketdb.ErrReadOnly
+	}
+	return db.db.CompactRange(util.Range{Start: start, Limit: end})
+}
+
+// writeOptions returns the options of a write, which is synced if sync is set or if the database
+// was opened with sync.
+func (db *goLevelDB) writeOptions(sync bool) *opt.WriteOptions {
+	return &opt.WriteOptions{Sync: sync || db.sync}
 }
 
 func (db *goLevelDB) DB() *leveldb.DB {
 	return db.db
 }
 
+// KVType implements KVTyper.
+func (db *goLevelDB) KVType() locketdb.KVType {
+	return locketdb.GoLevelDB
+}
+
 // Close implements DB.
 func (db *goLevelDB) Close() error {
 	return db.db.Close()
@@ -118,10 +264,52 @@ func (db *goLevelDB) Print() error {
 	return nil
 }
 
-// Stats implements DB.
+// Metrics implements MetricsReporter. The disk size is that of the tables, without the journal
+// of the memtable. Metrics are all unknown if the database is closed.
+func (db *goLevelDB) Metrics() locketdb.Metrics {
+	var ds leveldb.DBStats
+	if err := db.db.Stats(&ds); err != nil {
+		return locketdb.Metrics{}
+	}
+	var size int64
+	for _, levelSize := range ds.LevelSizes {
+		size += levelSize
+	}
+	return locketdb.Metrics{
+		DiskBytes:     locketdb.KnownMetric(uint64(size)),
+		OpenIterators: locketdb.KnownMetric(uint64(ds.AliveIterators)),
+	}
+}
+
+// Stats implements DB. It returns nil if the database is closed.
 func (db *goLevelDB) Stats() map[string]string {
-	keys := []string{
-		"leveldb.num-files-at-level{n}",
+	var ds leveldb.DBStats
+	if err := db.db.Stats(&ds); err != nil {
+		return nil
+	}
+	stats := make(map[string]string)
+	set := func(value interface{}, names ...string) {
+		stats[locketdb.StatsKey(locketdb.GoLevelDB, names...)] = fmt.Sprint(value)
+	}
+
+	for level := range ds.LevelSizes {
+		n := strconv.Itoa(level)
+		set(ds.LevelTablesCounts[level], "level", n, "tables")
+		set(ds.LevelSizes[level], "level", n, "size_bytes")
+		set(ds.LevelRead[level], "level", n, "read_bytes")
+		set(ds.LevelWrite[level], "level", n, "written_bytes")
+	}
+	set(ds.IORead, "io", "read_bytes")
+	set(ds.IOWrite, "io", "written_bytes")
+	set(ds.BlockCacheSize, "block_cache", "size_bytes")
+	set(ds.OpenedTablesCount, "table_cache", "tables")
+	set(ds.AliveSnapshots, "snapshot", "open")
+	set(ds.AliveIterators, "iterator", "open")
+	set(ds.WriteDelayCount, "write_delay", "count")
+	set(ds.WriteDelayDuration.Milliseconds(), "write_delay", "duration_ms")
+
+	// Keys reported before StatsKey.
+	legacy := []string{
 		"leveldb.stats",
 		"leveldb.sstables",
 		"leveldb.blockpool",
@@ -130,19 +318,31 @@ func (db *goLevelDB) Stats() map[string]string {
 		"leveldb.alivesnaps",
 		"leveldb.aliveiters",
 	}
-
-	stats := make(map[string]string)
-	for _, key := range keys {
-		str, err := db.db.GetProperty(key)
-		if err == nil {
+	for level := range ds.LevelSizes {
+		legacy = append(legacy, "leveldb.num-files-at-level"+strconv.Itoa(level))
+	}
+	for _, key := range legacy {
+		if str, err := db.db.GetProperty(key); err == nil {
 			stats[key] = str
 		}
 	}
 	return stats
 }
 
+// Begin implements Transactional. Transactions are optimistic: they read from a snapshot and fail
+// to commit with ErrConflict if anything they read was modified in the meantime.
+func (db *goLevelDB) Begin(writable bool) (locketdb.Txn, error) {
+	if writable && db.readOnly {
+		return nil, locketdb.ErrReadOnly
+	}
+	return locketdb.NewOptimisticTxn(db, &db.txnMtx, writable)
+}
+
 // NewBatch implements DB.
 func (db *goLevelDB) NewBatch() locketdb.Batch {
+	if db.readOnly {
+		return locketdb.NewReadOnlyBatch()
+	}
 	return newGoLevelDBBatch(db)
 }
 
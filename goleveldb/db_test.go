@@ -0,0 +1,144 @@
+package goleveldb
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/meission/locketdb"
+	"github.com/meission/locketdb/locketdbtest"
+)
+
+func TestConformance(t *testing.T) {
+	dir := t.TempDir()
+	n := 0
+	locketdbtest.RunConformance(t, func() locketdb.DB {
+		n++
+		db, err := NewDB(fmt.Sprintf("test%d", n), dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return db
+	})
+}
+
+func TestMergeConformance(t *testing.T) {
+	dir := t.TempDir()
+	n := 0
+	locketdbtest.RunMergeConformance(t, func(op locketdb.MergeOperator) locketdb.DB {
+		n++
+		db, err := OpenWithOptions(fmt.Sprintf("test%d", n), dir, locketdb.Options{MergeOperator: op})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return db
+	})
+}
+
+func TestStats(t *testing.T) {
+	db, err := NewDB("test", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.Set([]byte("key"), []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Levels are only reported once they hold tables.
+	if err := locketdb.Compact(db, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := db.Stats()
+	for _, key := range []string{"golevel.level.0.tables", "golevel.io.written_bytes",
+		"leveldb.stats", "leveldb.sstables", "leveldb.num-files-at-level0", "leveldb.aliveiters"} {
+		if _, ok := stats[key]; !ok {
+			t.Errorf("Stats() is missing %q", key)
+		}
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	db, err := NewDB("test", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	itr, err := db.Iterator(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer itr.Close()
+
+	m := locketdb.MetricsOf(db)
+	if m.OpenIterators != locketdb.KnownMetric(1) || !m.DiskBytes.Known {
+		t.Errorf("OpenIterators = %v, DiskBytes = %v, want 1 and known", m.OpenIterators, m.DiskBytes)
+	}
+	if m.LiveKeys.Known || m.CacheHits.Known || m.OpenTxns.Known {
+		t.Errorf("expected the metrics goleveldb does not report to be unknown: %+v", m)
+	}
+}
+
+func TestDeleteRangeLimit(t *testing.T) {
+	db, err := NewDB("test", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	batch := db.NewBatch()
+	for i := 0; i <= deleteRangeLimit; i++ {
+		if err := batch.Set([]byte(fmt.Sprintf("k%06d", i)), []byte("v")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, key := range []string{"a", "z"} {
+		if err := batch.Set([]byte(key), []byte("v")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := batch.Write(); err != nil {
+		t.Fatal(err)
+	}
+	batch.Close()
+	count := func() int {
+		itr, err := db.Iterator(nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer itr.Close()
+		n := 0
+		for ; itr.Valid(); itr.Next() {
+			n++
+		}
+		return n
+	}
+
+	// A range too large is not deleted at all, from the database nor from a batch.
+	if err := locketdb.DeleteRange(db, []byte("k"), []byte("l")); !errors.Is(err, locketdb.ErrRangeTooLarge) {
+		t.Fatalf("DeleteRange over %d keys: got %v, want ErrRangeTooLarge", deleteRangeLimit+1, err)
+	}
+	batch = db.NewBatch()
+	defer batch.Close()
+	rd := batch.(locketdb.RangeDeleter)
+	if err := rd.DeleteRange([]byte("k"), []byte("l")); !errors.Is(err, locketdb.ErrRangeTooLarge) {
+		t.Fatalf("batch DeleteRange over %d keys: got %v, want ErrRangeTooLarge", deleteRangeLimit+1, err)
+	}
+	if err := rd.DeleteRange([]byte("k"), []byte("k050000")); err != nil {
+		t.Fatal(err)
+	}
+	if err := batch.Write(); err != nil {
+		t.Fatal(err)
+	}
+	if n, want := count(), deleteRangeLimit+3-50000; n != want {
+		t.Fatalf("%d keys after deleting k000000 to k049999, want %d", n, want)
+	}
+
+	if err := locketdb.DeleteRange(db, []byte("k"), []byte("l")); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(); n != 2 {
+		t.Errorf("%d keys after DeleteRange, want 2", n)
+	}
+}
@@ -0,0 +1,55 @@
+package goleveldb
+
+import (
+	"fmt"
+
+	"github.com/meission/locketdb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+)
+
+// OpenDSN opens a database from a DSN, see locketdb.Open. The supported options are:
+//
+//	cache_size        block cache capacity (size)
+//	write_buffer      memtable size (size)
+//	open_files_cache  number of open files kept in cache (integer)
+//	compression       "snappy" (default) or "none"
+//	sync              sync every write to disk (boolean)
+func OpenDSN(name string, dir string, params *locketdb.DSNParams) (locketdb.DB, error) {
+	if name == "" {
+		return nil, fmt.Errorf("DSN must include the database path")
+	}
+	var (
+		o                      opt.Options
+		cacheSize, writeBuffer int64
+		compression            string
+		sync                   bool
+	)
+	params.Size("cache_size", &cacheSize)
+	params.Size("write_buffer", &writeBuffer)
+	params.Int("open_files_cache", &o.OpenFilesCacheCapacity)
+	params.String("compression", &compression)
+	params.Bool("sync", &sync)
+	if err := params.Err(); err != nil {
+		return nil, err
+	}
+
+	o.BlockCacheCapacity = int(cacheSize)
+	o.WriteBuffer = int(writeBuffer)
+	switch compression {
+	case "":
+	case "snappy":
+		o.Compression = opt.SnappyCompression
+	case "none":
+		o.Compression = opt.NoCompression
+	default:
+		return nil, fmt.Errorf("invalid value %q for %s option %q, expected snappy or none",
+			compression, locketdb.GoLevelDB, "compression")
+	}
+
+	db, err := NewDBWithOpts(name, dir, &o)
+	if err != nil {
+		return nil, err
+	}
+	db.sync = sync
+	return db, nil
+}
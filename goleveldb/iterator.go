@@ -15,37 +15,19 @@ type goLevelDBIterator struct {
 	isInvalid bool
 }
 
-var _ locketdb.Iterator = (*goLevelDBIterator)(nil)
+var _ locketdb.SeekIterator = (*goLevelDBIterator)(nil)
 
+// newGoLevelDBIterator wraps iter, which must be bounded to [start, end) already.
 func newGoLevelDBIterator(iter iterator.Iterator, start, end []byte, isReverse bool) *goLevelDBIterator {
-	if isReverse {
-		if end == nil {
-			iter.Last()
-		} else {
-			valid := iter.Seek(end)
-			if valid {
-				eoakey := iter.Key() // end or after key
-				if bytes.Compare(end, eoakey) <= 0 {
-					iter.Prev()
-				}
-			} else {
-				iter.Last()
-			}
-		}
-	} else {
-		if start == nil {
-			iter.First()
-		} else {
-			iter.Seek(start)
-		}
-	}
-	return &goLevelDBIterator{
+	itr := &goLevelDBIterator{
 		iter:      iter,
 		start:     start,
 		end:       end,
 		isReverse: isReverse,
 		isInvalid: false,
 	}
+	itr.First()
+	return itr
 }
 
 // Domain implements Iterator.
@@ -73,19 +55,9 @@ func (iter *goLevelDBIterator) Valid() bool {
 		return false
 	}
 
-	key := iter.iter.Key()
-	if iter.isReverse {
-		start := iter.start
-		if start != nil && bytes.Compare(key, start) < 0 {
-			iter.isInvalid = true
-			return false
-		}
-	} else {
-		end := iter.end
-		if end != nil && bytes.Compare(end, key) <= 0 {
-			iter.isInvalid = true
-			return false
-		}
+	if !locketdb.IsKeyInDomain(iter.iter.Key(), iter.start, iter.end) {
+		iter.isInvalid = true
+		return false
 	}
 	return true
 }
@@ -118,6 +90,51 @@ func (iter *goLevelDBIterator) Next() {
 	}
 }
 
+// Prev implements SeekIterator.
+func (iter *goLevelDBIterator) Prev() {
+	iter.assertIsValid()
+	if iter.isReverse {
+		iter.iter.Next()
+	} else {
+		iter.iter.Prev()
+	}
+}
+
+// Seek implements SeekIterator.
+func (iter *goLevelDBIterator) Seek(key []byte) {
+	iter.isInvalid = false
+	if !iter.isReverse {
+		iter.iter.Seek(key)
+		return
+	}
+	// The greatest key <= key is either key itself or the one before the first key after it.
+	if !iter.iter.Seek(key) {
+		iter.iter.Last()
+	} else if !bytes.Equal(iter.iter.Key(), key) {
+		iter.iter.Prev()
+	}
+}
+
+// First implements SeekIterator.
+func (iter *goLevelDBIterator) First() {
+	iter.isInvalid = false
+	if iter.isReverse {
+		iter.iter.Last()
+	} else {
+		iter.iter.First()
+	}
+}
+
+// Last implements SeekIterator.
+func (iter *goLevelDBIterator) Last() {
+	iter.isInvalid = false
+	if iter.isReverse {
+		iter.iter.First()
+	} else {
+		iter.iter.Last()
+	}
+}
+
 // Error implements Iterator.
 func (iter *goLevelDBIterator) Error() error {
 	return iter.iter.Error()
@@ -0,0 +1,67 @@
+package goleveldb
+
+import (
+	"github.com/meission/locketdb"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/errors"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+type goLevelDBSnapshot struct {
+	snap *leveldb.Snapshot
+}
+
+var _ locketdb.Snapshot = (*goLevelDBSnapshot)(nil)
+
+// NewSnapshot implements Snapshotter.
+func (db *goLevelDB) NewSnapshot() (locketdb.Snapshot, error) {
+	snap, err := db.db.GetSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	return &goLevelDBSnapshot{snap: snap}, nil
+}
+
+// Get implements Snapshot.
+func (s *goLevelDBSnapshot) Get(key []byte) ([]byte, error) {
+	if len(key) == 0 {
+		return nil, locketdb.ErrKeyEmpty
+	}
+	res, err := s.snap.Get(key, nil)
+	if err == errors.ErrNotFound {
+		return nil, nil
+	}
+	return res, err
+}
+
+// Has implements Snapshot.
+func (s *goLevelDBSnapshot) Has(key []byte) (bool, error) {
+	if len(key) == 0 {
+		return false, locketdb.ErrKeyEmpty
+	}
+	return s.snap.Has(key, nil)
+}
+
+// Iterator implements Snapshot.
+func (s *goLevelDBSnapshot) Iterator(start, end []byte) (locketdb.Iterator, error) {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return nil, locketdb.ErrKeyEmpty
+	}
+	itr := s.snap.NewIterator(&util.Range{Start: start, Limit: end}, nil)
+	return newGoLevelDBIterator(itr, start, end, false), nil
+}
+
+// ReverseIterator implements Snapshot.
+func (s *goLevelDBSnapshot) ReverseIterator(start, end []byte) (locketdb.Iterator, error) {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return nil, locketdb.ErrKeyEmpty
+	}
+	itr := s.snap.NewIterator(&util.Range{Start: start, Limit: end}, nil)
+	return newGoLevelDBIterator(itr, start, end, true), nil
+}
+
+// Close implements Snapshot.
+func (s *goLevelDBSnapshot) Close() error {
+	s.snap.Release()
+	return nil
+}
@@ -0,0 +1,230 @@
+package locketdb
+
+import (
+	"time"
+)
+
+// InstrumentedDB wraps a database to record its operations with a Collector: the name, namespace,
+// latency, size and error of every read and write, of every batch written, and of every
+// iteration, from the creation of its iterator until it is closed. Snapshots and transactions are
+// not recorded.
+//
+// The namespace of the operations made through NewInstrumentedDB is the prefix of db if it is a
+// PrefixDB, and empty otherwise. Prefix returns the InstrumentedDB of a namespace.
+type InstrumentedDB struct {
+	db        DB
+	collector Collector
+	namespace string
+}
+
+var (
+	_ DB                = (*InstrumentedDB)(nil)
+	_ Snapshotter       = (*InstrumentedDB)(nil)
+	_ Transactional     = (*InstrumentedDB)(nil)
+	_ RangeDeleter      = (*InstrumentedDB)(nil)
+	_ Compacter         = (*InstrumentedDB)(nil)
+	_ KVTyper           = (*InstrumentedDB)(nil)
+	_ MetricsReporter   = (*InstrumentedDB)(nil)
+	_ TTLSetter         = (*InstrumentedDB)(nil)
+	_ ConditionalWriter = (*InstrumentedDB)(nil)
+	_ Merger            = (*InstrumentedDB)(nil)
+)
+
+// NewInstrumentedDB records the operations made on db with collector.
+func NewInstrumentedDB(db DB, collector Collector) *InstrumentedDB {
+	var namespace string
+	if pdb, ok := db.(*PrefixDB); ok {
+		namespace = string(pdb.prefix)
+	}
+	return &InstrumentedDB{
+		db:        db,
+		collector: collector,
+		namespace: namespace,
+	}
+}
+
+// Prefix returns an InstrumentedDB over the keys of the namespace prefix, as NewPrefixDB does,
+// recording its operations with the same collector under the namespace. Closing it closes the
+// underlying database.
+func (idb *InstrumentedDB) Prefix(prefix []byte) *InstrumentedDB {
+	return &InstrumentedDB{
+		db:        NewPrefixDB(idb.db, prefix),
+		collector: idb.collector,
+		namespace: idb.namespace + string(prefix),
+	}
+}
+
+// observe records an operation started at start.
+func (idb *InstrumentedDB) observe(op Op, start time.Time, bytes int, err error) {
+	idb.collector.Observe(Observation{
+		Op:        op,
+		Namespace: idb.namespace,
+		Duration:  time.Since(start),
+		Bytes:     bytes,
+		Err:       err,
+	})
+}
+
+// Get implements DB.
+func (idb *InstrumentedDB) Get(key []byte) ([]byte, error) {
+	start := time.Now()
+	value, err := idb.db.Get(key)
+	idb.observe(OpGet, start, len(key)+len(value), err)
+	return value, err
+}
+
+// Has implements DB.
+func (idb *InstrumentedDB) Has(key []byte) (bool, error) {
+	start := time.Now()
+	ok, err := idb.db.Has(key)
+	idb.observe(OpHas, start, len(key), err)
+	return ok, err
+}
+
+// Set implements DB.
+func (idb *InstrumentedDB) Set(key []byte, value []byte) error {
+	start := time.Now()
+	err := idb.db.Set(key, value)
+	idb.observe(OpSet, start, len(key)+len(value), err)
+	return err
+}
+
+// SetSync implements DB.
+func (idb *InstrumentedDB) SetSync(key []byte, value []byte) error {
+	start := time.Now()
+	err := idb.db.SetSync(key, value)
+	idb.observe(OpSetSync, start, len(key)+len(value), err)
+	return err
+}
+
+// SetWithTTL implements TTLSetter. It returns ErrNotSupported if the underlying database is not a
+// TTLSetter.
+func (idb *InstrumentedDB) SetWithTTL(key, value []byte, ttl time.Duration) error {
+	start := time.Now()
+	err := SetWithTTL(idb.db, key, value, ttl)
+	idb.observe(OpSetWithTTL, start, len(key)+len(value), err)
+	return err
+}
+
+// CompareAndSwap implements ConditionalWriter. It returns ErrNotSupported if the underlying
+// database is not a ConditionalWriter. Conflicts are recorded as errors.
+func (idb *InstrumentedDB) CompareAndSwap(key, expected, new []byte) (bool, error) {
+	start := time.Now()
+	ok, err := CompareAndSwap(idb.db, key, expected, new)
+	idb.observe(OpCompareAndSwap, start, len(key)+len(expected)+len(new), err)
+	return ok, err
+}
+
+// SetIfAbsent implements ConditionalWriter. It returns ErrNotSupported if the underlying database
+// is not a ConditionalWriter. Conflicts are recorded as errors.
+func (idb *InstrumentedDB) SetIfAbsent(key, value []byte) (bool, error) {
+	start := time.Now()
+	ok, err := SetIfAbsent(idb.db, key, value)
+	idb.observe(OpSetIfAbsent, start, len(key)+len(value), err)
+	return ok, err
+}
+
+// Merge implements Merger. It returns ErrNotSupported if the underlying database is not a Merger.
+func (idb *InstrumentedDB) Merge(key, operand []byte) error {
+	start := time.Now()
+	err := Merge(idb.db, key, operand)
+	idb.observe(OpMerge, start, len(key)+len(operand), err)
+	return err
+}
+
+// Delete implements DB.
+func (idb *InstrumentedDB) Delete(key []byte) error {
+	start := time.Now()
+	err := idb.db.Delete(key)
+	idb.observe(OpDelete, start, len(key), err)
+	return err
+}
+
+// DeleteSync implements DB.
+func (idb *InstrumentedDB) DeleteSync(key []byte) error {
+	start := time.Now()
+	err := idb.db.DeleteSync(key)
+	idb.observe(OpDeleteSync, start, len(key), err)
+	return err
+}
+
+// DeleteRange implements RangeDeleter. It returns ErrNotSupported if the underlying database is
+// not a RangeDeleter.
+func (idb *InstrumentedDB) DeleteRange(start, end []byte) error {
+	begin := time.Now()
+	err := DeleteRange(idb.db, start, end)
+	idb.observe(OpDeleteRange, begin, len(start)+len(end), err)
+	return err
+}
+
+// Compact implements Compacter. It returns ErrNotSupported if the underlying database is not a
+// Compacter.
+func (idb *InstrumentedDB) Compact(start, end []byte) error {
+	begin := time.Now()
+	err := Compact(idb.db, start, end)
+	idb.observe(OpCompact, begin, 0, err)
+	return err
+}
+
+// Iterator implements DB.
+func (idb *InstrumentedDB) Iterator(start, end []byte) (Iterator, error) {
+	return idb.iterator(OpIterator, idb.db.Iterator, start, end)
+}
+
+// ReverseIterator implements DB.
+func (idb *InstrumentedDB) ReverseIterator(start, end []byte) (Iterator, error) {
+	return idb.iterator(OpReverseIterator, idb.db.ReverseIterator, start, end)
+}
+
+func (idb *InstrumentedDB) iterator(op Op, open func(start, end []byte) (Iterator, error),
+	start, end []byte) (Iterator, error) {
+	begin := time.Now()
+	itr, err := open(start, end)
+	if err != nil {
+		idb.observe(op, begin, 0, err)
+		return nil, err
+	}
+	return newInstrumentedIterator(idb, op, begin, itr), nil
+}
+
+// NewBatch implements DB.
+func (idb *InstrumentedDB) NewBatch() Batch {
+	return newInstrumentedBatch(idb, idb.db.NewBatch())
+}
+
+// NewSnapshot implements Snapshotter. It returns ErrNotSupported if the underlying database is
+// not a Snapshotter.
+func (idb *InstrumentedDB) NewSnapshot() (Snapshot, error) {
+	return NewSnapshot(idb.db)
+}
+
+// Begin implements Transactional. It returns ErrNotSupported if the underlying database is not
+// Transactional.
+func (idb *InstrumentedDB) Begin(writable bool) (Txn, error) {
+	return Begin(idb.db, writable)
+}
+
+// KVType implements KVTyper, reporting the type of the underlying database.
+func (idb *InstrumentedDB) KVType() KVType {
+	return TypeOf(idb.db)
+}
+
+// Metrics implements MetricsReporter, reporting the metrics of the underlying database.
+func (idb *InstrumentedDB) Metrics() Metrics {
+	return MetricsOf(idb.db)
+}
+
+// Close implements DB.
+func (idb *InstrumentedDB) Close() error {
+	return idb.db.Close()
+}
+
+// Print implements DB.
+func (idb *InstrumentedDB) Print() error {
+	return idb.db.Print()
+}
+
+// Stats implements DB.
+func (idb *InstrumentedDB) Stats() map[string]string {
+	return idb.db.Stats()
+}
@@ -0,0 +1,89 @@
+package locketdb
+
+import "time"
+
+// instrumentedDBBatch records its Write and WriteSync, with the size of the operations queued.
+type instrumentedDBBatch struct {
+	idb    *InstrumentedDB
+	source Batch
+	bytes  int
+}
+
+var (
+	_ Batch        = (*instrumentedDBBatch)(nil)
+	_ RangeDeleter = (*instrumentedDBBatch)(nil)
+	_ Merger       = (*instrumentedDBBatch)(nil)
+)
+
+func newInstrumentedBatch(idb *InstrumentedDB, source Batch) *instrumentedDBBatch {
+	return &instrumentedDBBatch{
+		idb:    idb,
+		source: source,
+	}
+}
+
+// Set implements Batch.
+func (ib *instrumentedDBBatch) Set(key, value []byte) error {
+	err := ib.source.Set(key, value)
+	if err == nil {
+		ib.bytes += len(key) + len(value)
+	}
+	return err
+}
+
+// Delete implements Batch.
+func (ib *instrumentedDBBatch) Delete(key []byte) error {
+	err := ib.source.Delete(key)
+	if err == nil {
+		ib.bytes += len(key)
+	}
+	return err
+}
+
+// Merge implements Merger. It returns ErrNotSupported if the underlying batch is not a Merger.
+func (ib *instrumentedDBBatch) Merge(key, operand []byte) error {
+	m, ok := ib.source.(Merger)
+	if !ok {
+		return ErrNotSupported
+	}
+	err := m.Merge(key, operand)
+	if err == nil {
+		ib.bytes += len(key) + len(operand)
+	}
+	return err
+}
+
+// DeleteRange implements RangeDeleter. It returns ErrNotSupported if the underlying batch is not
+// a RangeDeleter.
+func (ib *instrumentedDBBatch) DeleteRange(start, end []byte) error {
+	rd, ok := ib.source.(RangeDeleter)
+	if !ok {
+		return ErrNotSupported
+	}
+	err := rd.DeleteRange(start, end)
+	if err == nil {
+		ib.bytes += len(start) + len(end)
+	}
+	return err
+}
+
+// Write implements Batch.
+func (ib *instrumentedDBBatch) Write() error {
+	start := time.Now()
+	err := ib.source.Write()
+	ib.idb.observe(OpBatchWrite, start, ib.bytes, err)
+	return err
+}
+
+// WriteSync implements Batch.
+func (ib *instrumentedDBBatch) WriteSync() error {
+	start := time.Now()
+	err := ib.source.WriteSync()
+	ib.idb.observe(OpBatchWriteSync, start, ib.bytes, err)
+	return err
+}
+
+// Close implements Batch.
+func (ib *instrumentedDBBatch) Close() error {
+	return ib.source.Close()
+}
@@ -0,0 +1,140 @@
+package locketdb
+
+import "time"
+
+// instrumentedDBIterator records an iteration when it is closed, with the time elapsed since the
+// iterator was opened, the size of the keys and values read, and the error of the iterator. The
+// key and value of each position are counted on the first call to Key and Value, so that
+// instrumenting an iterator reads nothing more than its caller does.
+type instrumentedDBIterator struct {
+	idb    *InstrumentedDB
+	op     Op
+	start  time.Time
+	source Iterator
+
+	bytes int
+	// keyRead and valueRead are set once the key and value of the current position are counted.
+	keyRead, valueRead bool
+	err                error
+	closed             bool
+}
+
+var _ SeekIterator = (*instrumentedDBIterator)(nil)
+
+func newInstrumentedIterator(idb *InstrumentedDB, op Op, start time.Time, source Iterator) *instrumentedDBIterator {
+	return &instrumentedDBIterator{
+		idb:    idb,
+		op:     op,
+		start:  start,
+		source: source,
+	}
+}
+
+// Domain implements Iterator.
+func (itr *instrumentedDBIterator) Domain() (start []byte, end []byte) {
+	return itr.source.Domain()
+}
+
+// Valid implements Iterator.
+func (itr *instrumentedDBIterator) Valid() bool {
+	return itr.err == nil && itr.source.Valid()
+}
+
+// Next implements Iterator.
+func (itr *instrumentedDBIterator) Next() {
+	itr.assertIsValid()
+	itr.resetPosition()
+	itr.source.Next()
+}
+
+// Prev implements SeekIterator.
+func (itr *instrumentedDBIterator) Prev() {
+	itr.assertIsValid()
+	if source, ok := itr.seekSource(); ok {
+		itr.resetPosition()
+		source.Prev()
+	}
+}
+
+// Seek implements SeekIterator. Like First and Last, it makes the iterator fail with
+// ErrNotSupported if the underlying iterator is not a SeekIterator.
+func (itr *instrumentedDBIterator) Seek(key []byte) {
+	if source, ok := itr.seekSource(); ok {
+		itr.resetPosition()
+		source.Seek(key)
+	}
+}
+
+// First implements SeekIterator.
+func (itr *instrumentedDBIterator) First() {
+	if source, ok := itr.seekSource(); ok {
+		itr.resetPosition()
+		source.First()
+	}
+}
+
+// Last implements SeekIterator.
+func (itr *instrumentedDBIterator) Last() {
+	if source, ok := itr.seekSource(); ok {
+		itr.resetPosition()
+		source.Last()
+	}
+}
+
+func (itr *instrumentedDBIterator) seekSource() (SeekIterator, bool) {
+	source, ok := itr.source.(SeekIterator)
+	if !ok && itr.err == nil {
+		itr.err = ErrNotSupported
+	}
+	return source, ok
+}
+
+// Key implements Iterator.
+func (itr *instrumentedDBIterator) Key() []byte {
+	itr.assertIsValid()
+	key := itr.source.Key()
+	if !itr.keyRead {
+		itr.keyRead = true
+		itr.bytes += len(key)
+	}
+	return key
+}
+
+// Value implements Iterator.
+func (itr *instrumentedDBIterator) Value() []byte {
+	itr.assertIsValid()
+	value := itr.source.Value()
+	if !itr.valueRead {
+		itr.valueRead = true
+		itr.bytes += len(value)
+	}
+	return value
+}
+
+// Error implements Iterator.
+func (itr *instrumentedDBIterator) Error() error {
+	if itr.err != nil {
+		return itr.err
+	}
+	return itr.source.Error()
+}
+
+// Close implements Iterator. The iteration is recorded on the first call only.
+func (itr *instrumentedDBIterator) Close() error {
+	if !itr.closed {
+		itr.closed = true
+		itr.idb.observe(itr.op, itr.start, itr.bytes, itr.Error())
+	}
+	return itr.source.Close()
+}
+
+// resetPosition forgets what was read at the current position, before the iterator moves.
+func (itr *instrumentedDBIterator) resetPosition() {
+	itr.keyRead, itr.valueRead = false, false
+}
+
+func (itr *instrumentedDBIterator) assertIsValid() {
+	if !itr.Valid() {
+		panic("iterator is invalid")
+	}
+}
@@ -0,0 +1,215 @@
+package locketdb_test
+
+import (
+	"bytes"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/meission/locketdb"
+	"github.com/meission/locketdb/locketdbtest"
+	"github.com/meission/locketdb/memdb"
+)
+
+func newInstrumentedDB(t *testing.T, c locketdb.Collector) *locketdb.InstrumentedDB {
+	t.Helper()
+	db, err := memdb.NewDB("test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return locketdb.NewInstrumentedDB(db, c)
+}
+
+func findSeries(series []locketdb.OpSeries, op locketdb.Op, namespace string) (locketdb.OpSeries, bool) {
+	for _, s := range series {
+		if s.Op == op && s.Namespace == namespace {
+			return s, true
+		}
+	}
+	return locketdb.OpSeries{}, false
+}
+
+func TestInstrumentedDBConformance(t *testing.T) {
+	locketdbtest.RunConformance(t, func() locketdb.DB {
+		return newInstrumentedDB(t, locketdb.NewHistogramCollector())
+	})
+}
+
+func TestInstrumentedDB(t *testing.T) {
+	c := locketdb.NewHistogramCollector()
+	db := newInstrumentedDB(t, c)
+	defer db.Close()
+
+	if err := db.Set([]byte("key1"), []byte("value1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Set([]byte("key2"), []byte("value2")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Get([]byte("key1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Set(nil, []byte("value")); err == nil {
+		t.Fatal("expected an error setting an empty key")
+	}
+	if _, err := db.SetIfAbsent([]byte("key1"), []byte("other")); !errors.Is(err, locketdb.ErrConflict) {
+		t.Fatalf("expected a conflict, got %v", err)
+	}
+
+	itr, err := db.Iterator(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Each position is counted once, however many times it is read.
+	for ; itr.Valid(); itr.Next() {
+		itr.Key()
+		itr.Key()
+		itr.Value()
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected Key to panic on an invalid iterator")
+			}
+		}()
+		itr.Key()
+	}()
+	itr.Close()
+	itr.Close()
+
+	// Values that are not read are not counted.
+	itr, err = db.Iterator(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for ; itr.Valid(); itr.Next() {
+		itr.Key()
+	}
+	itr.Close()
+
+	batch := db.NewBatch()
+	if err := batch.Set([]byte("key3"), []byte("value3")); err != nil {
+		t.Fatal(err)
+	}
+	if err := batch.Delete([]byte("key1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := batch.Write(); err != nil {
+		t.Fatal(err)
+	}
+	batch.Close()
+
+	for _, tc := range []struct {
+		op                   locketdb.Op
+		count, errors, bytes uint64
+	}{
+		{locketdb.OpSet, 3, 1, 25},
+		{locketdb.OpGet, 1, 0, 10},
+		{locketdb.OpSetIfAbsent, 1, 1, 9},
+		{locketdb.OpIterator, 2, 0, 28},
+		{locketdb.OpBatchWrite, 1, 0, 14},
+	} {
+		s, ok := findSeries(c.Series(), tc.op, "")
+		if !ok {
+			t.Errorf("%s: no series", tc.op)
+			continue
+		}
+		if s.Count != tc.count || s.Errors != tc.errors || s.Bytes != tc.bytes {
+			t.Errorf("%s: got count %d, errors %d, bytes %d, want %d, %d, %d",
+				tc.op, s.Count, s.Errors, s.Bytes, tc.count, tc.errors, tc.bytes)
+		}
+		if last := s.Buckets[len(s.Buckets)-1]; last > s.Count {
+			t.Errorf("%s: last bucket %d exceeds count %d", tc.op, last, s.Count)
+		}
+	}
+	if _, ok := findSeries(c.Series(), locketdb.OpDelete, ""); ok {
+		t.Error("batch operations should not be recorded on their own")
+	}
+}
+
+func TestInstrumentedDBNamespaces(t *testing.T) {
+	c := locketdb.NewHistogramCollector()
+	db := newInstrumentedDB(t, c)
+	defer db.Close()
+
+	users := db.Prefix([]byte("users/"))
+	admins := users.Prefix([]byte("admins/"))
+	if err := users.Set([]byte("alice"), []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := admins.Set([]byte("bob"), []byte("2")); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Set([]byte("other"), []byte("3")); err != nil {
+		t.Fatal(err)
+	}
+
+	value, err := db.Get([]byte("users/admins/bob"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(value, []byte("2")) {
+		t.Fatalf("got %q, want %q", value, "2")
+	}
+
+	orders, err := memdb.NewDB("orders", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrapped := locketdb.NewInstrumentedDB(locketdb.NewPrefixDB(orders, []byte("orders/")), c)
+	defer wrapped.Close()
+	if err := wrapped.Set([]byte("1"), []byte("4")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, namespace := range []string{"", "users/", "users/admins/", "orders/"} {
+		if s, ok := findSeries(c.Series(), locketdb.OpSet, namespace); !ok || s.Count != 1 {
+			t.Errorf("namespace %q: expected one set, got %+v", namespace, s)
+		}
+	}
+}
+
+func TestHistogramCollector(t *testing.T) {
+	c := locketdb.NewHistogramCollector(time.Millisecond, time.Second)
+	c.Observe(locketdb.Observation{Op: locketdb.OpGet, Duration: 500 * time.Microsecond, Bytes: 3})
+	c.Observe(locketdb.Observation{Op: locketdb.OpGet, Duration: 10 * time.Millisecond, Bytes: 4})
+	c.Observe(locketdb.Observation{Op: locketdb.OpGet, Duration: 2 * time.Second, Err: errors.New("boom")})
+	c.Observe(locketdb.Observation{Op: locketdb.OpSet, Namespace: "a\"b\\c\n", Duration: time.Millisecond})
+	c.Observe(locketdb.Observation{Op: locketdb.OpSet, Namespace: "\xff", Duration: time.Millisecond})
+
+	var buf bytes.Buffer
+	if err := c.WritePrometheus(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, line := range []string{
+		"# TYPE locketdb_operations_total counter",
+		"# TYPE locketdb_operation_duration_seconds histogram",
+		`locketdb_operations_total{op="get",namespace=""} 3`,
+		`locketdb_operation_errors_total{op="get",namespace=""} 1`,
+		`locketdb_operation_bytes_total{op="get",namespace=""} 7`,
+		`locketdb_operation_duration_seconds_bucket{op="get",namespace="",le="0.001"} 1`,
+		`locketdb_operation_duration_seconds_bucket{op="get",namespace="",le="1"} 2`,
+		`locketdb_operation_duration_seconds_bucket{op="get",namespace="",le="+Inf"} 3`,
+		`locketdb_operation_duration_seconds_sum{op="get",namespace=""} 2.0105`,
+		`locketdb_operation_duration_seconds_count{op="get",namespace=""} 3`,
+		`locketdb_operations_total{op="set",namespace="a\"b\\c\n"} 1`,
+		`locketdb_operation_duration_seconds_bucket{op="set",namespace="a\"b\\c\n",le="0.001"} 1`,
+		`locketdb_operations_total{op="set",namespace="0xFF"} 1`,
+	} {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("missing line %q in:\n%s", line, out)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("unexpected content type %q", ct)
+	}
+	if rec.Body.String() != out {
+		t.Errorf("served metrics differ from WritePrometheus:\n%s", rec.Body.String())
+	}
+}
@@ -18,6 +18,23 @@ var (
 
 	// ErrValueNil is returned when attempting to set a nil value.
 	ErrValueNil = errors.New("value cannot be nil")
+
+	// ErrNotSupported is returned when the backend does not implement an optional capability.
+	ErrNotSupported = errors.New("operation not supported by backend")
+
+	// ErrTxnClosed is returned when a committed or rolled back transaction is used.
+	ErrTxnClosed = errors.New("transaction has been committed or rolled back")
+
+	// ErrTxnReadOnly is returned when attempting to write through a read-only transaction.
+	ErrTxnReadOnly = errors.New("transaction is read-only")
+
+	// ErrConflict is returned when a transaction cannot commit because data it read was modified
+	// concurrently, in which case the transaction can be retried, and when the condition of a
+	// conditional write does not hold.
+	ErrConflict = errors.New("transaction conflict")
+
+	// ErrReadOnly is returned when attempting to write to a database opened read-only.
+	ErrReadOnly = errors.New("database is read-only")
 )
 
 // DB is the main interface for all database backends. DBs are concurrency-safe. Callers must call
@@ -73,7 +90,7 @@ type DB interface {
 	// Print is used for debugging.
 	Print() error
 
-	// Stats returns a map of property values for all keys and the size of the cache.
+	// Stats returns statistics about the database, keyed as described by StatsKey.
 	Stats() map[string]string
 }
 
@@ -131,7 +148,7 @@ type Iterator interface {
 	Domain() (start []byte, end []byte)
 
 	// Valid returns whether the current iterator is valid. Once invalid, the Iterator remains
-	// invalid forever.
+	// invalid forever, unless it is a SeekIterator moved by Seek, First or Last.
 	Valid() bool
 
 	// Next moves the iterator to the next key in the database, as defined by order of iteration.
@@ -153,6 +170,31 @@ type Iterator interface {
 	Close() error
 }
 
+// SeekIterator is implemented by iterators that can be moved to another key without being
+// reopened. Positions are relative to the order of iteration and bounded by the Domain: for a
+// reverse iterator, First is the greatest key of the domain and Seek looks backwards.
+//
+// Seek, First and Last make the iterator valid again when they find a key, even after it was
+// exhausted. An iterator that encountered an error remains invalid.
+type SeekIterator interface {
+	Iterator
+
+	// Seek moves to the first key at or after key in the order of iteration: the smallest key
+	// >= key for a forward iterator, the greatest key <= key for a reverse one.
+	// CONTRACT: key readonly []byte
+	Seek(key []byte)
+
+	// First moves to the first key of the domain in the order of iteration.
+	First()
+
+	// Last moves to the last key of the domain in the order of iteration.
+	Last()
+
+	// Prev moves the iterator to the previous key, as defined by order of iteration. If Valid
+	// returns false, this method will panic.
+	Prev()
+}
+
 type KVType string
 
 // These are valid backend types.
@@ -169,8 +211,25 @@ const (
 
 	// goleveldb github.com/cockroachdb/pebble
 	Pebble KVType = "pebble"
+
+	// memdb in-memory skiplist github.com/meission/locketdb/memdb
+	MemDB KVType = "memdb"
 )
 
+// KVTyper is implemented by databases that report their backend type.
+type KVTyper interface {
+	// KVType returns the backend type of the database.
+	KVType() KVType
+}
+
+// TypeOf returns the backend type of db, or an empty KVType if db does not report it.
+func TypeOf(db DB) KVType {
+	if t, ok := db.(KVTyper); ok {
+		return t.KVType()
+	}
+	return ""
+}
+
 type Engine func(name string, dir string) (DB, error)
 
 var engines = map[KVType]Engine{}
@@ -0,0 +1,937 @@
+// Package locketdbtest provides a conformance suite for locketdb backends.
+//
+// Every engine registered with locketdb is expected to pass RunConformance, so that the DB, Batch
+// and Iterator contracts documented in the locketdb package behave identically across backends.
+package locketdbtest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/meission/locketdb"
+)
+
+// RunConformance runs the DB, Batch and Iterator contract tests against databases created by
+// newDB. Every call to newDB must return a new, empty database; the suite closes it when done.
+//
+// The subtests named in skip are reported as skipped instead of run, so that a backend with a
+// known contract violation keeps the coverage of the rest of the suite until it is fixed.
+func RunConformance(t *testing.T, newDB func() locketdb.DB, skip ...string) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, db locketdb.DB)
+	}{
+		{"GetSetDelete", testGetSetDelete},
+		{"EmptyKeyNilValue", testEmptyKeyNilValue},
+		{"EmptyValue", testEmptyValue},
+		{"IteratorEmptyKey", testIteratorEmptyKey},
+		{"IteratorEmptyDB", testIteratorEmptyDB},
+		{"IteratorDomain", testIteratorDomain},
+		{"IteratorInvalidForever", testIteratorInvalidForever},
+		{"SeekIterator", testSeekIterator},
+		{"IteratorModel", testIteratorModel},
+		{"BatchWrite", testBatchWrite},
+		{"BatchWriteSync", testBatchWriteSync},
+		{"BatchClosed", testBatchClosed},
+		{"BatchEmptyKeyNilValue", testBatchEmptyKeyNilValue},
+		{"Snapshot", testSnapshot},
+		{"Txn", testTxn},
+		{"TxnConflict", testTxnConflict},
+		{"DeleteRange", testDeleteRange},
+		{"Compact", testCompact},
+		{"TTL", testTTL},
+		{"ConditionalWrite", testConditionalWrite},
+		{"ConditionalWriteConcurrent", testConditionalWriteConcurrent},
+		{"Stats", testStats},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			skipKnownViolation(t, tc.name, skip)
+			db := newDB()
+			defer func() {
+				if err := db.Close(); err != nil {
+					t.Errorf("close: %v", err)
+				}
+			}()
+			tc.fn(t, db)
+		})
+	}
+}
+
+func testGetSetDelete(t *testing.T, db locketdb.DB) {
+	key, value := []byte("key"), []byte("value")
+
+	assertValue(t, db, key, nil)
+
+	mustNoErr(t, db.Set(key, value))
+	assertValue(t, db, key, value)
+
+	mustNoErr(t, db.SetSync(key, []byte("other")))
+	assertValue(t, db, key, []byte("other"))
+
+	mustNoErr(t, db.Delete(key))
+	assertValue(t, db, key, nil)
+
+	mustNoErr(t, db.SetSync(key, value))
+	mustNoErr(t, db.DeleteSync(key))
+	assertValue(t, db, key, nil)
+
+	// Deleting a missing key is a no-op.
+	mustNoErr(t, db.Delete([]byte("missing")))
+	mustNoErr(t, db.DeleteSync([]byte("missing")))
+}
+
+func testEmptyKeyNilValue(t *testing.T, db locketdb.DB) {
+	for _, key := range [][]byte{nil, {}} {
+		_, err := db.Get(key)
+		assertErr(t, "Get", err, locketdb.ErrKeyEmpty)
+		_, err = db.Has(key)
+		assertErr(t, "Has", err, locketdb.ErrKeyEmpty)
+		assertErr(t, "Set", db.Set(key, []byte("value")), locketdb.ErrKeyEmpty)
+		assertErr(t, "SetSync", db.SetSync(key, []byte("value")), locketdb.ErrKeyEmpty)
+		assertErr(t, "Delete", db.Delete(key), locketdb.ErrKeyEmpty)
+		assertErr(t, "DeleteSync", db.DeleteSync(key), locketdb.ErrKeyEmpty)
+	}
+	assertErr(t, "Set", db.Set([]byte("key"), nil), locketdb.ErrValueNil)
+	assertErr(t, "SetSync", db.SetSync([]byte("key"), nil), locketdb.ErrValueNil)
+}
+
+func testEmptyValue(t *testing.T, db locketdb.DB) {
+	key := []byte("key")
+	mustNoErr(t, db.Set(key, []byte{}))
+
+	ok, err := db.Has(key)
+	mustNoErr(t, err)
+	if !ok {
+		t.Fatalf("Has(%q) = false, want true", key)
+	}
+	value, err := db.Get(key)
+	mustNoErr(t, err)
+	if value == nil || len(value) != 0 {
+		t.Fatalf("Get(%q) = %#v, want empty non-nil value", key, value)
+	}
+}
+
+func testIteratorEmptyKey(t *testing.T, db locketdb.DB) {
+	_, err := db.Iterator([]byte{}, nil)
+	assertErr(t, "Iterator", err, locketdb.ErrKeyEmpty)
+	_, err = db.Iterator(nil, []byte{})
+	assertErr(t, "Iterator", err, locketdb.ErrKeyEmpty)
+	_, err = db.ReverseIterator([]byte{}, nil)
+	assertErr(t, "ReverseIterator", err, locketdb.ErrKeyEmpty)
+	_, err = db.ReverseIterator(nil, []byte{})
+	assertErr(t, "ReverseIterator", err, locketdb.ErrKeyEmpty)
+}
+
+func testIteratorEmptyDB(t *testing.T, db locketdb.DB) {
+	itr, err := db.Iterator(nil, nil)
+	mustNoErr(t, err)
+	assertKeys(t, itr, nil)
+
+	itr, err = db.ReverseIterator(nil, nil)
+	mustNoErr(t, err)
+	assertKeys(t, itr, nil)
+}
+
+func testIteratorDomain(t *testing.T, db locketdb.DB) {
+	for _, k := range []string{"b", "c", "d", "f", "h"} {
+		mustNoErr(t, db.Set([]byte(k), []byte("v"+k)))
+	}
+
+	tests := []struct {
+		start, end []byte
+		want       []string
+	}{
+		{nil, nil, []string{"b", "c", "d", "f", "h"}},
+		{[]byte("a"), nil, []string{"b", "c", "d", "f", "h"}},
+		{[]byte("b"), nil, []string{"b", "c", "d", "f", "h"}},
+		{[]byte("c"), nil, []string{"c", "d", "f", "h"}},
+		{[]byte("e"), nil, []string{"f", "h"}},
+		{[]byte("i"), nil, nil},
+		{nil, []byte("a"), nil},
+		{nil, []byte("b"), nil},
+		{nil, []byte("c"), []string{"b"}},
+		{nil, []byte("e"), []string{"b", "c", "d"}},
+		{nil, []byte("h"), []string{"b", "c", "d", "f"}},
+		{nil, []byte("i"), []string{"b", "c", "d", "f", "h"}},
+		{[]byte("b"), []byte("h"), []string{"b", "c", "d", "f"}},
+		{[]byte("c"), []byte("f"), []string{"c", "d"}},
+		{[]byte("c"), []byte("g"), []string{"c", "d", "f"}},
+		{[]byte("e"), []byte("g"), []string{"f"}},
+		{[]byte("e"), []byte("f"), nil},
+		{[]byte("c"), []byte("c\x00"), []string{"c"}},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(fmt.Sprintf("[%s,%s)", tc.start, tc.end), func(t *testing.T) {
+			itr, err := db.Iterator(tc.start, tc.end)
+			mustNoErr(t, err)
+			assertDomain(t, itr, tc.start, tc.end)
+			assertKeys(t, itr, tc.want)
+
+			itr, err = db.ReverseIterator(tc.start, tc.end)
+			mustNoErr(t, err)
+			assertDomain(t, itr, tc.start, tc.end)
+			assertKeys(t, itr, reversed(tc.want))
+		})
+	}
+}
+
+func testIteratorInvalidForever(t *testing.T, db locketdb.DB) {
+	mustNoErr(t, db.Set([]byte("a"), []byte("1")))
+	mustNoErr(t, db.Set([]byte("b"), []byte("2")))
+
+	for _, reverse := range []bool{false, true} {
+		var itr locketdb.Iterator
+		var err error
+		if reverse {
+			itr, err = db.ReverseIterator(nil, []byte("b"))
+		} else {
+			itr, err = db.Iterator([]byte("b"), nil)
+		}
+		mustNoErr(t, err)
+		if !itr.Valid() {
+			t.Fatalf("reverse=%v: iterator is invalid, want one key", reverse)
+		}
+		itr.Next()
+		for i := 0; i < 2; i++ {
+			if itr.Valid() {
+				t.Fatalf("reverse=%v: iterator is valid past the last key", reverse)
+			}
+		}
+		assertPanics(t, "Next", itr.Next)
+		assertPanics(t, "Key", func() { itr.Key() })
+		assertPanics(t, "Value", func() { itr.Value() })
+		mustNoErr(t, itr.Error())
+		mustNoErr(t, itr.Close())
+	}
+}
+
+func testSeekIterator(t *testing.T, db locketdb.DB) {
+	keys := []string{"b", "c", "d", "f", "h"}
+	for _, k := range keys {
+		mustNoErr(t, db.Set([]byte(k), []byte("v"+k)))
+	}
+	probes := []string{"", "a", "b", "b\x00", "c", "e", "f", "g", "h", "i"}
+
+	domains := []struct{ start, end []byte }{
+		{nil, nil},
+		{[]byte("c"), []byte("g")},
+		{[]byte("b\x00"), []byte("h")},
+		{[]byte("d"), nil},
+		{nil, []byte("d")},
+		{[]byte("e"), []byte("f")},
+	}
+	for _, d := range domains {
+		for _, reverse := range []bool{false, true} {
+			d, reverse := d, reverse
+			t.Run(fmt.Sprintf("[%s,%s) reverse=%v", d.start, d.end, reverse), func(t *testing.T) {
+				var itr locketdb.Iterator
+				var err error
+				if reverse {
+					itr, err = db.ReverseIterator(d.start, d.end)
+				} else {
+					itr, err = db.Iterator(d.start, d.end)
+				}
+				mustNoErr(t, err)
+				defer itr.Close()
+				sitr, ok := itr.(locketdb.SeekIterator)
+				if !ok {
+					t.Skip("backend iterators are not SeekIterators")
+				}
+
+				// want holds the keys of the domain in the order of iteration.
+				var want []string
+				for _, k := range keys {
+					if locketdb.IsKeyInDomain([]byte(k), d.start, d.end) {
+						want = append(want, k)
+					}
+				}
+				if reverse {
+					want = reversed(want)
+				}
+				first, last := "", ""
+				if len(want) > 0 {
+					first, last = want[0], want[len(want)-1]
+				}
+
+				sitr.Last()
+				assertPosition(t, "Last", sitr, last)
+				for i := len(want) - 2; i >= 0; i-- {
+					sitr.Prev()
+					assertPosition(t, "Prev", sitr, want[i])
+				}
+				if len(want) > 0 {
+					sitr.Prev()
+					assertPosition(t, "Prev", sitr, "")
+				}
+				assertPanics(t, "Prev", sitr.Prev)
+
+				// Seek and First make an exhausted iterator valid again.
+				sitr.First()
+				assertPosition(t, "First", sitr, first)
+				for _, probe := range probes {
+					expected := ""
+					for _, k := range want {
+						if (!reverse && k >= probe) || (reverse && k <= probe) {
+							expected = k
+							break
+						}
+					}
+					sitr.Seek([]byte(probe))
+					assertPosition(t, fmt.Sprintf("Seek(%q)", probe), sitr, expected)
+				}
+				sitr.Seek(nil)
+				if len(want) > 0 && !reverse {
+					assertPosition(t, "Seek(nil)", sitr, first)
+				}
+				assertDomain(t, sitr, d.start, d.end)
+				mustNoErr(t, sitr.Error())
+			})
+		}
+	}
+}
+
+// testIteratorModel applies random writes to db and to a sorted reference model, and checks that
+// iterators over random domains yield the keys and values of the model, in both directions. The
+// seed is fixed, so that failures can be reproduced.
+func testIteratorModel(t *testing.T, db locketdb.DB) {
+	rnd := rand.New(rand.NewSource(1))
+	// Keys are short strings over a small alphabet, so that the bounds of the domains often match
+	// existing keys, or are prefixes or successors of them.
+	randKey := func() []byte {
+		key := make([]byte, 1+rnd.Intn(3))
+		for i := range key {
+			key[i] = "\x00ab\xff"[rnd.Intn(4)]
+		}
+		return key
+	}
+
+	model := make(map[string]string)
+	for round := 0; round < 20; round++ {
+		// Odd rounds write through a batch.
+		var batch locketdb.Batch
+		set, del := db.Set, db.Delete
+		if round%2 == 1 {
+			batch = db.NewBatch()
+			set, del = batch.Set, batch.Delete
+		}
+		for i := 0; i < 20; i++ {
+			key := randKey()
+			if rnd.Intn(4) == 0 {
+				mustNoErr(t, del(key))
+				delete(model, string(key))
+			} else {
+				value := fmt.Sprintf("v%d.%d", round, i)
+				mustNoErr(t, set(key, []byte(value)))
+				model[string(key)] = value
+			}
+		}
+		if batch != nil {
+			mustNoErr(t, batch.Write())
+			mustNoErr(t, batch.Close())
+		}
+
+		keys := make([]string, 0, len(model))
+		for k := range model {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for q := 0; q < 10; q++ {
+			var start, end []byte
+			if rnd.Intn(4) > 0 {
+				start = randKey()
+			}
+			if rnd.Intn(4) > 0 {
+				end = randKey()
+			}
+			if start != nil && end != nil && bytes.Compare(start, end) > 0 {
+				start, end = end, start
+			}
+			var want []string
+			for _, k := range keys {
+				if locketdb.IsKeyInDomain([]byte(k), start, end) {
+					want = append(want, k+"="+model[k])
+				}
+			}
+
+			itr, err := db.Iterator(start, end)
+			mustNoErr(t, err)
+			if got := drainPairs(t, itr); fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Fatalf("round %d: Iterator(%q, %q) yields %q, want %q", round, start, end, got, want)
+			}
+			itr, err = db.ReverseIterator(start, end)
+			mustNoErr(t, err)
+			if got := drainPairs(t, itr); fmt.Sprint(got) != fmt.Sprint(reversed(want)) {
+				t.Fatalf("round %d: ReverseIterator(%q, %q) yields %q, want %q", round, start, end, got, reversed(want))
+			}
+		}
+	}
+}
+
+// assertPosition checks that itr is at key, or invalid if key is empty.
+func assertPosition(t *testing.T, op string, itr locketdb.Iterator, key string) {
+	t.Helper()
+	if key == "" {
+		if itr.Valid() {
+			t.Fatalf("%s: iterator at %q, want invalid", op, itr.Key())
+		}
+		return
+	}
+	if !itr.Valid() {
+		t.Fatalf("%s: iterator is invalid, want %q", op, key)
+	}
+	if string(itr.Key()) != key || string(itr.Value()) != "v"+key {
+		t.Fatalf("%s: iterator at %q=%q, want %q", op, itr.Key(), itr.Value(), key)
+	}
+}
+
+func testBatchWrite(t *testing.T, db locketdb.DB) {
+	mustNoErr(t, db.Set([]byte("b"), []byte("old")))
+
+	batch := db.NewBatch()
+	mustNoErr(t, batch.Set([]byte("a"), []byte("1")))
+	mustNoErr(t, batch.Delete([]byte("b")))
+	mustNoErr(t, batch.Set([]byte("c"), []byte("3")))
+	mustNoErr(t, batch.Set([]byte("c"), []byte("33")))
+
+	// Nothing is visible before Write.
+	assertValue(t, db, []byte("a"), nil)
+	assertValue(t, db, []byte("b"), []byte("old"))
+
+	mustNoErr(t, batch.Write())
+	mustNoErr(t, batch.Close())
+
+	assertValue(t, db, []byte("a"), []byte("1"))
+	assertValue(t, db, []byte("b"), nil)
+	assertValue(t, db, []byte("c"), []byte("33"))
+
+	// A closed batch discards its operations.
+	batch = db.NewBatch()
+	mustNoErr(t, batch.Set([]byte("d"), []byte("4")))
+	mustNoErr(t, batch.Close())
+	assertValue(t, db, []byte("d"), nil)
+}
+
+func testBatchWriteSync(t *testing.T, db locketdb.DB) {
+	batch := db.NewBatch()
+	mustNoErr(t, batch.Set([]byte("a"), []byte("1")))
+	mustNoErr(t, batch.WriteSync())
+	mustNoErr(t, batch.Close())
+
+	assertValue(t, db, []byte("a"), []byte("1"))
+}
+
+func testBatchClosed(t *testing.T, db locketdb.DB) {
+	check := func(batch locketdb.Batch) {
+		t.Helper()
+		assertErr(t, "Set", batch.Set([]byte("a"), []byte("1")), locketdb.ErrBatchClosed)
+		assertErr(t, "Delete", batch.Delete([]byte("a")), locketdb.ErrBatchClosed)
+		assertErr(t, "Write", batch.Write(), locketdb.ErrBatchClosed)
+		assertErr(t, "WriteSync", batch.WriteSync(), locketdb.ErrBatchClosed)
+		mustNoErr(t, batch.Close())
+		mustNoErr(t, batch.Close())
+	}
+
+	batch := db.NewBatch()
+	mustNoErr(t, batch.Set([]byte("a"), []byte("1")))
+	mustNoErr(t, batch.Write())
+	check(batch)
+
+	batch = db.NewBatch()
+	mustNoErr(t, batch.Set([]byte("a"), []byte("1")))
+	mustNoErr(t, batch.WriteSync())
+	check(batch)
+
+	batch = db.NewBatch()
+	mustNoErr(t, batch.Close())
+	check(batch)
+}
+
+func testBatchEmptyKeyNilValue(t *testing.T, db locketdb.DB) {
+	batch := db.NewBatch()
+	defer batch.Close()
+
+	for _, key := range [][]byte{nil, {}} {
+		assertErr(t, "Set", batch.Set(key, []byte("value")), locketdb.ErrKeyEmpty)
+		assertErr(t, "Delete", batch.Delete(key), locketdb.ErrKeyEmpty)
+	}
+	assertErr(t, "Set", batch.Set([]byte("key"), nil), locketdb.ErrValueNil)
+}
+
+func testSnapshot(t *testing.T, db locketdb.DB) {
+	if _, ok := db.(locketdb.Snapshotter); !ok {
+		t.Skip("backend is not a Snapshotter")
+	}
+	mustNoErr(t, db.Set([]byte("a"), []byte("1")))
+	mustNoErr(t, db.Set([]byte("b"), []byte("2")))
+
+	snap, err := locketdb.NewSnapshot(db)
+	mustNoErr(t, err)
+
+	mustNoErr(t, db.Set([]byte("a"), []byte("10")))
+	mustNoErr(t, db.Delete([]byte("b")))
+	mustNoErr(t, db.Set([]byte("c"), []byte("3")))
+
+	for key, want := range map[string][]byte{"a": []byte("1"), "b": []byte("2"), "c": nil} {
+		value, err := snap.Get([]byte(key))
+		mustNoErr(t, err)
+		if !bytes.Equal(value, want) || (value == nil) != (want == nil) {
+			t.Errorf("snapshot Get(%q) = %q, want %q", key, value, want)
+		}
+		ok, err := snap.Has([]byte(key))
+		mustNoErr(t, err)
+		if ok != (want != nil) {
+			t.Errorf("snapshot Has(%q) = %v, want %v", key, ok, want != nil)
+		}
+	}
+	_, err = snap.Get(nil)
+	assertErr(t, "Get", err, locketdb.ErrKeyEmpty)
+	_, err = snap.Iterator([]byte{}, nil)
+	assertErr(t, "Iterator", err, locketdb.ErrKeyEmpty)
+
+	itr, err := snap.Iterator(nil, nil)
+	mustNoErr(t, err)
+	assertKeys(t, itr, []string{"a", "b"})
+	itr, err = snap.ReverseIterator(nil, []byte("b"))
+	mustNoErr(t, err)
+	assertDomain(t, itr, nil, []byte("b"))
+	assertKeys(t, itr, []string{"a"})
+	mustNoErr(t, snap.Close())
+
+	assertValue(t, db, []byte("a"), []byte("10"))
+	assertValue(t, db, []byte("b"), nil)
+	assertValue(t, db, []byte("c"), []byte("3"))
+}
+
+func testTxn(t *testing.T, db locketdb.DB) {
+	if _, ok := db.(locketdb.Transactional); !ok {
+		t.Skip("backend is not Transactional")
+	}
+	mustNoErr(t, db.Set([]byte("a"), []byte("1")))
+	mustNoErr(t, db.Set([]byte("b"), []byte("2")))
+
+	txn, err := locketdb.Begin(db, true)
+	mustNoErr(t, err)
+	value, err := txn.Get([]byte("a"))
+	mustNoErr(t, err)
+	if string(value) != "1" {
+		t.Errorf("txn Get(a) = %q, want %q", value, "1")
+	}
+	mustNoErr(t, txn.Set([]byte("c"), []byte("3")))
+	mustNoErr(t, txn.Delete([]byte("b")))
+	assertErr(t, "Set", txn.Set(nil, []byte("v")), locketdb.ErrKeyEmpty)
+	assertErr(t, "Set", txn.Set([]byte("d"), nil), locketdb.ErrValueNil)
+
+	ok, err := txn.Has([]byte("b"))
+	mustNoErr(t, err)
+	if ok {
+		t.Errorf("txn Has(b) = true after Delete")
+	}
+	itr, err := txn.Iterator(nil, nil)
+	mustNoErr(t, err)
+	assertKeys(t, itr, []string{"a", "c"})
+	itr, err = txn.ReverseIterator([]byte("b"), nil)
+	mustNoErr(t, err)
+	assertKeys(t, itr, []string{"c"})
+
+	// Nothing is visible before Commit.
+	assertValue(t, db, []byte("b"), []byte("2"))
+	assertValue(t, db, []byte("c"), nil)
+
+	mustNoErr(t, txn.Commit())
+	mustNoErr(t, txn.Rollback())
+	assertErr(t, "Set", txn.Set([]byte("a"), []byte("v")), locketdb.ErrTxnClosed)
+	assertErr(t, "Commit", txn.Commit(), locketdb.ErrTxnClosed)
+
+	assertValue(t, db, []byte("a"), []byte("1"))
+	assertValue(t, db, []byte("b"), nil)
+	assertValue(t, db, []byte("c"), []byte("3"))
+
+	// Rolled back writes are discarded.
+	txn, err = locketdb.Begin(db, true)
+	mustNoErr(t, err)
+	mustNoErr(t, txn.Set([]byte("d"), []byte("4")))
+	mustNoErr(t, txn.Rollback())
+	assertValue(t, db, []byte("d"), nil)
+
+	txn, err = locketdb.Begin(db, false)
+	mustNoErr(t, err)
+	assertErr(t, "Set", txn.Set([]byte("d"), []byte("4")), locketdb.ErrTxnReadOnly)
+	assertErr(t, "Delete", txn.Delete([]byte("a")), locketdb.ErrTxnReadOnly)
+	value, err = txn.Get([]byte("c"))
+	mustNoErr(t, err)
+	if string(value) != "3" {
+		t.Errorf("read-only txn Get(c) = %q, want %q", value, "3")
+	}
+	mustNoErr(t, txn.Commit())
+}
+
+// testTxnConflict checks that a read-modify-write transaction never loses a concurrent write:
+// either its commit fails with ErrConflict, or the backend serialized the other write after it.
+func testTxnConflict(t *testing.T, db locketdb.DB) {
+	if _, ok := db.(locketdb.Transactional); !ok {
+		t.Skip("backend is not Transactional")
+	}
+	key := []byte("counter")
+	mustNoErr(t, db.Set(key, []byte("0")))
+
+	txn, err := locketdb.Begin(db, true)
+	mustNoErr(t, err)
+	defer txn.Rollback()
+	_, err = txn.Get(key)
+	mustNoErr(t, err)
+	mustNoErr(t, txn.Set(key, []byte("txn")))
+
+	done := make(chan error, 1)
+	go func() { done <- db.Set(key, []byte("other")) }()
+
+	writtenBefore := false
+	select {
+	case err := <-done:
+		mustNoErr(t, err)
+		writtenBefore = true
+	case <-time.After(200 * time.Millisecond):
+		// The backend blocks writers while a writable transaction is open.
+	}
+
+	err = txn.Commit()
+	if !writtenBefore {
+		mustNoErr(t, <-done)
+	}
+	switch {
+	case err == locketdb.ErrConflict:
+		if !writtenBefore {
+			t.Fatalf("Commit returned ErrConflict although the other write was blocked")
+		}
+	case err != nil:
+		t.Fatalf("Commit: %v", err)
+	case writtenBefore:
+		t.Fatalf("Commit succeeded after a concurrent write to a key the transaction read")
+	}
+	assertValue(t, db, key, []byte("other"))
+}
+
+func testDeleteRange(t *testing.T, db locketdb.DB) {
+	if _, ok := db.(locketdb.RangeDeleter); !ok {
+		t.Skip("backend is not a RangeDeleter")
+	}
+	keys := func(want ...string) {
+		t.Helper()
+		itr, err := db.Iterator(nil, nil)
+		mustNoErr(t, err)
+		assertKeys(t, itr, want)
+	}
+	for _, k := range []string{"b", "c", "d", "f", "h"} {
+		mustNoErr(t, db.Set([]byte(k), []byte("v"+k)))
+	}
+	assertErr(t, "DeleteRange", locketdb.DeleteRange(db, []byte{}, nil), locketdb.ErrKeyEmpty)
+	assertErr(t, "DeleteRange", locketdb.DeleteRange(db, nil, []byte{}), locketdb.ErrKeyEmpty)
+
+	mustNoErr(t, locketdb.DeleteRange(db, []byte("c"), []byte("f")))
+	keys("b", "f", "h")
+	mustNoErr(t, locketdb.DeleteRange(db, []byte("g"), []byte("c")))
+	keys("b", "f", "h")
+	mustNoErr(t, locketdb.DeleteRange(db, nil, []byte("c")))
+	keys("f", "h")
+	mustNoErr(t, db.Set([]byte("a"), []byte("va")))
+	mustNoErr(t, db.Set([]byte("z"), []byte("vz")))
+	mustNoErr(t, locketdb.DeleteRange(db, []byte("g"), nil))
+	keys("a", "f")
+
+	batch := db.NewBatch()
+	defer batch.Close()
+	if rd, ok := batch.(locketdb.RangeDeleter); ok {
+		mustNoErr(t, batch.Set([]byte("m"), []byte("vm")))
+		mustNoErr(t, batch.Set([]byte("y"), []byte("vy")))
+		mustNoErr(t, rd.DeleteRange([]byte("e"), []byte("x")))
+		mustNoErr(t, batch.Set([]byte("n"), []byte("vn")))
+		keys("a", "f")
+		mustNoErr(t, batch.Write())
+		keys("a", "n", "y")
+		assertErr(t, "DeleteRange", rd.DeleteRange(nil, nil), locketdb.ErrBatchClosed)
+	}
+
+	mustNoErr(t, locketdb.DeleteRange(db, nil, nil))
+	keys()
+}
+
+func testCompact(t *testing.T, db locketdb.DB) {
+	if _, ok := db.(locketdb.Compacter); !ok {
+		t.Skip("backend is not a Compacter")
+	}
+	var want []string
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("k%03d", i)
+		mustNoErr(t, db.Set([]byte(key), []byte("v"+key)))
+		if i%2 == 0 {
+			mustNoErr(t, db.Delete([]byte(key)))
+		} else {
+			want = append(want, key)
+		}
+	}
+	assertErr(t, "Compact", locketdb.Compact(db, []byte{}, nil), locketdb.ErrKeyEmpty)
+	mustNoErr(t, locketdb.Compact(db, []byte("k010"), []byte("k050")))
+	mustNoErr(t, locketdb.Compact(db, nil, nil))
+
+	itr, err := db.Iterator(nil, nil)
+	mustNoErr(t, err)
+	assertKeys(t, itr, want)
+	assertValue(t, db, []byte("k001"), []byte("vk001"))
+}
+
+func testTTL(t *testing.T, db locketdb.DB) {
+	err := locketdb.SetWithTTL(db, []byte("a"), []byte("1"), time.Hour)
+	if err == locketdb.ErrNotSupported {
+		t.Skip("backend is not a TTLSetter")
+	}
+	mustNoErr(t, err)
+	assertErr(t, "SetWithTTL", locketdb.SetWithTTL(db, nil, []byte("v"), time.Hour), locketdb.ErrKeyEmpty)
+	assertErr(t, "SetWithTTL", locketdb.SetWithTTL(db, []byte("b"), nil, time.Hour), locketdb.ErrValueNil)
+	assertErr(t, "SetWithTTL", locketdb.SetWithTTL(db, []byte("b"), []byte("v"), 0), locketdb.ErrInvalidTTL)
+
+	// Backends may truncate the expiry time to the second.
+	mustNoErr(t, locketdb.SetWithTTL(db, []byte("b"), []byte("2"), time.Second))
+	mustNoErr(t, locketdb.SetWithTTL(db, []byte("c"), []byte("3"), time.Second))
+	mustNoErr(t, db.Set([]byte("c"), []byte("30")))
+	assertValue(t, db, []byte("b"), []byte("2"))
+	time.Sleep(1100 * time.Millisecond)
+
+	assertValue(t, db, []byte("a"), []byte("1"))
+	assertValue(t, db, []byte("b"), nil)
+	assertValue(t, db, []byte("c"), []byte("30"))
+	ok, err := db.Has([]byte("b"))
+	mustNoErr(t, err)
+	if ok {
+		t.Errorf("Has(b) = true after expiry")
+	}
+	itr, err := db.Iterator(nil, nil)
+	mustNoErr(t, err)
+	assertKeys(t, itr, []string{"a", "c"})
+	itr, err = db.ReverseIterator(nil, nil)
+	mustNoErr(t, err)
+	assertKeys(t, itr, []string{"c", "a"})
+}
+
+func testConditionalWrite(t *testing.T, db locketdb.DB) {
+	key := []byte("key")
+	ok, err := locketdb.SetIfAbsent(db, key, []byte("1"))
+	if err == locketdb.ErrNotSupported {
+		t.Skip("backend is not a ConditionalWriter")
+	}
+	mustNoErr(t, err)
+	if !ok {
+		t.Errorf("SetIfAbsent = false for a missing key")
+	}
+	assertValue(t, db, key, []byte("1"))
+
+	assertConflict := func(op string, ok bool, err error, current []byte) {
+		t.Helper()
+		if ok || !errors.Is(err, locketdb.ErrConflict) {
+			t.Fatalf("%s: got %v, %v, want a conflict", op, ok, err)
+		}
+		var conflict *locketdb.ConflictError
+		if !errors.As(err, &conflict) {
+			t.Fatalf("%s: got error %T, want *ConflictError", op, err)
+		}
+		if !bytes.Equal(conflict.Key, key) || !bytes.Equal(conflict.Current, current) ||
+			(conflict.Current == nil) != (current == nil) {
+			t.Errorf("%s: conflict on %q with current %q, want %q with %q", op, conflict.Key, conflict.Current, key, current)
+		}
+	}
+	ok, err = locketdb.SetIfAbsent(db, key, []byte("2"))
+	assertConflict("SetIfAbsent", ok, err, []byte("1"))
+	ok, err = locketdb.CompareAndSwap(db, key, []byte("2"), []byte("3"))
+	assertConflict("CompareAndSwap", ok, err, []byte("1"))
+	ok, err = locketdb.CompareAndSwap(db, key, nil, []byte("3"))
+	assertConflict("CompareAndSwap", ok, err, []byte("1"))
+	assertValue(t, db, key, []byte("1"))
+
+	ok, err = locketdb.CompareAndSwap(db, key, []byte("1"), []byte{})
+	mustNoErr(t, err)
+	if !ok {
+		t.Errorf("CompareAndSwap = false for the expected value")
+	}
+	assertValue(t, db, key, []byte{})
+
+	// An empty value is not the same as a missing key.
+	ok, err = locketdb.CompareAndSwap(db, key, nil, []byte("4"))
+	assertConflict("CompareAndSwap", ok, err, []byte{})
+
+	// A nil new value deletes the key.
+	_, err = locketdb.CompareAndSwap(db, key, []byte{}, nil)
+	mustNoErr(t, err)
+	assertValue(t, db, key, nil)
+	ok, err = locketdb.CompareAndSwap(db, key, []byte{}, []byte("5"))
+	assertConflict("CompareAndSwap", ok, err, nil)
+
+	_, err = locketdb.CompareAndSwap(db, nil, nil, []byte("v"))
+	assertErr(t, "CompareAndSwap", err, locketdb.ErrKeyEmpty)
+	_, err = locketdb.SetIfAbsent(db, nil, []byte("v"))
+	assertErr(t, "SetIfAbsent", err, locketdb.ErrKeyEmpty)
+	_, err = locketdb.SetIfAbsent(db, key, nil)
+	assertErr(t, "SetIfAbsent", err, locketdb.ErrValueNil)
+}
+
+func testConditionalWriteConcurrent(t *testing.T, db locketdb.DB) {
+	key := []byte("counter")
+	if _, err := locketdb.SetIfAbsent(db, key, []byte("0")); err == locketdb.ErrNotSupported {
+		t.Skip("backend is not a ConditionalWriter")
+	}
+	const workers, increments = 8, 50
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < increments; {
+				current, err := db.Get(key)
+				if err != nil {
+					errs <- err
+					return
+				}
+				n, _ := strconv.Atoi(string(current))
+				_, err = locketdb.CompareAndSwap(db, key, current, []byte(strconv.Itoa(n+1)))
+				switch {
+				case err == nil:
+					i++
+				case !errors.Is(err, locketdb.ErrConflict):
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+	assertValue(t, db, key, []byte(strconv.Itoa(workers*increments)))
+}
+
+// statsKeyPattern matches the keys described by locketdb.StatsKey.
+var statsKeyPattern = regexp.MustCompile(`^[a-z0-9_]+(\.[a-z0-9_]+)+$`)
+
+// legacyStatsKeyPattern matches the keys goleveldb and bbolt reported before locketdb.StatsKey,
+// such as "leveldb.num-files-at-level0" and "FreePageN", which they still report.
+var legacyStatsKeyPattern = regexp.MustCompile(`^(leveldb\.[a-z-]+[0-9]*|[A-Z][A-Za-z]+)$`)
+
+func testStats(t *testing.T, db locketdb.DB) {
+	mustNoErr(t, db.Set([]byte("key"), []byte("value")))
+	for key := range db.Stats() {
+		if !statsKeyPattern.MatchString(key) && !legacyStatsKeyPattern.MatchString(key) {
+			t.Errorf("Stats key %q does not follow the naming scheme", key)
+		}
+	}
+}
+
+func mustNoErr(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func assertErr(t *testing.T, op string, got, want error) {
+	t.Helper()
+	if got != want {
+		t.Errorf("%s: got error %v, want %v", op, got, want)
+	}
+}
+
+func assertValue(t *testing.T, db locketdb.DB, key, want []byte) {
+	t.Helper()
+	value, err := db.Get(key)
+	mustNoErr(t, err)
+	if !bytes.Equal(value, want) || (value == nil) != (want == nil) {
+		t.Fatalf("Get(%q) = %q, want %q", key, value, want)
+	}
+	ok, err := db.Has(key)
+	mustNoErr(t, err)
+	if ok != (want != nil) {
+		t.Fatalf("Has(%q) = %v, want %v", key, ok, want != nil)
+	}
+}
+
+func assertDomain(t *testing.T, itr locketdb.Iterator, start, end []byte) {
+	t.Helper()
+	ds, de := itr.Domain()
+	if !bytes.Equal(ds, start) || !bytes.Equal(de, end) {
+		t.Errorf("Domain() = [%q, %q), want [%q, %q)", ds, de, start, end)
+	}
+}
+
+// assertKeys drains and closes itr, checking that it yields exactly the given keys in order.
+func assertKeys(t *testing.T, itr locketdb.Iterator, want []string) {
+	t.Helper()
+	defer itr.Close()
+
+	var got []string
+	for ; itr.Valid(); itr.Next() {
+		key, value := itr.Key(), itr.Value()
+		if value == nil {
+			t.Fatalf("Value() at %q is nil", key)
+		}
+		got = append(got, string(key))
+	}
+	mustNoErr(t, itr.Error())
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("iterated keys %q, want %q", got, want)
+	}
+}
+
+// drainPairs drains and closes itr, returning its pairs formatted as key=value.
+func drainPairs(t *testing.T, itr locketdb.Iterator) []string {
+	t.Helper()
+	defer itr.Close()
+
+	var pairs []string
+	for ; itr.Valid(); itr.Next() {
+		pairs = append(pairs, string(itr.Key())+"="+string(itr.Value()))
+	}
+	mustNoErr(t, itr.Error())
+	return pairs
+}
+
+func assertPanics(t *testing.T, op string, fn func()) {
+	t.Helper()
+	defer func() {
+		if recover() == nil {
+			t.Errorf("%s on an invalid iterator did not panic", op)
+		}
+	}()
+	fn()
+}
+
+func reversed(keys []string) []string {
+	if keys == nil {
+		return nil
+	}
+	out := make([]string, len(keys))
+	for i, k := range keys {
+		out[len(keys)-1-i] = k
+	}
+	return out
+}
+
+// skipKnownViolation skips the subtest name if it is listed in skip.
+func skipKnownViolation(t *testing.T, name string, skip []string) {
+	t.Helper()
+	for _, s := range skip {
+		if s == name {
+			t.Skip("known contract violation")
+		}
+	}
+}
@@ -0,0 +1,313 @@
+package locketdbtest
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/meission/locketdb"
+)
+
+// RunMergeConformance runs the Merger contract tests against databases created by newDB with the
+// given MergeOperator. Every call to newDB must return a new, empty database; the suite closes it
+// when done. The subtests named in skip are skipped, as in RunConformance.
+func RunMergeConformance(t *testing.T, newDB func(op locketdb.MergeOperator) locketdb.DB, skip ...string) {
+	tests := []struct {
+		name string
+		op   locketdb.MergeOperator
+		fn   func(t *testing.T, db locketdb.DB)
+	}{
+		{"Merge", concat, testMerge},
+		{"MergeIterator", concat, testMergeIterator},
+		{"MergeSnapshot", concat, testMergeSnapshot},
+		{"MergeCompact", concat, testMergeCompact},
+		{"MergeBatch", concat, testMergeBatch},
+		{"MergeBatchDeleteRange", concat, testMergeBatchDeleteRange},
+		{"MergeBatchOverwrite", concat, testMergeBatchOverwrite},
+		{"MergeConcurrent", locketdb.Uint64Add, testMergeConcurrent},
+		{"MergeBatchConcurrent", locketdb.Uint64Add, testMergeBatchConcurrent},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			skipKnownViolation(t, tc.name, skip)
+			db := newDB(tc.op)
+			defer func() {
+				if err := db.Close(); err != nil {
+					t.Errorf("close: %v", err)
+				}
+			}()
+			if _, ok := db.(locketdb.Merger); !ok {
+				t.Fatalf("%T is not a Merger", db)
+			}
+			tc.fn(t, db)
+		})
+	}
+}
+
+// concat concatenates values, which is associative but not commutative and so catches operands
+// merged out of order.
+var concat locketdb.MergeOperator = concatOperator{}
+
+type concatOperator struct{}
+
+func (concatOperator) Name() string {
+	return "locketdbtest.concat"
+}
+
+func (concatOperator) Merge(older, newer []byte) ([]byte, error) {
+	return append(append([]byte{}, older...), newer...), nil
+}
+
+func testMerge(t *testing.T, db locketdb.DB) {
+	key := []byte("key")
+	for _, operand := range []string{"a", "b", "c"} {
+		mustNoErr(t, locketdb.Merge(db, key, []byte(operand)))
+	}
+	assertValue(t, db, key, []byte("abc"))
+
+	mustNoErr(t, db.Set(key, []byte("x")))
+	assertValue(t, db, key, []byte("x"))
+	mustNoErr(t, locketdb.Merge(db, key, []byte("y")))
+	assertValue(t, db, key, []byte("xy"))
+
+	mustNoErr(t, db.Delete(key))
+	assertValue(t, db, key, nil)
+	mustNoErr(t, locketdb.Merge(db, key, []byte("z")))
+	assertValue(t, db, key, []byte("z"))
+
+	// An empty operand is a value like any other.
+	mustNoErr(t, locketdb.Merge(db, []byte("empty"), []byte{}))
+	assertValue(t, db, []byte("empty"), []byte{})
+
+	assertErr(t, "Merge", locketdb.Merge(db, nil, []byte("v")), locketdb.ErrKeyEmpty)
+	assertErr(t, "Merge", locketdb.Merge(db, []byte{}, []byte("v")), locketdb.ErrKeyEmpty)
+	assertErr(t, "Merge", locketdb.Merge(db, key, nil), locketdb.ErrValueNil)
+}
+
+func testMergeIterator(t *testing.T, db locketdb.DB) {
+	mustNoErr(t, db.Set([]byte("a"), []byte("1")))
+	mustNoErr(t, locketdb.Merge(db, []byte("a"), []byte("2")))
+	mustNoErr(t, locketdb.Merge(db, []byte("b"), []byte("1")))
+	mustNoErr(t, locketdb.Merge(db, []byte("b"), []byte("2")))
+	mustNoErr(t, locketdb.Merge(db, []byte("b"), []byte("3")))
+	mustNoErr(t, db.Set([]byte("c"), []byte("1")))
+	// A key prefixing another keeps its operands apart.
+	mustNoErr(t, locketdb.Merge(db, []byte("c\x00"), []byte("2")))
+	mustNoErr(t, locketdb.Merge(db, []byte("d"), []byte("1")))
+	mustNoErr(t, db.Delete([]byte("d")))
+
+	want := []string{"a=12", "b=123", "c=1", "c\x00=2"}
+	itr, err := db.Iterator(nil, nil)
+	mustNoErr(t, err)
+	assertPairs(t, itr, want)
+	itr, err = db.ReverseIterator(nil, nil)
+	mustNoErr(t, err)
+	assertPairs(t, itr, reversed(want))
+
+	itr, err = db.Iterator([]byte("b"), []byte("c\x00"))
+	mustNoErr(t, err)
+	assertDomain(t, itr, []byte("b"), []byte("c\x00"))
+	assertPairs(t, itr, []string{"b=123", "c=1"})
+	itr, err = db.ReverseIterator([]byte("a\x00"), []byte("d"))
+	mustNoErr(t, err)
+	assertPairs(t, itr, []string{"c\x00=2", "c=1", "b=123"})
+}
+
+func testMergeSnapshot(t *testing.T, db locketdb.DB) {
+	if _, ok := db.(locketdb.Snapshotter); !ok {
+		t.Skip("backend is not a Snapshotter")
+	}
+	mustNoErr(t, locketdb.Merge(db, []byte("a"), []byte("1")))
+	snap, err := locketdb.NewSnapshot(db)
+	mustNoErr(t, err)
+	defer snap.Close()
+
+	mustNoErr(t, locketdb.Merge(db, []byte("a"), []byte("2")))
+	mustNoErr(t, locketdb.Merge(db, []byte("b"), []byte("1")))
+
+	value, err := snap.Get([]byte("a"))
+	mustNoErr(t, err)
+	if string(value) != "1" {
+		t.Errorf("snapshot Get(a) = %q, want %q", value, "1")
+	}
+	itr, err := snap.Iterator(nil, nil)
+	mustNoErr(t, err)
+	assertPairs(t, itr, []string{"a=1"})
+	assertValue(t, db, []byte("a"), []byte("12"))
+}
+
+func testMergeCompact(t *testing.T, db locketdb.DB) {
+	if _, ok := db.(locketdb.Compacter); !ok {
+		t.Skip("backend is not a Compacter")
+	}
+	var want []string
+	for i := 0; i < 50; i++ {
+		key := []byte(fmt.Sprintf("k%02d", i))
+		if i%3 == 0 {
+			mustNoErr(t, db.Set(key, []byte("s")))
+		}
+		mustNoErr(t, locketdb.Merge(db, key, []byte("a")))
+		mustNoErr(t, locketdb.Merge(db, key, []byte("b")))
+		if i%3 == 0 {
+			want = append(want, string(key)+"=sab")
+		} else {
+			want = append(want, string(key)+"=ab")
+		}
+	}
+	mustNoErr(t, locketdb.Compact(db, []byte("k10"), []byte("k20")))
+	mustNoErr(t, locketdb.Compact(db, nil, nil))
+
+	itr, err := db.Iterator(nil, nil)
+	mustNoErr(t, err)
+	assertPairs(t, itr, want)
+
+	// The folded values keep merging.
+	mustNoErr(t, locketdb.Merge(db, []byte("k00"), []byte("c")))
+	assertValue(t, db, []byte("k00"), []byte("sabc"))
+}
+
+func testMergeBatch(t *testing.T, db locketdb.DB) {
+	mustNoErr(t, db.Set([]byte("a"), []byte("1")))
+	mustNoErr(t, locketdb.Merge(db, []byte("c"), []byte("1")))
+
+	batch := db.NewBatch()
+	defer batch.Close()
+	m, ok := batch.(locketdb.Merger)
+	if !ok {
+		t.Fatalf("%T is not a Merger", batch)
+	}
+	mustNoErr(t, m.Merge([]byte("a"), []byte("2")))
+	mustNoErr(t, m.Merge([]byte("a"), []byte("3")))
+	mustNoErr(t, batch.Set([]byte("b"), []byte("1")))
+	mustNoErr(t, m.Merge([]byte("b"), []byte("2")))
+	mustNoErr(t, batch.Delete([]byte("c")))
+	mustNoErr(t, m.Merge([]byte("c"), []byte("2")))
+	mustNoErr(t, m.Merge([]byte("d"), []byte("1")))
+	mustNoErr(t, batch.Set([]byte("d"), []byte("2")))
+
+	assertErr(t, "Merge", m.Merge(nil, []byte("v")), locketdb.ErrKeyEmpty)
+	assertErr(t, "Merge", m.Merge([]byte("k"), nil), locketdb.ErrValueNil)
+
+	// Nothing is visible before the batch is written.
+	assertValue(t, db, []byte("a"), []byte("1"))
+	assertValue(t, db, []byte("b"), nil)
+
+	mustNoErr(t, batch.Write())
+	assertErr(t, "Merge", m.Merge([]byte("a"), []byte("4")), locketdb.ErrBatchClosed)
+
+	itr, err := db.Iterator(nil, nil)
+	mustNoErr(t, err)
+	assertPairs(t, itr, []string{"a=123", "b=12", "c=2", "d=2"})
+}
+
+func testMergeBatchDeleteRange(t *testing.T, db locketdb.DB) {
+	mustNoErr(t, locketdb.Merge(db, []byte("a"), []byte("1")))
+	mustNoErr(t, locketdb.Merge(db, []byte("b"), []byte("1")))
+
+	batch := db.NewBatch()
+	defer batch.Close()
+	rd, ok := batch.(locketdb.RangeDeleter)
+	if !ok {
+		t.Skip("batch is not a RangeDeleter")
+	}
+	m := batch.(locketdb.Merger)
+	mustNoErr(t, m.Merge([]byte("a"), []byte("2")))
+	err := rd.DeleteRange([]byte("a"), []byte("b"))
+	if err == locketdb.ErrNotSupported {
+		t.Skip("batch does not support DeleteRange")
+	}
+	mustNoErr(t, err)
+	mustNoErr(t, m.Merge([]byte("a"), []byte("3")))
+	mustNoErr(t, m.Merge([]byte("b"), []byte("2")))
+	mustNoErr(t, batch.Write())
+
+	itr, err := db.Iterator(nil, nil)
+	mustNoErr(t, err)
+	assertPairs(t, itr, []string{"a=3", "b=12"})
+}
+
+// testMergeBatchOverwrite checks that the Set and Delete of a batch override the operands merged
+// before the batch is written, not only those merged before they were queued.
+func testMergeBatchOverwrite(t *testing.T, db locketdb.DB) {
+	mustNoErr(t, db.Set([]byte("a"), []byte("1")))
+	mustNoErr(t, db.Set([]byte("b"), []byte("1")))
+
+	batch := db.NewBatch()
+	defer batch.Close()
+	mustNoErr(t, batch.Set([]byte("a"), []byte("x")))
+	mustNoErr(t, batch.Delete([]byte("b")))
+	mustNoErr(t, locketdb.Merge(db, []byte("a"), []byte("2")))
+	mustNoErr(t, locketdb.Merge(db, []byte("b"), []byte("2")))
+	mustNoErr(t, batch.Write())
+
+	assertValue(t, db, []byte("a"), []byte("x"))
+	assertValue(t, db, []byte("b"), nil)
+}
+
+func testMergeConcurrent(t *testing.T, db locketdb.DB) {
+	key := []byte("counter")
+	mergeConcurrently(t, db, key, func() error {
+		return locketdb.Merge(db, key, locketdb.EncodeUint64(1))
+	})
+}
+
+// testMergeBatchConcurrent checks that batches merge into the value of a key when written, not
+// when the merge is queued.
+func testMergeBatchConcurrent(t *testing.T, db locketdb.DB) {
+	key := []byte("counter")
+	mergeConcurrently(t, db, key, func() error {
+		batch := db.NewBatch()
+		defer batch.Close()
+		m, ok := batch.(locketdb.Merger)
+		if !ok {
+			return fmt.Errorf("%T is not a Merger", batch)
+		}
+		if err := m.Merge(key, locketdb.EncodeUint64(1)); err != nil {
+			return err
+		}
+		return batch.Write()
+	})
+}
+
+// mergeConcurrently runs increment, which must add 1 to the value of key with Uint64Add, from
+// several goroutines at once and checks that no increment is lost.
+func mergeConcurrently(t *testing.T, db locketdb.DB, key []byte, increment func() error) {
+	const workers, increments = 8, 50
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < increments; i++ {
+				if err := increment(); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+	assertValue(t, db, key, locketdb.EncodeUint64(workers*increments))
+}
+
+// assertPairs drains and closes itr, checking that it yields exactly the given key=value pairs in
+// order.
+func assertPairs(t *testing.T, itr locketdb.Iterator, want []string) {
+	t.Helper()
+	defer itr.Close()
+
+	var got []string
+	for ; itr.Valid(); itr.Next() {
+		got = append(got, string(itr.Key())+"="+string(itr.Value()))
+	}
+	mustNoErr(t, itr.Error())
+	if fmt.Sprintf("%q", got) != fmt.Sprintf("%q", want) {
+		t.Fatalf("iterated %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,182 @@
+package memdb
+
+import (
+	"github.com/meission/locketdb"
+)
+
+type opType int
+
+const (
+	opTypeSet opType = iota + 1
+	opTypeDelete
+	opTypeDeleteRange
+	opTypeMerge
+)
+
+// operation is a queued write. For opTypeDeleteRange, key and value hold the start and end of the
+// range.
+type operation struct {
+	opType
+	key   []byte
+	value []byte
+}
+
+// memDBBatch stores operations internally and applies them under a single write lock on Write().
+type memDBBatch struct {
+	db  *memDB
+	ops []operation
+}
+
+var (
+	_ locketdb.Batch        = (*memDBBatch)(nil)
+	_ locketdb.RangeDeleter = (*memDBBatch)(nil)
+	_ locketdb.Merger       = (*memDBBatch)(nil)
+)
+
+func newMemDBBatch(db *memDB) *memDBBatch {
+	return &memDBBatch{
+		db:  db,
+		ops: []operation{},
+	}
+}
+
+// Set implements Batch.
+func (b *memDBBatch) Set(key, value []byte) error {
+	if len(key) == 0 {
+		return locketdb.ErrKeyEmpty
+	}
+	if value == nil {
+		return locketdb.ErrValueNil
+	}
+	if b.ops == nil {
+		return locketdb.ErrBatchClosed
+	}
+	b.ops = append(b.ops, operation{opTypeSet, key, value})
+	return nil
+}
+
+// Delete implements Batch.
+func (b *memDBBatch) Delete(key []byte) error {
+	if len(key) == 0 {
+		return locketdb.ErrKeyEmpty
+	}
+	if b.ops == nil {
+		return locketdb.ErrBatchClosed
+	}
+	b.ops = append(b.ops, operation{opTypeDelete, key, nil})
+	return nil
+}
+
+// Merge implements Merger. It returns ErrNotSupported if the database was opened without a
+// MergeOperator.
+func (b *memDBBatch) Merge(key, operand []byte) error {
+	if len(key) == 0 {
+		return locketdb.ErrKeyEmpty
+	}
+	if operand == nil {
+		return locketdb.ErrValueNil
+	}
+	if b.db.merge == nil {
+		return locketdb.ErrNotSupported
+	}
+	if b.ops == nil {
+		return locketdb.ErrBatchClosed
+	}
+	b.ops = append(b.ops, operation{opTypeMerge, key, operand})
+	return nil
+}
+
+// DeleteRange implements RangeDeleter.
+func (b *memDBBatch) DeleteRange(start, end []byte) error {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return locketdb.ErrKeyEmpty
+	}
+	if b.ops == nil {
+		return locketdb.ErrBatchClosed
+	}
+	b.ops = append(b.ops, operation{opTypeDeleteRange, start, end})
+	return nil
+}
+
+// Write implements Batch.
+func (b *memDBBatch) Write() error {
+	if b.ops == nil {
+		return locketdb.ErrBatchClosed
+	}
+	b.db.mtx.Lock()
+	values, err := b.mergedValues()
+	if err != nil {
+		b.db.mtx.Unlock()
+		return err
+	}
+	for i, op := range b.ops {
+		switch op.opType {
+		case opTypeSet:
+			b.db.set(op.key, op.value)
+		case opTypeMerge:
+			b.db.list.set(cp(op.key), values[i])
+		case opTypeDelete:
+			b.db.list.delete(op.key)
+		case opTypeDeleteRange:
+			b.db.list.deleteRange(op.key, op.value)
+		}
+	}
+	b.db.mtx.Unlock()
+
+	// Make sure batch cannot be used afterwards. Callers should still call Close(), for errors.
+	return b.Close()
+}
+
+// mergedValues folds the merges of the batch before any operation is applied, so that a failing
+// merge leaves the database untouched. It returns the value each merge sets, by operation index.
+// The caller must hold the write lock.
+func (b *memDBBatch) mergedValues() (map[int][]byte, error) {
+	values := map[int][]byte{}
+	// pending holds the values the batch writes before the current operation, nil if deleted, and
+	// ranges the ranges it deletes. A key in pending is not affected by the ranges before it.
+	pending := map[string][]byte{}
+	var ranges []operation
+	for i, op := range b.ops {
+		switch op.opType {
+		case opTypeSet:
+			pending[string(op.key)] = op.value
+		case opTypeDelete:
+			pending[string(op.key)] = nil
+		case opTypeDeleteRange:
+			for key := range pending {
+				if locketdb.IsKeyInDomain([]byte(key), op.key, op.value) {
+					delete(pending, key)
+				}
+			}
+			ranges = append(ranges, op)
+		case opTypeMerge:
+			current, ok := pending[string(op.key)]
+			if !ok {
+				current, ok = b.db.list.get(op.key)
+				for _, r := range ranges {
+					if ok && locketdb.IsKeyInDomain(op.key, r.key, r.value) {
+						current, ok = nil, false
+					}
+				}
+			}
+			value, err := locketdb.MergeValue(b.db.merge, current, op.value)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = value
+			pending[string(op.key)] = value
+		}
+	}
+	return values, nil
+}
+
+// WriteSync implements Batch.
+func (b *memDBBatch) WriteSync() error {
+	return b.Write()
+}
+
+// Close implements Batch.
+func (b *memDBBatch) Close() error {
+	b.ops = nil
+	return nil
+}
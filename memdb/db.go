@@ -0,0 +1,291 @@
+package memdb
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/meission/locketdb"
+)
+
+// memDB is an in-memory database backed by a skiplist. It is mostly useful for tests, and as the
+// reference implementation the other backends are compared against.
+//
+// Reads take a shared lock and writes an exclusive one, so readers never observe a partially
+// applied Batch. Iterators do not hold the lock between calls, they re-seek from their current
+// key on every Next instead.
+type memDB struct {
+	mtx  sync.RWMutex
+	list *skiplist
+
+	// txnMtx serializes the commits of optimistic transactions.
+	txnMtx sync.Mutex
+
+	// merge folds the operands of Merge into the values eagerly, under the write lock. Merge is
+	// not supported when it is nil.
+	merge locketdb.MergeOperator
+}
+
+var (
+	_ locketdb.DB                = (*memDB)(nil)
+	_ locketdb.Snapshotter       = (*memDB)(nil)
+	_ locketdb.Transactional     = (*memDB)(nil)
+	_ locketdb.RangeDeleter      = (*memDB)(nil)
+	_ locketdb.Compacter         = (*memDB)(nil)
+	_ locketdb.KVTyper           = (*memDB)(nil)
+	_ locketdb.MetricsReporter   = (*memDB)(nil)
+	_ locketdb.ConditionalWriter = (*memDB)(nil)
+	_ locketdb.Merger            = (*memDB)(nil)
+)
+
+func init() {
+	locketdb.RegisterEngine(locketdb.MemDB, NewDB)
+	locketdb.RegisterDSNEngine(locketdb.MemDB, OpenDSN)
+	locketdb.RegisterOptionsEngine(locketdb.MemDB, OpenWithOptions)
+}
+
+// NewDB returns a new, empty in-memory database. Both name and dir are ignored.
+func NewDB(name string, dir string) (locketdb.DB, error) {
+	return newMemDB(), nil
+}
+
+// OpenWithOptions returns a new, empty in-memory database, see locketdb.NewDBWithOptions. Sync
+// and FileMode have nothing to apply to and are ignored, while ReadOnly, CacheSize and
+// ErrorIfMissing are not supported since the database always starts empty.
+func OpenWithOptions(name string, dir string, opts locketdb.Options) (locketdb.DB, error) {
+	switch {
+	case opts.ReadOnly:
+		return nil, locketdb.OptionNotSupported(locketdb.MemDB, "ReadOnly")
+	case opts.CacheSize != 0:
+		return nil, locketdb.OptionNotSupported(locketdb.MemDB, "CacheSize")
+	case opts.ErrorIfMissing:
+		return nil, locketdb.OptionNotSupported(locketdb.MemDB, "ErrorIfMissing")
+	}
+	db := newMemDB()
+	db.merge = opts.MergeOperator
+	return db, nil
+}
+
+func newMemDB() *memDB {
+	return &memDB{list: newSkiplist()}
+}
+
+// Get implements DB.
+func (db *memDB) Get(key []byte) ([]byte, error) {
+	if len(key) == 0 {
+		return nil, locketdb.ErrKeyEmpty
+	}
+	db.mtx.RLock()
+	defer db.mtx.RUnlock()
+
+	value, ok := db.list.get(key)
+	if !ok {
+		return nil, nil
+	}
+	return cp(value), nil
+}
+
+// Has implements DB.
+func (db *memDB) Has(key []byte) (bool, error) {
+	if len(key) == 0 {
+		return false, locketdb.ErrKeyEmpty
+	}
+	db.mtx.RLock()
+	defer db.mtx.RUnlock()
+
+	_, ok := db.list.get(key)
+	return ok, nil
+}
+
+// Set implements DB.
+func (db *memDB) Set(key []byte, value []byte) error {
+	if len(key) == 0 {
+		return locketdb.ErrKeyEmpty
+	}
+	if value == nil {
+		return locketdb.ErrValueNil
+	}
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+
+	db.set(key, value)
+	return nil
+}
+
+// SetSync implements DB.
+func (db *memDB) SetSync(key []byte, value []byte) error {
+	return db.Set(key, value)
+}
+
+// set stores copies of key and value. The caller must hold the write lock.
+func (db *memDB) set(key []byte, value []byte) {
+	db.list.set(cp(key), cp(value))
+}
+
+// Delete implements DB.
+func (db *memDB) Delete(key []byte) error {
+	if len(key) == 0 {
+		return locketdb.ErrKeyEmpty
+	}
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+
+	db.list.delete(key)
+	return nil
+}
+
+// DeleteSync implements DB.
+func (db *memDB) DeleteSync(key []byte) error {
+	return db.Delete(key)
+}
+
+// CompareAndSwap implements ConditionalWriter.
+func (db *memDB) CompareAndSwap(key, expected, new []byte) (bool, error) {
+	if len(key) == 0 {
+		return false, locketdb.ErrKeyEmpty
+	}
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+
+	current, _ := db.list.get(key)
+	if err := locketdb.CheckExpected(key, current, expected); err != nil {
+		return false, err
+	}
+	if new != nil {
+		db.set(key, new)
+	} else {
+		db.list.delete(key)
+	}
+	return true, nil
+}
+
+// SetIfAbsent implements ConditionalWriter.
+func (db *memDB) SetIfAbsent(key, value []byte) (bool, error) {
+	if len(key) == 0 {
+		return false, locketdb.ErrKeyEmpty
+	}
+	if value == nil {
+		return false, locketdb.ErrValueNil
+	}
+	return db.CompareAndSwap(key, nil, value)
+}
+
+// Merge implements Merger. It returns ErrNotSupported if the database was opened without a
+// MergeOperator.
+func (db *memDB) Merge(key, operand []byte) error {
+	if len(key) == 0 {
+		return locketdb.ErrKeyEmpty
+	}
+	if operand == nil {
+		return locketdb.ErrValueNil
+	}
+	if db.merge == nil {
+		return locketdb.ErrNotSupported
+	}
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+
+	current, _ := db.list.get(key)
+	value, err := locketdb.MergeValue(db.merge, current, operand)
+	if err != nil {
+		return err
+	}
+	db.list.set(cp(key), value)
+	return nil
+}
+
+// DeleteRange implements RangeDeleter.
+func (db *memDB) DeleteRange(start, end []byte) error {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return locketdb.ErrKeyEmpty
+	}
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+
+	db.list.deleteRange(start, end)
+	return nil
+}
+
+// Compact implements Compacter. Deleted keys are unlinked from the skiplist right away, so there
+// is nothing to reclaim.
+func (db *memDB) Compact(start, end []byte) error {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return locketdb.ErrKeyEmpty
+	}
+	return nil
+}
+
+// KVType implements KVTyper.
+func (db *memDB) KVType() locketdb.KVType {
+	return locketdb.MemDB
+}
+
+// Close implements DB.
+func (db *memDB) Close() error {
+	return nil
+}
+
+// Print implements DB.
+func (db *memDB) Print() error {
+	db.mtx.RLock()
+	defer db.mtx.RUnlock()
+
+	for n := db.list.first(); n != nil; n = n.next[0] {
+		fmt.Printf("[%X]:\t[%X]\n", n.key, n.value)
+	}
+	return nil
+}
+
+// Metrics implements MetricsReporter. Nothing is written to disk or compacted.
+func (db *memDB) Metrics() locketdb.Metrics {
+	db.mtx.RLock()
+	defer db.mtx.RUnlock()
+
+	return locketdb.Metrics{
+		DiskBytes:              locketdb.KnownMetric(0),
+		LiveKeys:               locketdb.KnownMetric(uint64(db.list.len)),
+		PendingCompactionBytes: locketdb.KnownMetric(0),
+	}
+}
+
+// Stats implements DB.
+func (db *memDB) Stats() map[string]string {
+	db.mtx.RLock()
+	defer db.mtx.RUnlock()
+
+	return map[string]string{
+		"memdb.keys": fmt.Sprint(db.list.len),
+	}
+}
+
+// Begin implements Transactional. Transactions are optimistic: they read from a snapshot and fail
+// to commit with ErrConflict if anything they read was modified in the meantime.
+func (db *memDB) Begin(writable bool) (locketdb.Txn, error) {
+	return locketdb.NewOptimisticTxn(db, &db.txnMtx, writable)
+}
+
+// NewBatch implements DB.
+func (db *memDB) NewBatch() locketdb.Batch {
+	return newMemDBBatch(db)
+}
+
+// Iterator implements DB.
+func (db *memDB) Iterator(start, end []byte) (locketdb.Iterator, error) {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return nil, locketdb.ErrKeyEmpty
+	}
+	return newMemDBIterator(db, start, end, false), nil
+}
+
+// ReverseIterator implements DB.
+func (db *memDB) ReverseIterator(start, end []byte) (locketdb.Iterator, error) {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return nil, locketdb.ErrKeyEmpty
+	}
+	return newMemDBIterator(db, start, end, true), nil
+}
+
+func cp(bz []byte) (ret []byte) {
+	ret = make([]byte, len(bz))
+	copy(ret, bz)
+	return ret
+}
@@ -0,0 +1,93 @@
+package memdb
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/meission/locketdb"
+	"github.com/meission/locketdb/locketdbtest"
+)
+
+func TestConformance(t *testing.T) {
+	locketdbtest.RunConformance(t, func() locketdb.DB {
+		db, err := locketdb.NewDB("test", locketdb.MemDB, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return db
+	})
+}
+
+func TestMergeConformance(t *testing.T) {
+	locketdbtest.RunMergeConformance(t, func(op locketdb.MergeOperator) locketdb.DB {
+		db, err := OpenWithOptions("test", "", locketdb.Options{MergeOperator: op})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return db
+	})
+}
+
+func TestConcurrentReadWrite(t *testing.T) {
+	db, err := NewDB("test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				key := []byte(fmt.Sprintf("%d/%04d", w, i))
+				if err := db.Set(key, key); err != nil {
+					t.Error(err)
+					return
+				}
+				if i%3 == 0 {
+					if err := db.Delete(key); err != nil {
+						t.Error(err)
+						return
+					}
+				}
+			}
+		}(w)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				itr, err := db.Iterator(nil, nil)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				var prev []byte
+				for ; itr.Valid(); itr.Next() {
+					if prev != nil && bytes.Compare(prev, itr.Key()) >= 0 {
+						t.Errorf("keys out of order: %q before %q", prev, itr.Key())
+					}
+					prev = itr.Key()
+				}
+				itr.Close()
+			}
+		}()
+	}
+	wg.Wait()
+
+	n := 0
+	itr, err := db.Iterator(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for ; itr.Valid(); itr.Next() {
+		n++
+	}
+	itr.Close()
+	if want := 4 * (500 - 167); n != want {
+		t.Errorf("iterated %d keys, want %d", n, want)
+	}
+}
@@ -0,0 +1,14 @@
+package memdb
+
+import (
+	"github.com/meission/locketdb"
+)
+
+// OpenDSN returns a new, empty in-memory database, see locketdb.Open. The DSN path is ignored and
+// no options are supported.
+func OpenDSN(name string, dir string, params *locketdb.DSNParams) (locketdb.DB, error) {
+	if err := params.Err(); err != nil {
+		return nil, err
+	}
+	return NewDB(name, dir)
+}
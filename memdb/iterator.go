@@ -0,0 +1,193 @@
+package memdb
+
+import (
+	"bytes"
+
+	"github.com/meission/locketdb"
+)
+
+// memDBIterator walks the skiplist of a memDB. The current key and value are copied out of the
+// list, and every move re-seeks relative to the current key under a read lock, so the iterator
+// stays usable when the list is modified outside of its domain.
+type memDBIterator struct {
+	db    *memDB
+	start []byte
+	end   []byte
+
+	currentKey   []byte
+	currentValue []byte
+
+	isReverse bool
+	isInvalid bool
+}
+
+var _ locketdb.SeekIterator = (*memDBIterator)(nil)
+
+func newMemDBIterator(db *memDB, start, end []byte, isReverse bool) *memDBIterator {
+	iter := &memDBIterator{
+		db:        db,
+		start:     start,
+		end:       end,
+		isReverse: isReverse,
+	}
+	iter.First()
+	return iter
+}
+
+func (iter *memDBIterator) setCurrent(n *node) {
+	if n == nil {
+		iter.currentKey, iter.currentValue = nil, nil
+		return
+	}
+	iter.currentKey, iter.currentValue = n.key, n.value
+}
+
+// Domain implements Iterator.
+func (iter *memDBIterator) Domain() ([]byte, []byte) {
+	return iter.start, iter.end
+}
+
+// Valid implements Iterator.
+func (iter *memDBIterator) Valid() bool {
+	if iter.isInvalid {
+		return false
+	}
+
+	// iterated to the end of the list
+	if iter.currentKey == nil {
+		iter.isInvalid = true
+		return false
+	}
+
+	// Prev and Seek may move past either bound.
+	if !locketdb.IsKeyInDomain(iter.currentKey, iter.start, iter.end) {
+		iter.isInvalid = true
+		return false
+	}
+	return true
+}
+
+// Next implements Iterator.
+func (iter *memDBIterator) Next() {
+	iter.assertIsValid()
+
+	iter.db.mtx.RLock()
+	defer iter.db.mtx.RUnlock()
+
+	if iter.isReverse {
+		iter.setCurrent(iter.db.list.findLT(iter.currentKey))
+	} else {
+		iter.setCurrent(iter.db.list.findGT(iter.currentKey))
+	}
+}
+
+// Prev implements SeekIterator.
+func (iter *memDBIterator) Prev() {
+	iter.assertIsValid()
+
+	iter.db.mtx.RLock()
+	defer iter.db.mtx.RUnlock()
+
+	if iter.isReverse {
+		iter.setCurrent(iter.db.list.findGT(iter.currentKey))
+	} else {
+		iter.setCurrent(iter.db.list.findLT(iter.currentKey))
+	}
+}
+
+// Seek implements SeekIterator.
+func (iter *memDBIterator) Seek(key []byte) {
+	iter.db.mtx.RLock()
+	defer iter.db.mtx.RUnlock()
+
+	iter.isInvalid = false
+	if !iter.isReverse {
+		if iter.start != nil && bytes.Compare(key, iter.start) < 0 {
+			key = iter.start
+		}
+		iter.setCurrent(iter.db.list.findGE(key, nil))
+		return
+	}
+	if iter.end != nil && bytes.Compare(key, iter.end) >= 0 {
+		iter.setCurrent(iter.seekEnd())
+		return
+	}
+	// The greatest key <= key is either key itself or the one before it.
+	n := iter.db.list.findGE(key, nil)
+	if n == nil || !bytes.Equal(n.key, key) {
+		n = iter.db.list.findLT(key)
+	}
+	iter.setCurrent(n)
+}
+
+// First implements SeekIterator.
+func (iter *memDBIterator) First() {
+	iter.db.mtx.RLock()
+	defer iter.db.mtx.RUnlock()
+
+	iter.isInvalid = false
+	if iter.isReverse {
+		iter.setCurrent(iter.seekEnd())
+	} else {
+		iter.setCurrent(iter.seekStart())
+	}
+}
+
+// Last implements SeekIterator.
+func (iter *memDBIterator) Last() {
+	iter.db.mtx.RLock()
+	defer iter.db.mtx.RUnlock()
+
+	iter.isInvalid = false
+	if iter.isReverse {
+		iter.setCurrent(iter.seekStart())
+	} else {
+		iter.setCurrent(iter.seekEnd())
+	}
+}
+
+// seekStart returns the node of the smallest key of the domain. The caller must hold the read
+// lock.
+func (iter *memDBIterator) seekStart() *node {
+	if iter.start == nil {
+		return iter.db.list.first()
+	}
+	return iter.db.list.findGE(iter.start, nil)
+}
+
+// seekEnd returns the node of the greatest key of the domain. The caller must hold the read lock.
+func (iter *memDBIterator) seekEnd() *node {
+	if iter.end == nil {
+		return iter.db.list.last()
+	}
+	return iter.db.list.findLT(iter.end)
+}
+
+// Key implements Iterator.
+func (iter *memDBIterator) Key() []byte {
+	iter.assertIsValid()
+	return cp(iter.currentKey)
+}
+
+// Value implements Iterator.
+func (iter *memDBIterator) Value() []byte {
+	iter.assertIsValid()
+	return cp(iter.currentValue)
+}
+
+// Error implements Iterator.
+func (iter *memDBIterator) Error() error {
+	return nil
+}
+
+// Close implements Iterator.
+func (iter *memDBIterator) Close() error {
+	iter.setCurrent(nil)
+	return nil
+}
+
+func (iter *memDBIterator) assertIsValid() {
+	if !iter.Valid() {
+		panic("iterator is invalid")
+	}
+}
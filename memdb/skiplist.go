@@ -0,0 +1,167 @@
+package memdb
+
+import (
+	"bytes"
+	"math/rand"
+)
+
+const (
+	maxHeight = 24
+	pBranch   = 4 // each level holds roughly 1/pBranch of the nodes of the level below
+)
+
+type node struct {
+	key   []byte
+	value []byte
+	next  []*node
+}
+
+// skiplist is an ordered map of byte slices. It is not safe for concurrent use; memDB guards it
+// with a sync.RWMutex.
+type skiplist struct {
+	head   *node
+	height int
+	len    int
+	rnd    *rand.Rand
+}
+
+func newSkiplist() *skiplist {
+	return &skiplist{
+		head:   &node{next: make([]*node, maxHeight)},
+		height: 1,
+		rnd:    rand.New(rand.NewSource(0xdeadbeef)),
+	}
+}
+
+func (s *skiplist) randomHeight() int {
+	h := 1
+	for h < maxHeight && s.rnd.Intn(pBranch) == 0 {
+		h++
+	}
+	return h
+}
+
+// findGE returns the first node whose key is >= key, or nil. If prev is not nil, it is filled
+// with the last node before that position on every level.
+func (s *skiplist) findGE(key []byte, prev []*node) *node {
+	x := s.head
+	for level := s.height - 1; level >= 0; level-- {
+		for next := x.next[level]; next != nil && bytes.Compare(next.key, key) < 0; next = x.next[level] {
+			x = next
+		}
+		if prev != nil {
+			prev[level] = x
+		}
+	}
+	return x.next[0]
+}
+
+// findGT returns the first node whose key is > key, or nil.
+func (s *skiplist) findGT(key []byte) *node {
+	n := s.findGE(key, nil)
+	if n != nil && bytes.Equal(n.key, key) {
+		n = n.next[0]
+	}
+	return n
+}
+
+// findLT returns the last node whose key is < key, or nil.
+func (s *skiplist) findLT(key []byte) *node {
+	x := s.head
+	for level := s.height - 1; level >= 0; level-- {
+		for next := x.next[level]; next != nil && bytes.Compare(next.key, key) < 0; next = x.next[level] {
+			x = next
+		}
+	}
+	if x == s.head {
+		return nil
+	}
+	return x
+}
+
+// first returns the first node, or nil if the list is empty.
+func (s *skiplist) first() *node {
+	return s.head.next[0]
+}
+
+// last returns the last node, or nil if the list is empty.
+func (s *skiplist) last() *node {
+	x := s.head
+	for level := s.height - 1; level >= 0; level-- {
+		for x.next[level] != nil {
+			x = x.next[level]
+		}
+	}
+	if x == s.head {
+		return nil
+	}
+	return x
+}
+
+func (s *skiplist) get(key []byte) ([]byte, bool) {
+	n := s.findGE(key, nil)
+	if n == nil || !bytes.Equal(n.key, key) {
+		return nil, false
+	}
+	return n.value, true
+}
+
+func (s *skiplist) set(key, value []byte) {
+	var prev [maxHeight]*node
+	n := s.findGE(key, prev[:])
+	if n != nil && bytes.Equal(n.key, key) {
+		n.value = value
+		return
+	}
+
+	h := s.randomHeight()
+	if h > s.height {
+		for level := s.height; level < h; level++ {
+			prev[level] = s.head
+		}
+		s.height = h
+	}
+	n = &node{key: key, value: value, next: make([]*node, h)}
+	for level := 0; level < h; level++ {
+		n.next[level] = prev[level].next[level]
+		prev[level].next[level] = n
+	}
+	s.len++
+}
+
+func (s *skiplist) delete(key []byte) {
+	var prev [maxHeight]*node
+	n := s.findGE(key, prev[:])
+	if n == nil || !bytes.Equal(n.key, key) {
+		return
+	}
+	for level := 0; level < len(n.next); level++ {
+		prev[level].next[level] = n.next[level]
+	}
+	for s.height > 1 && s.head.next[s.height-1] == nil {
+		s.height--
+	}
+	s.len--
+}
+
+// clone returns a copy of the list. Keys and values are shared, they are never modified in place.
+func (s *skiplist) clone() *skiplist {
+	c := newSkiplist()
+	for n := s.first(); n != nil; n = n.next[0] {
+		c.set(n.key, n.value)
+	}
+	return c
+}
+
+// deleteRange deletes every key in [start, end). A nil start or end leaves that side unbounded.
+func (s *skiplist) deleteRange(start, end []byte) {
+	n := s.first()
+	if start != nil {
+		n = s.findGE(start, nil)
+	}
+	for n != nil && (end == nil || bytes.Compare(n.key, end) < 0) {
+		next := n.next[0]
+		s.delete(n.key)
+		n = next
+	}
+}
@@ -0,0 +1,46 @@
+package memdb
+
+import (
+	"github.com/meission/locketdb"
+)
+
+// memDBSnapshot is a private copy of the database taken under the read lock. Taking a snapshot
+// is O(n), which is acceptable for an in-memory database mostly used in tests.
+type memDBSnapshot struct {
+	db *memDB
+}
+
+var _ locketdb.Snapshot = (*memDBSnapshot)(nil)
+
+// NewSnapshot implements Snapshotter.
+func (db *memDB) NewSnapshot() (locketdb.Snapshot, error) {
+	db.mtx.RLock()
+	defer db.mtx.RUnlock()
+
+	return &memDBSnapshot{db: &memDB{list: db.list.clone()}}, nil
+}
+
+// Get implements Snapshot.
+func (s *memDBSnapshot) Get(key []byte) ([]byte, error) {
+	return s.db.Get(key)
+}
+
+// Has implements Snapshot.
+func (s *memDBSnapshot) Has(key []byte) (bool, error) {
+	return s.db.Has(key)
+}
+
+// Iterator implements Snapshot.
+func (s *memDBSnapshot) Iterator(start, end []byte) (locketdb.Iterator, error) {
+	return s.db.Iterator(start, end)
+}
+
+// ReverseIterator implements Snapshot.
+func (s *memDBSnapshot) ReverseIterator(start, end []byte) (locketdb.Iterator, error) {
+	return s.db.ReverseIterator(start, end)
+}
+
+// Close implements Snapshot.
+func (s *memDBSnapshot) Close() error {
+	return nil
+}
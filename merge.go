@@ -0,0 +1,173 @@
+package locketdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// MergeOperator combines the values of a key, so that updates such as increments can be written
+// blindly with Merge instead of a Get followed by a Set. A database is given its operator when it
+// is opened, see Options.MergeOperator.
+//
+// Operands and values share one encoding: a value set with Set is merged like any operand, and a
+// merge on a missing key stores the operand as is. Backends may combine operands before knowing
+// the value they apply to, so the operation must be associative.
+type MergeOperator interface {
+	// Name identifies the operator. Backends that record it refuse to open a database with an
+	// operator of another name.
+	Name() string
+
+	// Merge returns the combination of an older and a newer value of a key. It must not modify
+	// its arguments, but may return one of them.
+	Merge(older, newer []byte) ([]byte, error)
+}
+
+// Merger is implemented by databases opened with a MergeOperator, and by their batches.
+type Merger interface {
+	// Merge merges operand into the value of key with the MergeOperator of the database.
+	// CONTRACT: key, operand readonly []byte
+	Merge(key, operand []byte) error
+}
+
+// Merge merges operand into the value of key in db, or returns ErrNotSupported if the database
+// was not opened with a MergeOperator.
+func Merge(db DB, key, operand []byte) error {
+	m, ok := db.(Merger)
+	if !ok {
+		return ErrNotSupported
+	}
+	return m.Merge(key, operand)
+}
+
+// MergeValue merges operand into existing, the value of a key or nil if it does not exist, with
+// op. It is meant for backends implementing Merger.
+func MergeValue(op MergeOperator, existing, operand []byte) ([]byte, error) {
+	if existing == nil {
+		return cp(operand), nil
+	}
+	merged, err := op.Merge(existing, operand)
+	if err != nil {
+		return nil, err
+	}
+	return cp(merged), nil
+}
+
+// ErrInvalidOperand is returned when a MergeOperator is given a value it cannot decode.
+var ErrInvalidOperand = errors.New("invalid merge operand")
+
+var (
+	// Uint64Add adds unsigned integers encoded as 8 bytes in big-endian order, wrapping around on
+	// overflow. See EncodeUint64.
+	Uint64Add MergeOperator = uint64AddOperator{}
+
+	// Max keeps the greatest value in byte order, which is also the numeric order of fixed-width
+	// big-endian integers such as those of EncodeUint64.
+	Max MergeOperator = maxOperator{}
+
+	// AppendSet keeps the union of sets of byte strings. See EncodeSet and DecodeSet.
+	AppendSet MergeOperator = appendSetOperator{}
+)
+
+// EncodeUint64 encodes n as an operand of Uint64Add.
+func EncodeUint64(n uint64) []byte {
+	bz := make([]byte, 8)
+	binary.BigEndian.PutUint64(bz, n)
+	return bz
+}
+
+// DecodeUint64 decodes a value merged with Uint64Add.
+func DecodeUint64(bz []byte) (uint64, error) {
+	if len(bz) != 8 {
+		return 0, fmt.Errorf("%w: %d bytes, expected an 8-byte integer", ErrInvalidOperand, len(bz))
+	}
+	return binary.BigEndian.Uint64(bz), nil
+}
+
+type uint64AddOperator struct{}
+
+func (uint64AddOperator) Name() string {
+	return "locketdb.uint64add"
+}
+
+func (uint64AddOperator) Merge(older, newer []byte) ([]byte, error) {
+	a, err := DecodeUint64(older)
+	if err != nil {
+		return nil, err
+	}
+	b, err := DecodeUint64(newer)
+	if err != nil {
+		return nil, err
+	}
+	return EncodeUint64(a + b), nil
+}
+
+type maxOperator struct{}
+
+func (maxOperator) Name() string {
+	return "locketdb.max"
+}
+
+func (maxOperator) Merge(older, newer []byte) ([]byte, error) {
+	if bytes.Compare(older, newer) >= 0 {
+		return older, nil
+	}
+	return newer, nil
+}
+
+// EncodeSet encodes members as an operand of AppendSet: the distinct members in ascending order,
+// each preceded by its length as an uvarint.
+func EncodeSet(members ...[]byte) []byte {
+	sorted := make([][]byte, len(members))
+	copy(sorted, members)
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i], sorted[j]) < 0
+	})
+
+	bz := []byte{}
+	var lenBuf [binary.MaxVarintLen64]byte
+	for i, m := range sorted {
+		if i > 0 && bytes.Equal(m, sorted[i-1]) {
+			continue
+		}
+		bz = append(bz, lenBuf[:binary.PutUvarint(lenBuf[:], uint64(len(m)))]...)
+		bz = append(bz, m...)
+	}
+	return bz
+}
+
+// DecodeSet decodes a value merged with AppendSet, returning its members in ascending order.
+func DecodeSet(bz []byte) ([][]byte, error) {
+	var members [][]byte
+	for len(bz) > 0 {
+		n, size := binary.Uvarint(bz)
+		if size <= 0 || uint64(len(bz)-size) < n {
+			return nil, fmt.Errorf("%w: malformed set", ErrInvalidOperand)
+		}
+		bz = bz[size:]
+		members = append(members, bz[:n:n])
+		bz = bz[n:]
+	}
+	return members, nil
+}
+
+type appendSetOperator struct{}
+
+func (appendSetOperator) Name() string {
+	return "locketdb.appendset"
+}
+
+func (appendSetOperator) Merge(older, newer []byte) ([]byte, error) {
+	a, err := DecodeSet(older)
+	if err != nil {
+		return nil, err
+	}
+	b, err := DecodeSet(newer)
+	if err != nil {
+		return nil, err
+	}
+	// Operands may come from EncodeSet or from Set, so they are sorted again rather than merged.
+	return EncodeSet(append(a, b...)...), nil
+}
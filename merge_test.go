@@ -0,0 +1,236 @@
+package locketdb_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/meission/locketdb"
+	"github.com/meission/locketdb/locketdbtest"
+	"github.com/meission/locketdb/memdb"
+)
+
+func TestMergeOperators(t *testing.T) {
+	tests := []struct {
+		op            locketdb.MergeOperator
+		older, newer  []byte
+		want          []byte
+		wantMalformed bool
+	}{
+		{locketdb.Uint64Add, locketdb.EncodeUint64(40), locketdb.EncodeUint64(2), locketdb.EncodeUint64(42), false},
+		{locketdb.Uint64Add, locketdb.EncodeUint64(1<<64 - 1), locketdb.EncodeUint64(2), locketdb.EncodeUint64(1), false},
+		{locketdb.Uint64Add, []byte("short"), locketdb.EncodeUint64(2), nil, true},
+		{locketdb.Max, []byte("b"), []byte("a"), []byte("b"), false},
+		{locketdb.Max, locketdb.EncodeUint64(255), locketdb.EncodeUint64(256), locketdb.EncodeUint64(256), false},
+		{locketdb.AppendSet, locketdb.EncodeSet([]byte("b"), []byte("a")), locketdb.EncodeSet([]byte("c"), []byte("a")),
+			locketdb.EncodeSet([]byte("a"), []byte("b"), []byte("c")), false},
+		{locketdb.AppendSet, []byte{5, 'a'}, locketdb.EncodeSet(), nil, true},
+	}
+	for _, tc := range tests {
+		got, err := tc.op.Merge(tc.older, tc.newer)
+		if tc.wantMalformed {
+			if !errors.Is(err, locketdb.ErrInvalidOperand) {
+				t.Errorf("%s.Merge(%X, %X): got error %v, want ErrInvalidOperand", tc.op.Name(), tc.older, tc.newer, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, tc.want) {
+			t.Errorf("%s.Merge(%X, %X) = %X, want %X", tc.op.Name(), tc.older, tc.newer, got, tc.want)
+		}
+	}
+
+	members, err := locketdb.DecodeSet(locketdb.EncodeSet([]byte("b"), []byte{}, []byte("a"), []byte("b")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(members) != 3 || len(members[0]) != 0 || string(members[1]) != "a" || string(members[2]) != "b" {
+		t.Errorf("DecodeSet(EncodeSet(b, \"\", a, b)) = %q", members)
+	}
+}
+
+func TestMergeNotSupported(t *testing.T) {
+	db, err := memdb.NewDB("test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := locketdb.Merge(db, []byte("k"), []byte("v")); err != locketdb.ErrNotSupported {
+		t.Fatalf("Merge without a MergeOperator: got %v, want ErrNotSupported", err)
+	}
+}
+
+func newMergeDB(t *testing.T, op locketdb.MergeOperator) *locketdb.MergeDB {
+	t.Helper()
+	db, err := memdb.NewDB("test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mdb, err := locketdb.NewMergeDB(db, op)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mdb
+}
+
+func TestMergeDBConformance(t *testing.T) {
+	locketdbtest.RunConformance(t, func() locketdb.DB {
+		return newMergeDB(t, locketdb.Uint64Add)
+	})
+	locketdbtest.RunMergeConformance(t, func(op locketdb.MergeOperator) locketdb.DB {
+		return newMergeDB(t, op)
+	})
+}
+
+func TestMergePrefixDB(t *testing.T) {
+	locketdbtest.RunMergeConformance(t, func(op locketdb.MergeOperator) locketdb.DB {
+		db, err := memdb.OpenWithOptions("test", "", locketdb.Options{MergeOperator: op})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return locketdb.NewPrefixDB(db, []byte("p/"))
+	})
+}
+
+func TestMergeDBReopen(t *testing.T) {
+	dir := t.TempDir()
+	open := func(op locketdb.MergeOperator) (locketdb.DB, error) {
+		return locketdb.NewDBWithOptions("test", locketdb.GoLevelDB, dir, locketdb.Options{MergeOperator: op})
+	}
+	db, err := open(locketdb.AppendSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := locketdb.Merge(db, []byte("k"), locketdb.EncodeSet([]byte("a"))); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := open(locketdb.Uint64Add); err == nil {
+		t.Fatal("expected opening with another merge operator to fail")
+	}
+
+	// Operands merged after reopening are newer than those merged before.
+	db, err = open(locketdb.AppendSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.Set([]byte("k"), locketdb.EncodeSet([]byte("b"))); err != nil {
+		t.Fatal(err)
+	}
+	if err := locketdb.Merge(db, []byte("k"), locketdb.EncodeSet([]byte("c"))); err != nil {
+		t.Fatal(err)
+	}
+	value, err := db.Get([]byte("k"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := locketdb.EncodeSet([]byte("b"), []byte("c")); !bytes.Equal(value, want) {
+		t.Fatalf("Get(k) = %X, want %X", value, want)
+	}
+}
+
+func TestMergeDBLayoutMismatch(t *testing.T) {
+	for _, kvType := range []locketdb.KVType{locketdb.GoLevelDB, locketdb.BoltDB} {
+		t.Run(string(kvType), func(t *testing.T) {
+			dir := t.TempDir()
+			open := func(name string, op locketdb.MergeOperator) (locketdb.DB, error) {
+				return locketdb.NewDBWithOptions(name, kvType, dir, locketdb.Options{MergeOperator: op})
+			}
+
+			// Keys named like those of a MergeDB are neither hidden nor overwritten.
+			plain, err := open("plain", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			keys := map[string][]byte{"x": []byte("1"), "e": locketdb.EncodeUint64(7), "n": []byte("name")}
+			for key, value := range keys {
+				if err := plain.Set([]byte(key), value); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := plain.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := open("plain", locketdb.Uint64Add); !errors.Is(err, locketdb.ErrMergeLayout) {
+				t.Fatalf("opening a plain database with a merge operator: got %v, want ErrMergeLayout", err)
+			}
+			plain, err = open("plain", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for key, want := range keys {
+				if value, err := plain.Get([]byte(key)); err != nil || !bytes.Equal(value, want) {
+					t.Errorf("Get(%s) = %X, %v, want %X", key, value, err, want)
+				}
+			}
+			if err := plain.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			merged, err := open("merged", locketdb.Uint64Add)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := locketdb.Merge(merged, []byte("x"), locketdb.EncodeUint64(1)); err != nil {
+				t.Fatal(err)
+			}
+			if err := merged.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := open("merged", nil); !errors.Is(err, locketdb.ErrMergeLayout) {
+				t.Fatalf("opening a merged database without a merge operator: got %v, want ErrMergeLayout", err)
+			}
+		})
+	}
+}
+
+func TestMergeDBCompactFolds(t *testing.T) {
+	raw, err := memdb.NewDB("test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := locketdb.NewMergeDB(raw, locketdb.Uint64Add)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for i := 0; i < 10; i++ {
+		if err := db.Merge([]byte("a"), locketdb.EncodeUint64(1)); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.Merge([]byte("b"), locketdb.EncodeUint64(2)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The name, the epoch and 20 operands.
+	if n := countKeys(t, raw); n != 22 {
+		t.Fatalf("%d keys before Compact, want 22", n)
+	}
+	if err := db.Compact([]byte("a"), []byte("b")); err != nil {
+		t.Fatal(err)
+	}
+	if n := countKeys(t, raw); n != 13 {
+		t.Fatalf("%d keys after compacting [a, b), want 13", n)
+	}
+	if err := db.Compact(nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if n := countKeys(t, raw); n != 4 {
+		t.Fatalf("%d keys after compacting everything, want 4", n)
+	}
+	for key, want := range map[string]uint64{"a": 10, "b": 20} {
+		value, err := db.Get([]byte(key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n, err := locketdb.DecodeUint64(value); err != nil || n != want {
+			t.Errorf("Get(%s) = %d, %v, want %d", key, n, err, want)
+		}
+	}
+}
@@ -0,0 +1,601 @@
+package locketdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// A MergeDB lays out the wrapped database as follows, under the reserved namespace ns:
+//
+//	ns | 'n'                                 -> name of the MergeOperator
+//	ns | 'e'                                 -> epoch, as 8 bytes in big-endian order
+//	ns | 'v' | key                           -> value of key, as set with Set
+//	ns | 'o' | escape(key) | 0x00 0x01 | seq -> operand of key, as written by Merge
+//
+// where escape(key) is key with every 0x00 byte replaced by 0x00 0xFF. The escaping keeps the
+// operands in the order of their keys, and the 0x00 0x01 terminator keeps the operands of a key
+// apart from those of the keys it prefixes. seq is the sequence number of the operand as 8 bytes
+// in big-endian order: the epoch, incremented each time the database is opened, in the top
+// mergeSeqBits bits, followed by a counter of the merges since it was opened.
+//
+// The namespace keeps the layout apart from the keys of a database written without a MergeDB, so
+// that opening one with the wrong option is detected instead of hiding or overwriting its keys.
+var (
+	mergeNamespace    = []byte("\x00locketdb.merge\x00")
+	mergeNameKey      = mergeKey('n')
+	mergeEpochKey     = mergeKey('e')
+	mergeValuePrefix  = mergeKey('v')
+	mergeOperandStart = mergeKey('o')
+	mergeOperandEnd   = mergeKey('p')
+)
+
+func mergeKey(tag byte) []byte {
+	return append(cp(mergeNamespace), tag)
+}
+
+// mergeSeqBits is the number of bits of the merge counter in a sequence number.
+const mergeSeqBits = 40
+
+var errMergeOperandKey = errors.New("malformed merge operand key")
+
+// MergeDB wraps a database to implement Merger on backends without native merge operators, as
+// goleveldb and bbolt do when opened with Options.MergeOperator. Merge writes each operand under
+// a key of its own, without reading, and reads fold the operands of a key into its value. Set and
+// Delete remove the pending operands of their key, and Compact folds them for good.
+//
+// A MergeDB owns the whole keyspace of the database it wraps, which must only be written through
+// it. Transactions are not supported.
+type MergeDB struct {
+	// seq counts the merges since the database was opened. It comes first to be 64-bit aligned.
+	seq   uint64
+	epoch uint64
+
+	db DB
+	op MergeOperator
+
+	// mtx is held for reading by writes, and for writing by Compact and by batches setting or
+	// deleting keys, so that no operand is merged between reading the operands of a key and
+	// writing over them.
+	mtx sync.RWMutex
+}
+
+var (
+	_ DB              = (*MergeDB)(nil)
+	_ Merger          = (*MergeDB)(nil)
+	_ Snapshotter     = (*MergeDB)(nil)
+	_ RangeDeleter    = (*MergeDB)(nil)
+	_ Compacter       = (*MergeDB)(nil)
+	_ KVTyper         = (*MergeDB)(nil)
+	_ MetricsReporter = (*MergeDB)(nil)
+)
+
+// ErrMergeLayout is returned when a database is opened with a MergeOperator but was written
+// without one, or the other way around.
+var ErrMergeLayout = errors.New("database layout does not match its merge operator option")
+
+// OpenMergeDB wraps db in a MergeDB merging values with op, as backends without native merge
+// operators do for Options.MergeOperator. With a nil op, it returns db as it is, but fails with
+// ErrMergeLayout if db was written through a MergeDB, whose keys would otherwise be read as plain
+// ones.
+func OpenMergeDB(db DB, op MergeOperator) (DB, error) {
+	if op != nil {
+		return NewMergeDB(db, op)
+	}
+	name, err := db.Get(mergeNameKey)
+	if err != nil {
+		return nil, err
+	}
+	if name != nil {
+		return nil, fmt.Errorf("%w: database was written with merge operator %q", ErrMergeLayout, name)
+	}
+	return db, nil
+}
+
+// NewMergeDB wraps db to merge values with op. It fails if db was written with an operator of
+// another name, and with ErrMergeLayout if db holds keys but was not written through a MergeDB.
+// On a read-only database, it leaves the name and epoch as they are, since merges fail anyway.
+func NewMergeDB(db DB, op MergeOperator) (*MergeDB, error) {
+	name, err := db.Get(mergeNameKey)
+	if err != nil {
+		return nil, err
+	}
+	if name != nil && string(name) != op.Name() {
+		return nil, fmt.Errorf("database was written with merge operator %q, not %q", name, op.Name())
+	}
+	if name == nil {
+		empty, err := isEmpty(db)
+		if err != nil {
+			return nil, err
+		}
+		if !empty {
+			return nil, fmt.Errorf("%w: database holds keys written without a merge operator", ErrMergeLayout)
+		}
+	}
+	raw, err := db.Get(mergeEpochKey)
+	if err != nil {
+		return nil, err
+	}
+	var epoch uint64
+	if raw != nil {
+		if len(raw) != 8 {
+			return nil, fmt.Errorf("malformed merge epoch %X", raw)
+		}
+		epoch = binary.BigEndian.Uint64(raw)
+	}
+	epoch++
+	if epoch >= 1<<(64-mergeSeqBits) {
+		return nil, errors.New("merge epochs exhausted")
+	}
+
+	batch := db.NewBatch()
+	defer batch.Close()
+	err = batch.Set(mergeNameKey, []byte(op.Name()))
+	if err == nil {
+		err = batch.Set(mergeEpochKey, EncodeUint64(epoch))
+	}
+	if err == nil {
+		err = batch.WriteSync()
+	}
+	if err != nil && !errors.Is(err, ErrReadOnly) {
+		return nil, err
+	}
+	return &MergeDB{epoch: epoch, db: db, op: op}, nil
+}
+
+// isEmpty reports whether db holds no key.
+func isEmpty(db DB) (bool, error) {
+	itr, err := db.Iterator(nil, nil)
+	if err != nil {
+		return false, err
+	}
+	defer itr.Close()
+	return !itr.Valid(), itr.Error()
+}
+
+// nextSeq returns the sequence number of a new operand.
+func (m *MergeDB) nextSeq() (uint64, error) {
+	n := atomic.AddUint64(&m.seq, 1)
+	if n >= 1<<mergeSeqBits {
+		return 0, errors.New("too many merges since the database was opened")
+	}
+	return m.epoch<<mergeSeqBits | n, nil
+}
+
+// mergeReader reads a MergeDB, either directly or through a snapshot.
+type mergeReader interface {
+	Get([]byte) ([]byte, error)
+	rangeReader
+}
+
+// view returns a snapshot of the underlying database if it is a Snapshotter, or the database
+// itself otherwise, and a function releasing it. Reading a value and its operands from a snapshot
+// keeps them consistent with each other.
+func (m *MergeDB) view() (mergeReader, func() error, error) {
+	snap, err := NewSnapshot(m.db)
+	if err == ErrNotSupported {
+		return m.db, func() error { return nil }, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return snap, snap.Close, nil
+}
+
+// Get implements DB.
+func (m *MergeDB) Get(key []byte) ([]byte, error) {
+	if len(key) == 0 {
+		return nil, ErrKeyEmpty
+	}
+	r, release, err := m.view()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return m.get(r, key)
+}
+
+func (m *MergeDB) get(r mergeReader, key []byte) ([]byte, error) {
+	if len(key) == 0 {
+		return nil, ErrKeyEmpty
+	}
+	value, err := r.Get(mergeValueKey(key))
+	if err != nil {
+		return nil, err
+	}
+	group := mergeOperandGroup(key)
+	itr, err := r.Iterator(group, cpIncr(group))
+	if err != nil {
+		return nil, err
+	}
+	defer itr.Close()
+	for ; itr.Valid(); itr.Next() {
+		if value, err = MergeValue(m.op, value, itr.Value()); err != nil {
+			return nil, err
+		}
+	}
+	return value, itr.Error()
+}
+
+// Has implements DB.
+func (m *MergeDB) Has(key []byte) (bool, error) {
+	value, err := m.Get(key)
+	return value != nil, err
+}
+
+// Set implements DB.
+func (m *MergeDB) Set(key []byte, value []byte) error {
+	return m.write(func(b *mergeDBBatch) error { return b.Set(key, value) }, false)
+}
+
+// SetSync implements DB.
+func (m *MergeDB) SetSync(key []byte, value []byte) error {
+	return m.write(func(b *mergeDBBatch) error { return b.Set(key, value) }, true)
+}
+
+// Delete implements DB.
+func (m *MergeDB) Delete(key []byte) error {
+	return m.write(func(b *mergeDBBatch) error { return b.Delete(key) }, false)
+}
+
+// DeleteSync implements DB.
+func (m *MergeDB) DeleteSync(key []byte) error {
+	return m.write(func(b *mergeDBBatch) error { return b.Delete(key) }, true)
+}
+
+// write applies op through a batch, which removes the operands of the key atomically with it.
+func (m *MergeDB) write(op func(b *mergeDBBatch) error, sync bool) error {
+	b := newMergeDBBatch(m)
+	defer b.Close()
+	if err := op(b); err != nil {
+		return err
+	}
+	if sync {
+		return b.WriteSync()
+	}
+	return b.Write()
+}
+
+// Merge implements Merger.
+func (m *MergeDB) Merge(key, operand []byte) error {
+	if len(key) == 0 {
+		return ErrKeyEmpty
+	}
+	if operand == nil {
+		return ErrValueNil
+	}
+	seq, err := m.nextSeq()
+	if err != nil {
+		return err
+	}
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+
+	return m.db.Set(mergeOperandKey(key, seq), operand)
+}
+
+// DeleteRange implements RangeDeleter. It returns ErrNotSupported if the batches of the
+// underlying database are not RangeDeleters.
+func (m *MergeDB) DeleteRange(start, end []byte) error {
+	return m.write(func(b *mergeDBBatch) error { return b.DeleteRange(start, end) }, false)
+}
+
+// Compact implements Compacter. It folds the operands within [start, end) into the values of
+// their keys, then compacts the underlying database if it is a Compacter.
+func (m *MergeDB) Compact(start, end []byte) error {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return ErrKeyEmpty
+	}
+	if err := m.fold(start, end); err != nil {
+		return err
+	}
+	if _, ok := m.db.(Compacter); !ok {
+		return nil
+	}
+	vstart, vend := prefixRange(mergeValuePrefix, start, end)
+	if err := Compact(m.db, vstart, vend); err != nil {
+		return err
+	}
+	ostart, oend := mergeOperandRange(start, end)
+	return Compact(m.db, ostart, oend)
+}
+
+// fold replaces the operands within [start, end) by the values they merge into. Each value is
+// written in the same batch as the deletion of its operands, so that readers never see both.
+//
+// Writes are blocked meanwhile, so the database is read directly rather than from a snapshot, one
+// chunk of operands at a time, and no iterator is left open while a chunk is written: bbolt
+// cannot grow its file while a read transaction is open.
+func (m *MergeDB) fold(start, end []byte) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	ostart, oend := mergeOperandRange(start, end)
+	for ostart != nil {
+		groups, next, err := m.scanOperands(ostart, oend)
+		if err != nil {
+			return err
+		}
+		if err := m.foldGroups(groups); err != nil {
+			return err
+		}
+		ostart = next
+	}
+	return nil
+}
+
+// operandGroup holds the keys of the operands of a key.
+type operandGroup struct {
+	key      []byte
+	operands [][]byte
+}
+
+// scanOperands returns the operands within [ostart, oend) grouped by key, up to about
+// defaultBulkBatchSize bytes, and the operand key to resume from, nil once the range is done.
+func (m *MergeDB) scanOperands(ostart, oend []byte) ([]operandGroup, []byte, error) {
+	itr, err := m.db.Iterator(ostart, oend)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer itr.Close()
+
+	var groups []operandGroup
+	size := 0
+	for ; itr.Valid(); itr.Next() {
+		okey := cp(itr.Key())
+		key, err := decodeMergeOperandKey(okey)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(groups) == 0 || !bytes.Equal(key, groups[len(groups)-1].key) {
+			if size >= defaultBulkBatchSize {
+				return groups, okey, nil
+			}
+			groups = append(groups, operandGroup{key: key})
+		}
+		g := &groups[len(groups)-1]
+		g.operands = append(g.operands, okey)
+		size += len(okey) + len(itr.Value())
+	}
+	return groups, nil, itr.Error()
+}
+
+// foldGroups writes the values the groups merge into, and deletes their operands, in one batch.
+func (m *MergeDB) foldGroups(groups []operandGroup) error {
+	if len(groups) == 0 {
+		return nil
+	}
+	batch := m.db.NewBatch()
+	defer batch.Close()
+	for _, g := range groups {
+		value, err := m.get(m.db, g.key)
+		if err != nil {
+			return err
+		}
+		if err := batch.Set(mergeValueKey(g.key), value); err != nil {
+			return err
+		}
+		for _, okey := range g.operands {
+			if err := batch.Delete(okey); err != nil {
+				return err
+			}
+		}
+	}
+	return batch.Write()
+}
+
+// Iterator implements DB.
+func (m *MergeDB) Iterator(start, end []byte) (Iterator, error) {
+	return m.viewIterator(start, end, false)
+}
+
+// ReverseIterator implements DB.
+func (m *MergeDB) ReverseIterator(start, end []byte) (Iterator, error) {
+	return m.viewIterator(start, end, true)
+}
+
+// viewIterator iterates a view of the database, released when the iterator is closed.
+func (m *MergeDB) viewIterator(start, end []byte, reverse bool) (Iterator, error) {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return nil, ErrKeyEmpty
+	}
+	r, release, err := m.view()
+	if err != nil {
+		return nil, err
+	}
+	itr, err := m.iterator(r, start, end, reverse)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	itr.release = release
+	return itr, nil
+}
+
+func (m *MergeDB) iterator(r mergeReader, start, end []byte, reverse bool) (*mergeDBIterator, error) {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return nil, ErrKeyEmpty
+	}
+	open := r.Iterator
+	if reverse {
+		open = r.ReverseIterator
+	}
+	vstart, vend := prefixRange(mergeValuePrefix, start, end)
+	vitr, err := open(vstart, vend)
+	if err != nil {
+		return nil, err
+	}
+	values, err := newPrefixIterator(mergeValuePrefix, start, end, vitr)
+	if err != nil {
+		vitr.Close()
+		return nil, err
+	}
+	ostart, oend := mergeOperandRange(start, end)
+	operands, err := open(ostart, oend)
+	if err != nil {
+		values.Close()
+		return nil, err
+	}
+	return newMergeDBIterator(m.op, start, end, reverse, values, operands), nil
+}
+
+// NewBatch implements DB.
+func (m *MergeDB) NewBatch() Batch {
+	return newMergeDBBatch(m)
+}
+
+// NewSnapshot implements Snapshotter. It returns ErrNotSupported if the underlying database is
+// not a Snapshotter.
+func (m *MergeDB) NewSnapshot() (Snapshot, error) {
+	snap, err := NewSnapshot(m.db)
+	if err != nil {
+		return nil, err
+	}
+	return &mergeDBSnapshot{m: m, source: snap}, nil
+}
+
+// KVType implements KVTyper, reporting the type of the underlying database.
+func (m *MergeDB) KVType() KVType {
+	return TypeOf(m.db)
+}
+
+// Metrics implements MetricsReporter, reporting the metrics of the underlying database.
+func (m *MergeDB) Metrics() Metrics {
+	return MetricsOf(m.db)
+}
+
+// Close implements DB.
+func (m *MergeDB) Close() error {
+	return m.db.Close()
+}
+
+// Print implements DB.
+func (m *MergeDB) Print() error {
+	itr, err := m.Iterator(nil, nil)
+	if err != nil {
+		return err
+	}
+	defer itr.Close()
+	for ; itr.Valid(); itr.Next() {
+		fmt.Printf("[%X]:\t[%X]\n", itr.Key(), itr.Value())
+	}
+	return itr.Error()
+}
+
+// Stats implements DB.
+func (m *MergeDB) Stats() map[string]string {
+	return m.db.Stats()
+}
+
+func mergeValueKey(key []byte) []byte {
+	return append(cp(mergeValuePrefix), key...)
+}
+
+// mergeOperandGroup returns the prefix of the operands of key.
+func mergeOperandGroup(key []byte) []byte {
+	group := make([]byte, 0, len(mergeOperandStart)+len(key)+2)
+	group = append(group, mergeOperandStart...)
+	for _, c := range key {
+		group = append(group, c)
+		if c == 0x00 {
+			group = append(group, 0xFF)
+		}
+	}
+	return append(group, 0x00, 0x01)
+}
+
+func mergeOperandKey(key []byte, seq uint64) []byte {
+	okey := mergeOperandGroup(key)
+	var seqBuf [8]byte
+	binary.BigEndian.PutUint64(seqBuf[:], seq)
+	return append(okey, seqBuf[:]...)
+}
+
+// mergeOperandRange maps the domain [start, end) onto the operands of its keys.
+func mergeOperandRange(start, end []byte) (ostart, oend []byte) {
+	ostart, oend = mergeOperandStart, mergeOperandEnd
+	if start != nil {
+		ostart = mergeOperandGroup(start)
+	}
+	if end != nil {
+		oend = mergeOperandGroup(end)
+	}
+	return ostart, oend
+}
+
+// decodeMergeOperandKey returns the key an operand applies to.
+func decodeMergeOperandKey(okey []byte) ([]byte, error) {
+	if len(okey) < len(mergeOperandStart)+2+8 || !bytes.HasPrefix(okey, mergeOperandStart) {
+		return nil, errMergeOperandKey
+	}
+	escaped := okey[len(mergeOperandStart) : len(okey)-8]
+	key := make([]byte, 0, len(escaped)-2)
+	for i := 0; i < len(escaped); i++ {
+		c := escaped[i]
+		if c != 0x00 {
+			key = append(key, c)
+			continue
+		}
+		if i+1 >= len(escaped) {
+			return nil, errMergeOperandKey
+		}
+		i++
+		switch escaped[i] {
+		case 0xFF:
+			key = append(key, 0x00)
+		case 0x01:
+			if i != len(escaped)-1 {
+				return nil, errMergeOperandKey
+			}
+			return key, nil
+		default:
+			return nil, errMergeOperandKey
+		}
+	}
+	return nil, errMergeOperandKey
+}
+
+// mergeDBSnapshot folds the operands of a snapshot of the underlying database.
+type mergeDBSnapshot struct {
+	m      *MergeDB
+	source Snapshot
+}
+
+var _ Snapshot = (*mergeDBSnapshot)(nil)
+
+// Get implements Snapshot.
+func (s *mergeDBSnapshot) Get(key []byte) ([]byte, error) {
+	return s.m.get(s.source, key)
+}
+
+// Has implements Snapshot.
+func (s *mergeDBSnapshot) Has(key []byte) (bool, error) {
+	value, err := s.Get(key)
+	return value != nil, err
+}
+
+// Iterator implements Snapshot.
+func (s *mergeDBSnapshot) Iterator(start, end []byte) (Iterator, error) {
+	itr, err := s.m.iterator(s.source, start, end, false)
+	if err != nil {
+		return nil, err
+	}
+	return itr, nil
+}
+
+// ReverseIterator implements Snapshot.
+func (s *mergeDBSnapshot) ReverseIterator(start, end []byte) (Iterator, error) {
+	itr, err := s.m.iterator(s.source, start, end, true)
+	if err != nil {
+		return nil, err
+	}
+	return itr, nil
+}
+
+// Close implements Snapshot.
+func (s *mergeDBSnapshot) Close() error {
+	return s.source.Close()
+}
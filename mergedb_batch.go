@@ -0,0 +1,168 @@
+package locketdb
+
+type mergeDBBatch struct {
+	m      *MergeDB
+	source Batch
+	// operands are the operand keys queued by Merge, by key, for Set and Delete to cancel them.
+	operands map[string][][]byte
+	// resets are the operand ranges of the keys set or deleted by the batch. The operands found
+	// there in the database are deleted at Write, so that those merged after the Set or Delete
+	// was queued are overridden too.
+	resets []KeyRange
+}
+
+var (
+	_ Batch        = (*mergeDBBatch)(nil)
+	_ RangeDeleter = (*mergeDBBatch)(nil)
+	_ Merger       = (*mergeDBBatch)(nil)
+)
+
+func newMergeDBBatch(m *MergeDB) *mergeDBBatch {
+	return &mergeDBBatch{
+		m:        m,
+		source:   m.db.NewBatch(),
+		operands: make(map[string][][]byte),
+	}
+}
+
+// Set implements Batch.
+func (b *mergeDBBatch) Set(key, value []byte) error {
+	if len(key) == 0 {
+		return ErrKeyEmpty
+	}
+	if value == nil {
+		return ErrValueNil
+	}
+	if err := b.source.Set(mergeValueKey(key), value); err != nil {
+		return err
+	}
+	return b.deleteOperands(key)
+}
+
+// Delete implements Batch.
+func (b *mergeDBBatch) Delete(key []byte) error {
+	if len(key) == 0 {
+		return ErrKeyEmpty
+	}
+	if err := b.source.Delete(mergeValueKey(key)); err != nil {
+		return err
+	}
+	return b.deleteOperands(key)
+}
+
+// deleteOperands queues a delete for every operand of key merged earlier in the batch, and
+// records its operands in the database to be deleted at Write.
+func (b *mergeDBBatch) deleteOperands(key []byte) error {
+	for _, okey := range b.operands[string(key)] {
+		if err := b.source.Delete(okey); err != nil {
+			return err
+		}
+	}
+	delete(b.operands, string(key))
+
+	group := mergeOperandGroup(key)
+	b.resets = append(b.resets, KeyRange{Start: group, End: cpIncr(group)})
+	return nil
+}
+
+// Merge implements Merger.
+func (b *mergeDBBatch) Merge(key, operand []byte) error {
+	if len(key) == 0 {
+		return ErrKeyEmpty
+	}
+	if operand == nil {
+		return ErrValueNil
+	}
+	seq, err := b.m.nextSeq()
+	if err != nil {
+		return err
+	}
+	okey := mergeOperandKey(key, seq)
+	if err := b.source.Set(okey, operand); err != nil {
+		return err
+	}
+	b.operands[string(key)] = append(b.operands[string(key)], okey)
+	return nil
+}
+
+// DeleteRange implements RangeDeleter. It returns ErrNotSupported if the underlying batch is not
+// a RangeDeleter.
+func (b *mergeDBBatch) DeleteRange(start, end []byte) error {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return ErrKeyEmpty
+	}
+	rd, ok := b.source.(RangeDeleter)
+	if !ok {
+		return ErrNotSupported
+	}
+	vstart, vend := prefixRange(mergeValuePrefix, start, end)
+	if err := rd.DeleteRange(vstart, vend); err != nil {
+		return err
+	}
+	for key, okeys := range b.operands {
+		if !IsKeyInDomain([]byte(key), start, end) {
+			continue
+		}
+		for _, okey := range okeys {
+			if err := b.source.Delete(okey); err != nil {
+				return err
+			}
+		}
+		delete(b.operands, key)
+	}
+	ostart, oend := mergeOperandRange(start, end)
+	b.resets = append(b.resets, KeyRange{Start: ostart, End: oend})
+	return nil
+}
+
+// Write implements Batch.
+func (b *mergeDBBatch) Write() error {
+	return b.write(b.source.Write)
+}
+
+// WriteSync implements Batch.
+func (b *mergeDBBatch) WriteSync() error {
+	return b.write(b.source.WriteSync)
+}
+
+// write deletes the operands of the keys reset by the batch, then writes it with write. Merges
+// are blocked meanwhile, so that none lands between reading the operands and writing the batch.
+func (b *mergeDBBatch) write(write func() error) error {
+	if len(b.resets) == 0 {
+		b.m.mtx.RLock()
+		defer b.m.mtx.RUnlock()
+		return write()
+	}
+	b.m.mtx.Lock()
+	defer b.m.mtx.Unlock()
+	for _, r := range b.resets {
+		if err := b.deleteStoredOperands(r.Start, r.End); err != nil {
+			return err
+		}
+	}
+	b.resets = nil
+	return write()
+}
+
+// deleteStoredOperands queues a delete for every operand within [ostart, oend) in the database.
+// Those merged through the batch are not written yet, and so are kept.
+func (b *mergeDBBatch) deleteStoredOperands(ostart, oend []byte) error {
+	itr, err := b.m.db.Iterator(ostart, oend)
+	if err != nil {
+		return err
+	}
+	defer itr.Close()
+	for ; itr.Valid(); itr.Next() {
+		if err := b.source.Delete(cp(itr.Key())); err != nil {
+			return err
+		}
+	}
+	return itr.Error()
+}
+
+// Close implements Batch.
+func (b *mergeDBBatch) Close() error {
+	b.operands = nil
+	b.resets = nil
+	return b.source.Close()
+}
@@ -0,0 +1,166 @@
+package locketdb
+
+import "bytes"
+
+// mergeDBIterator walks the values and the operands of a MergeDB side by side, yielding each key
+// with its operands folded into its value.
+type mergeDBIterator struct {
+	op      MergeOperator
+	start   []byte
+	end     []byte
+	reverse bool
+	// values yields the keys with a value, without the value prefix, and operands the raw operand
+	// keys, both in the order of the iteration.
+	values   Iterator
+	operands Iterator
+	key      []byte
+	value    []byte
+	valid    bool
+	err      error
+	// release is called on Close, to release the view the iterator reads.
+	release func() error
+}
+
+var _ Iterator = (*mergeDBIterator)(nil)
+
+func newMergeDBIterator(op MergeOperator, start, end []byte, reverse bool, values, operands Iterator) *mergeDBIterator {
+	itr := &mergeDBIterator{
+		op:       op,
+		start:    start,
+		end:      end,
+		reverse:  reverse,
+		values:   values,
+		operands: operands,
+	}
+	itr.step()
+	return itr
+}
+
+// step moves to the next key having a value or operands, and folds them.
+func (itr *mergeDBIterator) step() {
+	itr.valid = false
+
+	var okey []byte
+	if itr.operands.Valid() {
+		k, err := decodeMergeOperandKey(itr.operands.Key())
+		if err != nil {
+			itr.err = err
+			return
+		}
+		okey = k
+	}
+	hasValue := itr.values.Valid()
+
+	var key []byte
+	switch {
+	case !hasValue && okey == nil:
+		return
+	case !hasValue:
+		key = okey
+	case okey == nil:
+		key = cp(itr.values.Key())
+	default:
+		c := bytes.Compare(itr.values.Key(), okey)
+		if itr.reverse {
+			c = -c
+		}
+		if c <= 0 {
+			key = cp(itr.values.Key())
+		} else {
+			key = okey
+		}
+	}
+
+	var value []byte
+	if hasValue && bytes.Equal(itr.values.Key(), key) {
+		value = cp(itr.values.Value())
+		itr.values.Next()
+	}
+	var operands [][]byte
+	for itr.operands.Valid() {
+		k, err := decodeMergeOperandKey(itr.operands.Key())
+		if err != nil {
+			itr.err = err
+			return
+		}
+		if !bytes.Equal(k, key) {
+			break
+		}
+		operands = append(operands, cp(itr.operands.Value()))
+		itr.operands.Next()
+	}
+	// Operands are folded oldest first, which is the reverse of their order in reverse iteration.
+	if itr.reverse {
+		for i, j := 0, len(operands)-1; i < j; i, j = i+1, j-1 {
+			operands[i], operands[j] = operands[j], operands[i]
+		}
+	}
+	for _, operand := range operands {
+		var err error
+		if value, err = MergeValue(itr.op, value, operand); err != nil {
+			itr.err = err
+			return
+		}
+	}
+
+	itr.key, itr.value, itr.valid = key, value, true
+}
+
+// Domain implements Iterator.
+func (itr *mergeDBIterator) Domain() (start []byte, end []byte) {
+	return itr.start, itr.end
+}
+
+// Valid implements Iterator.
+func (itr *mergeDBIterator) Valid() bool {
+	return itr.valid && itr.err == nil
+}
+
+// Next implements Iterator.
+func (itr *mergeDBIterator) Next() {
+	itr.assertIsValid()
+	itr.step()
+}
+
+// Key implements Iterator.
+func (itr *mergeDBIterator) Key() []byte {
+	itr.assertIsValid()
+	return itr.key
+}
+
+// Value implements Iterator.
+func (itr *mergeDBIterator) Value() []byte {
+	itr.assertIsValid()
+	return itr.value
+}
+
+// Error implements Iterator.
+func (itr *mergeDBIterator) Error() error {
+	if err := itr.values.Error(); err != nil {
+		return err
+	}
+	if err := itr.operands.Error(); err != nil {
+		return err
+	}
+	return itr.err
+}
+
+// Close implements Iterator.
+func (itr *mergeDBIterator) Close() error {
+	err := itr.values.Close()
+	if oerr := itr.operands.Close(); err == nil {
+		err = oerr
+	}
+	if itr.release != nil {
+		if rerr := itr.release(); err == nil {
+			err = rerr
+		}
+	}
+	return err
+}
+
+func (itr *mergeDBIterator) assertIsValid() {
+	if !itr.Valid() {
+		panic("iterator is invalid")
+	}
+}
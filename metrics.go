@@ -0,0 +1,61 @@
+package locketdb
+
+import "strconv"
+
+// MetricValue is the value of a metric, which is unknown unless Known is set. Its zero value is
+// unknown, so backends only set the metrics they can report.
+type MetricValue struct {
+	Value uint64
+	Known bool
+}
+
+// KnownMetric returns a known MetricValue.
+func KnownMetric(value uint64) MetricValue {
+	return MetricValue{Value: value, Known: true}
+}
+
+// String implements fmt.Stringer, formatting the value, or "unknown".
+func (v MetricValue) String() string {
+	if !v.Known {
+		return "unknown"
+	}
+	return strconv.FormatUint(v.Value, 10)
+}
+
+// Metrics is a typed summary of the state of a database, filled by each backend from its native
+// statistics. Metrics a backend cannot report are unknown, while metrics that do not apply to it,
+// such as the pending compactions of a B+tree, are zero.
+type Metrics struct {
+	// DiskBytes is the size of the files of the database.
+	DiskBytes MetricValue
+	// LiveKeys is an estimate of the number of keys. Depending on the backend, it may count keys
+	// that were overwritten or deleted but not compacted yet.
+	LiveKeys MetricValue
+	// MemtableBytes is the size of the writes buffered in memory before being written to disk.
+	MemtableBytes MetricValue
+	// CacheHits and CacheMisses count the lookups of the block cache.
+	CacheHits   MetricValue
+	CacheMisses MetricValue
+	// PendingCompactionBytes estimates the bytes that compactions have to rewrite for the
+	// database to reach a stable shape.
+	PendingCompactionBytes MetricValue
+	// OpenIterators is the number of iterators not closed yet.
+	OpenIterators MetricValue
+	// OpenTxns is the number of transactions not committed or rolled back yet, including those
+	// held by iterators and snapshots on backends that read through transactions.
+	OpenTxns MetricValue
+}
+
+// MetricsReporter is implemented by databases that report typed Metrics.
+type MetricsReporter interface {
+	// Metrics returns the current metrics of the database.
+	Metrics() Metrics
+}
+
+// MetricsOf returns the metrics of db, which are all unknown if db does not report them.
+func MetricsOf(db DB) Metrics {
+	if r, ok := db.(MetricsReporter); ok {
+		return r.Metrics()
+	}
+	return Metrics{}
+}
@@ -0,0 +1,34 @@
+package locketdb_test
+
+import (
+	"testing"
+
+	"github.com/meission/locketdb"
+	"github.com/meission/locketdb/memdb"
+)
+
+func TestMetricsOf(t *testing.T) {
+	db, err := memdb.NewDB("test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, key := range []string{"a", "b", "c"} {
+		if err := db.Set([]byte(key), []byte("v")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m := locketdb.MetricsOf(locketdb.NewPrefixDB(db, []byte("p/")))
+	if m.LiveKeys != locketdb.KnownMetric(3) {
+		t.Errorf("LiveKeys = %v, want 3", m.LiveKeys)
+	}
+	if m.CacheHits.Known || m.CacheHits.String() != "unknown" {
+		t.Errorf("CacheHits = %v, want unknown", m.CacheHits)
+	}
+
+	// A database that does not report metrics has them all unknown.
+	if m := locketdb.MetricsOf(struct{ locketdb.DB }{db}); m != (locketdb.Metrics{}) {
+		t.Errorf("MetricsOf a DB without metrics = %+v, want all unknown", m)
+	}
+}
@@ -0,0 +1,158 @@
+package locketdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// MigrateOptions configures Migrate. The zero value copies every key in batches of a few
+// megabytes, without resuming, reporting progress or verifying.
+type MigrateOptions struct {
+	// BatchSize bounds the size in bytes of the keys and values of each batch written to dst.
+	// Zero uses a default of 4MB.
+	BatchSize int
+
+	// Resume continues an interrupted migration after the last key already in dst, instead of
+	// starting from the first key of src.
+	Resume bool
+
+	// Verify makes Migrate compare src and dst once the copy is done, and return an error
+	// wrapping ErrMigrateMismatch if they differ.
+	Verify bool
+
+	// Progress, if not nil, is called after each batch is written to dst.
+	Progress func(MigrateProgress)
+}
+
+// MigrateProgress reports how far a migration got.
+type MigrateProgress struct {
+	// Keys and Bytes count the keys, and the size of their keys and values, copied so far by this
+	// call of Migrate.
+	Keys  uint64
+	Bytes uint64
+
+	// LastKey is the last key written to dst.
+	LastKey []byte
+}
+
+// ErrMigrateMismatch is returned by Migrate when the verification finds that dst differs from src.
+var ErrMigrateMismatch = errors.New("migrated databases differ")
+
+// Migrate copies every key of src into dst, which is meant to be empty, for instance to move a
+// store to another backend. Keys are read in ascending order, from a snapshot if src is a
+// Snapshotter, and written to dst through batches, so that an interrupted migration can be
+// continued with Resume.
+//
+// src can keep serving reads and writes during the migration, but only the keys as of the
+// snapshot are copied: writes made to src during or after the copy are never caught up, and
+// Resume only copies the keys after the last one in dst, not those updated or deleted before it.
+// With Verify, dst is compared to that same snapshot, not to src as it is by then. To move a
+// store that keeps being written, migrate it once while it serves, then stop writing to src and
+// run a final Migrate with Resume and Verify before switching to dst; updates and deletes made to
+// keys already copied require starting over from an empty dst instead.
+func Migrate(src, dst DB, opts MigrateOptions) error {
+	var start []byte
+	if opts.Resume {
+		last, err := lastKey(dst)
+		if err != nil {
+			return err
+		}
+		if last != nil {
+			// The last key was written in the same batch as its value, so it can be skipped.
+			start = append(cp(last), 0)
+		}
+	}
+
+	view, release, err := consistentView(src)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if err := migrateRange(view, dst, start, opts); err != nil {
+		return err
+	}
+	if opts.Verify {
+		return verifyMigration(view, dst)
+	}
+	return nil
+}
+
+// MigrateContext is like Migrate, but stops with the error of ctx once it is done. The keys
+// copied until then remain in dst, and the migration can be continued with Resume. See ContextDB.
+func MigrateContext(ctx context.Context, src, dst DB, opts MigrateOptions) error {
+	return Migrate(NewContextDB(ctx, src), NewContextDB(ctx, dst), opts)
+}
+
+func migrateRange(src rangeReader, dst DB, start []byte, opts MigrateOptions) error {
+	itr, err := src.Iterator(start, nil)
+	if err != nil {
+		return err
+	}
+	defer itr.Close()
+
+	var progress, pending MigrateProgress
+	bw := newBulkWriter(dst, opts.BatchSize)
+	defer bw.close()
+	bw.flushed = func() {
+		progress.Keys += pending.Keys
+		progress.Bytes += pending.Bytes
+		progress.LastKey = pending.LastKey
+		pending = MigrateProgress{}
+		if opts.Progress != nil {
+			opts.Progress(progress)
+		}
+	}
+
+	for ; itr.Valid(); itr.Next() {
+		key, value := itr.Key(), itr.Value()
+		// Account for the key before set, which may write the batch.
+		pending.Keys++
+		pending.Bytes += uint64(len(key) + len(value))
+		pending.LastKey = cp(key)
+		if err := bw.set(key, value); err != nil {
+			return err
+		}
+	}
+	if err := itr.Error(); err != nil {
+		return err
+	}
+	return bw.flush(true)
+}
+
+// verifyMigration reports the first key that differs between src and dst.
+func verifyMigration(src rangeReader, dst DB) error {
+	var first *Difference
+	err := walkDiff(src, dst, nil, nil, func(d Difference) bool {
+		first = &d
+		return false
+	})
+	if err != nil {
+		return err
+	}
+	if first == nil {
+		return nil
+	}
+	switch first.Kind {
+	case DiffRemoved:
+		return fmt.Errorf("%w: key %X missing from destination", ErrMigrateMismatch, first.Key)
+	case DiffAdded:
+		return fmt.Errorf("%w: unexpected key %X in destination", ErrMigrateMismatch, first.Key)
+	default:
+		return fmt.Errorf("%w: value of key %X differs", ErrMigrateMismatch, first.Key)
+	}
+}
+
+// lastKey returns the last key of db, or nil if it is empty.
+func lastKey(db DB) ([]byte, error) {
+	itr, err := db.ReverseIterator(nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer itr.Close()
+	if !itr.Valid() {
+		return nil, itr.Error()
+	}
+	return cp(itr.Key()), nil
+}
@@ -0,0 +1,72 @@
+package locketdb_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/meission/locketdb"
+)
+
+func TestMigrate(t *testing.T) {
+	src, err := locketdb.NewDB("src", locketdb.GoLevelDB, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	for i := 0; i < 1000; i++ {
+		if err := src.Set([]byte(fmt.Sprintf("key%04d", i)), []byte(fmt.Sprintf("value%d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dst, err := locketdb.NewDB("dst", locketdb.Pebble, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+
+	// Simulate a migration interrupted after the first 300 keys.
+	for i := 0; i < 300; i++ {
+		if err := dst.Set([]byte(fmt.Sprintf("key%04d", i)), []byte(fmt.Sprintf("value%d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var calls int
+	var last locketdb.MigrateProgress
+	err = locketdb.Migrate(src, dst, locketdb.MigrateOptions{
+		BatchSize: 1024,
+		Resume:    true,
+		Verify:    true,
+		Progress: func(p locketdb.MigrateProgress) {
+			calls++
+			if p.Keys < last.Keys {
+				t.Errorf("progress went backwards from %d to %d keys", last.Keys, p.Keys)
+			}
+			last = p
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last.Keys != 700 {
+		t.Errorf("expected 700 keys copied after resuming, got %d", last.Keys)
+	}
+	if string(last.LastKey) != "key0999" {
+		t.Errorf("expected the last key to be key0999, got %q", last.LastKey)
+	}
+	if calls < 2 {
+		t.Errorf("expected progress for every batch, got %d calls", calls)
+	}
+
+	if err := dst.Set([]byte("key0500"), []byte("changed")); err != nil {
+		t.Fatal(err)
+	}
+	err = locketdb.Migrate(src, dst, locketdb.MigrateOptions{Resume: true, Verify: true})
+	if !errors.Is(err, locketdb.ErrMigrateMismatch) {
+		t.Fatalf("expected ErrMigrateMismatch, got %v", err)
+	}
+	if err := locketdb.Migrate(src, dst, locketdb.MigrateOptions{Verify: true}); err != nil {
+		t.Fatalf("expected a full migration to repair dst, got %v", err)
+	}
+}
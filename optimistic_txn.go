@@ -0,0 +1,385 @@
+package locketdb
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+)
+
+type txnWrite struct {
+	value   []byte
+	deleted bool
+}
+
+type keyRange struct {
+	start, end []byte
+}
+
+// optimisticTxn implements Txn on top of any DB that is a Snapshotter. Reads are served from a
+// Snapshot taken by Begin, and every key and range read is remembered. Commit takes the commit
+// lock, checks that none of them changed between the snapshot and the current state of the
+// database, and applies the buffered writes through a Batch.
+type optimisticTxn struct {
+	db        DB
+	snap      Snapshot
+	commitMtx *sync.Mutex
+	writable  bool
+
+	reads  map[string]struct{}
+	ranges []keyRange
+	writes map[string]txnWrite
+}
+
+var _ Txn = (*optimisticTxn)(nil)
+
+// NewOptimisticTxn begins an optimistic transaction on db, which must be a Snapshotter. It is
+// meant for backends without native transactions. Commits of all transactions sharing commitMtx
+// are serialized; backends should use a single mutex per database.
+//
+// Conflicts are detected against any write, transactional or not, made before the committing
+// transaction validates its reads. Non-transactional writes racing with the validation itself
+// are not detected.
+func NewOptimisticTxn(db DB, commitMtx *sync.Mutex, writable bool) (Txn, error) {
+	snap, err := NewSnapshot(db)
+	if err != nil {
+		return nil, err
+	}
+	return &optimisticTxn{
+		db:        db,
+		snap:      snap,
+		commitMtx: commitMtx,
+		writable:  writable,
+		reads:     make(map[string]struct{}),
+		writes:    make(map[string]txnWrite),
+	}, nil
+}
+
+// Get implements Txn.
+func (txn *optimisticTxn) Get(key []byte) ([]byte, error) {
+	if len(key) == 0 {
+		return nil, ErrKeyEmpty
+	}
+	if txn.snap == nil {
+		return nil, ErrTxnClosed
+	}
+	if w, ok := txn.writes[string(key)]; ok {
+		if w.deleted {
+			return nil, nil
+		}
+		return w.value, nil
+	}
+	txn.reads[string(key)] = struct{}{}
+	return txn.snap.Get(key)
+}
+
+// Has implements Txn.
+func (txn *optimisticTxn) Has(key []byte) (bool, error) {
+	value, err := txn.Get(key)
+	if err != nil {
+		return false, err
+	}
+	return value != nil, nil
+}
+
+// Set implements Txn.
+func (txn *optimisticTxn) Set(key, value []byte) error {
+	if len(key) == 0 {
+		return ErrKeyEmpty
+	}
+	if value == nil {
+		return ErrValueNil
+	}
+	if err := txn.checkWritable(); err != nil {
+		return err
+	}
+	txn.writes[string(key)] = txnWrite{value: value}
+	return nil
+}
+
+// Delete implements Txn.
+func (txn *optimisticTxn) Delete(key []byte) error {
+	if len(key) == 0 {
+		return ErrKeyEmpty
+	}
+	if err := txn.checkWritable(); err != nil {
+		return err
+	}
+	txn.writes[string(key)] = txnWrite{deleted: true}
+	return nil
+}
+
+func (txn *optimisticTxn) checkWritable() error {
+	if txn.snap == nil {
+		return ErrTxnClosed
+	}
+	if !txn.writable {
+		return ErrTxnReadOnly
+	}
+	return nil
+}
+
+// Iterator implements Txn.
+func (txn *optimisticTxn) Iterator(start, end []byte) (Iterator, error) {
+	return txn.iterator(start, end, false)
+}
+
+// ReverseIterator implements Txn.
+func (txn *optimisticTxn) ReverseIterator(start, end []byte) (Iterator, error) {
+	return txn.iterator(start, end, true)
+}
+
+func (txn *optimisticTxn) iterator(start, end []byte, isReverse bool) (Iterator, error) {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return nil, ErrKeyEmpty
+	}
+	if txn.snap == nil {
+		return nil, ErrTxnClosed
+	}
+	var source Iterator
+	var err error
+	if isReverse {
+		source, err = txn.snap.ReverseIterator(start, end)
+	} else {
+		source, err = txn.snap.Iterator(start, end)
+	}
+	if err != nil {
+		return nil, err
+	}
+	txn.ranges = append(txn.ranges, keyRange{start: start, end: end})
+	return newTxnIterator(source, txn.pendingWrites(start, end, isReverse), isReverse), nil
+}
+
+// pendingWrites returns the buffered writes within [start, end), in iteration order.
+func (txn *optimisticTxn) pendingWrites(start, end []byte, isReverse bool) []pendingWrite {
+	var pending []pendingWrite
+	for k, w := range txn.writes {
+		key := []byte(k)
+		if IsKeyInDomain(key, start, end) {
+			pending = append(pending, pendingWrite{key: key, txnWrite: w})
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		c := bytes.Compare(pending[i].key, pending[j].key)
+		if isReverse {
+			return c > 0
+		}
+		return c < 0
+	})
+	return pending
+}
+
+// Commit implements Txn.
+func (txn *optimisticTxn) Commit() error {
+	if txn.snap == nil {
+		return ErrTxnClosed
+	}
+	defer txn.Rollback()
+
+	if len(txn.writes) == 0 {
+		return nil
+	}
+
+	txn.commitMtx.Lock()
+	defer txn.commitMtx.Unlock()
+
+	if err := txn.validate(); err != nil {
+		return err
+	}
+
+	batch := txn.db.NewBatch()
+	defer batch.Close()
+	for k, w := range txn.writes {
+		var err error
+		if w.deleted {
+			err = batch.Delete([]byte(k))
+		} else {
+			err = batch.Set([]byte(k), w.value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return batch.Write()
+}
+
+// validate returns ErrConflict if any key or range read by the transaction differs between its
+// snapshot and the current state of the database.
+func (txn *optimisticTxn) validate() error {
+	for k := range txn.reads {
+		then, err := txn.snap.Get([]byte(k))
+		if err != nil {
+			return err
+		}
+		now, err := txn.db.Get([]byte(k))
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(then, now) || (then == nil) != (now == nil) {
+			return ErrConflict
+		}
+	}
+	for _, r := range txn.ranges {
+		if err := txn.validateRange(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (txn *optimisticTxn) validateRange(r keyRange) error {
+	then, err := txn.snap.Iterator(r.start, r.end)
+	if err != nil {
+		return err
+	}
+	defer then.Close()
+	now, err := txn.db.Iterator(r.start, r.end)
+	if err != nil {
+		return err
+	}
+	defer now.Close()
+
+	for ; then.Valid() && now.Valid(); then.Next() {
+		if !bytes.Equal(then.Key(), now.Key()) || !bytes.Equal(then.Value(), now.Value()) {
+			return ErrConflict
+		}
+		now.Next()
+	}
+	if then.Valid() != now.Valid() {
+		return ErrConflict
+	}
+	if err := then.Error(); err != nil {
+		return err
+	}
+	return now.Error()
+}
+
+// Rollback implements Txn.
+func (txn *optimisticTxn) Rollback() error {
+	if txn.snap == nil {
+		return nil
+	}
+	err := txn.snap.Close()
+	txn.snap = nil
+	txn.reads = nil
+	txn.ranges = nil
+	txn.writes = nil
+	return err
+}
+
+type pendingWrite struct {
+	key []byte
+	txnWrite
+}
+
+// txnIterator merges an iterator over a snapshot with the pending writes of a transaction, which
+// take precedence over the snapshot.
+type txnIterator struct {
+	source    Iterator
+	pending   []pendingWrite
+	isReverse bool
+
+	currentKey   []byte
+	currentValue []byte
+}
+
+var _ Iterator = (*txnIterator)(nil)
+
+func newTxnIterator(source Iterator, pending []pendingWrite, isReverse bool) *txnIterator {
+	itr := &txnIterator{
+		source:    source,
+		pending:   pending,
+		isReverse: isReverse,
+	}
+	itr.advance()
+	return itr
+}
+
+// before reports whether a comes before b in iteration order.
+func (itr *txnIterator) before(a, b []byte) bool {
+	c := bytes.Compare(a, b)
+	if itr.isReverse {
+		return c > 0
+	}
+	return c < 0
+}
+
+// advance moves to the next live entry, skipping pending deletes and the source entries they or
+// pending sets shadow.
+func (itr *txnIterator) advance() {
+	for {
+		sourceValid := itr.source.Valid()
+		if len(itr.pending) == 0 {
+			if !sourceValid {
+				itr.currentKey, itr.currentValue = nil, nil
+				return
+			}
+			itr.currentKey, itr.currentValue = itr.source.Key(), itr.source.Value()
+			itr.source.Next()
+			return
+		}
+
+		p := itr.pending[0]
+		if sourceValid {
+			key := itr.source.Key()
+			if itr.before(key, p.key) {
+				itr.currentKey, itr.currentValue = key, itr.source.Value()
+				itr.source.Next()
+				return
+			}
+			if bytes.Equal(key, p.key) {
+				itr.source.Next()
+			}
+		}
+		itr.pending = itr.pending[1:]
+		if !p.deleted {
+			itr.currentKey, itr.currentValue = p.key, p.value
+			return
+		}
+	}
+}
+
+// Domain implements Iterator.
+func (itr *txnIterator) Domain() ([]byte, []byte) {
+	return itr.source.Domain()
+}
+
+// Valid implements Iterator.
+func (itr *txnIterator) Valid() bool {
+	return itr.currentKey != nil && itr.source.Error() == nil
+}
+
+// Next implements Iterator.
+func (itr *txnIterator) Next() {
+	itr.assertIsValid()
+	itr.advance()
+}
+
+// Key implements Iterator.
+func (itr *txnIterator) Key() []byte {
+	itr.assertIsValid()
+	return itr.currentKey
+}
+
+// Value implements Iterator.
+func (itr *txnIterator) Value() []byte {
+	itr.assertIsValid()
+	return itr.currentValue
+}
+
+// Error implements Iterator.
+func (itr *txnIterator) Error() error {
+	return itr.source.Error()
+}
+
+// Close implements Iterator.
+func (itr *txnIterator) Close() error {
+	itr.currentKey, itr.currentValue = nil, nil
+	itr.pending = nil
+	return itr.source.Close()
+}
+
+func (itr *txnIterator) assertIsValid() {
+	if !itr.Valid() {
+		panic("iterator is invalid")
+	}
+}
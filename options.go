@@ -0,0 +1,76 @@
+package locketdb
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Options are the settings shared by every backend, see NewDBWithOptions. The zero value opens
+// the database with the backend defaults, creating it if it does not exist.
+type Options struct {
+	// ReadOnly opens the database without write access. Every write method, including those of
+	// the batches and transactions of the database, returns ErrReadOnly. Several read-only handles
+	// can share a database, but whether one can be opened while another process writes to it
+	// depends on the file locking of the backend.
+	ReadOnly bool
+
+	// CacheSize is the capacity of the block cache in bytes. Zero keeps the backend default.
+	CacheSize int64
+
+	// Sync makes every write durable, as if the Sync variants of DB and Batch were used.
+	Sync bool
+
+	// ErrorIfMissing makes opening fail when the database does not exist, instead of creating it.
+	ErrorIfMissing bool
+
+	// FileMode is the permission of the files created for the database. Zero keeps the backend
+	// default.
+	FileMode os.FileMode
+
+	// MergeOperator enables Merge on the database and its batches. A database written with a
+	// MergeOperator must always be opened with an operator of the same name: pebble refuses to
+	// open it otherwise, and goleveldb and bbolt store it in the layout of a MergeDB, which they
+	// refuse to open without one. A database holding keys written without a MergeOperator cannot
+	// be opened with one on goleveldb and bbolt either, see OpenMergeDB.
+	MergeOperator MergeOperator
+}
+
+// OptionsEngine opens a database with the common Options. It must return an error wrapping
+// ErrNotSupported, see OptionNotSupported, when it cannot honour one of them rather than ignore
+// it.
+type OptionsEngine func(name string, dir string, opts Options) (DB, error)
+
+var optionsEngines = map[KVType]OptionsEngine{}
+
+// RegisterOptionsEngine makes a backend available to NewDBWithOptions.
+func RegisterOptionsEngine(backend KVType, engine OptionsEngine) {
+	optionsEngines[backend] = engine
+}
+
+// NewDBWithOptions creates a new database of type backend with the given name, opened with opts.
+func NewDBWithOptions(name string, kvType KVType, dir string, opts Options) (DB, error) {
+	creator, ok := optionsEngines[kvType]
+	if ok {
+		db, err := creator(name, dir, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize database: %w", err)
+		}
+		return db, nil
+	}
+
+	keys := make([]string, 0, len(optionsEngines))
+	for k := range optionsEngines {
+		keys = append(keys, string(k))
+	}
+	sort.Strings(keys)
+	return nil, fmt.Errorf("unknown kvType %s, expected one of %v",
+		kvType, strings.Join(keys, ","))
+}
+
+// OptionNotSupported returns the error an OptionsEngine reports for an option its backend cannot
+// honour.
+func OptionNotSupported(backend KVType, option string) error {
+	return fmt.Errorf("%s: option %s: %w", backend, option, ErrNotSupported)
+}
@@ -0,0 +1,173 @@
+package locketdb_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/meission/locketdb"
+	_ "github.com/meission/locketdb/badgerdb"
+	_ "github.com/meission/locketdb/boltdb"
+	_ "github.com/meission/locketdb/goleveldb"
+	_ "github.com/meission/locketdb/memdb"
+	_ "github.com/meission/locketdb/pebble"
+)
+
+func TestNewDBWithOptions(t *testing.T) {
+	// An option each backend cannot honour.
+	unsupported := map[locketdb.KVType]locketdb.Options{
+		locketdb.GoLevelDB: {FileMode: 0600},
+		locketdb.Pebble:    {FileMode: 0600},
+		locketdb.BoltDB:    {CacheSize: 1 << 20},
+		locketdb.BadgerDB:  {FileMode: 0600},
+		locketdb.MemDB:     {ErrorIfMissing: true},
+	}
+	for kvType, opts := range unsupported {
+		kvType, opts := kvType, opts
+		t.Run(string(kvType), func(t *testing.T) {
+			dir := t.TempDir()
+			_, err := locketdb.NewDBWithOptions("test", kvType, dir, opts)
+			if !errors.Is(err, locketdb.ErrNotSupported) {
+				t.Fatalf("expected ErrNotSupported for %+v, got %v", opts, err)
+			}
+			if kvType == locketdb.MemDB {
+				return
+			}
+
+			_, err = locketdb.NewDBWithOptions("test", kvType, dir, locketdb.Options{ErrorIfMissing: true})
+			if err == nil {
+				t.Fatal("expected ErrorIfMissing to fail on a missing database")
+			}
+
+			db, err := locketdb.NewDBWithOptions("test", kvType, dir, locketdb.Options{Sync: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := db.Set([]byte("k"), []byte("v")); err != nil {
+				t.Fatal(err)
+			}
+			if err := db.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			db, err = locketdb.NewDBWithOptions("test", kvType, dir, locketdb.Options{ErrorIfMissing: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			value, err := db.Get([]byte("k"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(value) != "v" {
+				t.Fatalf("expected %q, got %q", "v", value)
+			}
+		})
+	}
+}
+
+func TestNewDBWithOptionsFileMode(t *testing.T) {
+	dir := t.TempDir()
+	db, err := locketdb.NewDBWithOptions("test", locketdb.BoltDB, dir, locketdb.Options{FileMode: 0600})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	info, err := os.Stat(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0600 {
+		t.Fatalf("expected mode 0600, got %v", mode)
+	}
+}
+
+func TestNewDBWithOptionsUnknownType(t *testing.T) {
+	if _, err := locketdb.NewDBWithOptions("test", "nosuchdb", "", locketdb.Options{}); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestNewDBWithOptionsReadOnly(t *testing.T) {
+	for _, kvType := range []locketdb.KVType{
+		locketdb.GoLevelDB, locketdb.Pebble, locketdb.BoltDB, locketdb.BadgerDB,
+	} {
+		kvType := kvType
+		t.Run(string(kvType), func(t *testing.T) {
+			dir := t.TempDir()
+			_, err := locketdb.NewDBWithOptions("test", kvType, dir, locketdb.Options{ReadOnly: true})
+			if err == nil {
+				t.Fatal("expected opening a missing database read-only to fail")
+			}
+
+			db, err := locketdb.NewDBWithOptions("test", kvType, dir, locketdb.Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := db.Set([]byte("k"), []byte("v")); err != nil {
+				t.Fatal(err)
+			}
+			if err := db.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			db, err = locketdb.NewDBWithOptions("test", kvType, dir, locketdb.Options{ReadOnly: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			value, err := db.Get([]byte("k"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(value) != "v" {
+				t.Fatalf("expected %q, got %q", "v", value)
+			}
+			itr, err := db.Iterator(nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !itr.Valid() || string(itr.Key()) != "k" {
+				t.Fatal("expected the iterator to return k")
+			}
+			itr.Close()
+
+			batch := db.NewBatch()
+			defer batch.Close()
+			txn, txnErr := locketdb.Begin(db, true)
+			if txnErr == nil {
+				txn.Rollback()
+			}
+			for name, err := range map[string]error{
+				"Set":         db.Set([]byte("k"), []byte("w")),
+				"SetSync":     db.SetSync([]byte("k"), []byte("w")),
+				"Delete":      db.Delete([]byte("k")),
+				"DeleteSync":  db.DeleteSync([]byte("k")),
+				"DeleteRange": locketdb.DeleteRange(db, nil, nil),
+				"Compact":     locketdb.Compact(db, nil, nil),
+				"Begin":       txnErr,
+				"Batch.Set":   batch.Set([]byte("k"), []byte("w")),
+				"Batch.Write": batch.Write(),
+			} {
+				if !errors.Is(err, locketdb.ErrReadOnly) {
+					t.Errorf("%s: expected ErrReadOnly, got %v", name, err)
+				}
+			}
+
+			txn, err = locketdb.Begin(db, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer txn.Rollback()
+			value, err = txn.Get([]byte("k"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(value) != "v" {
+				t.Fatalf("expected %q, got %q", "v", value)
+			}
+		})
+	}
+}
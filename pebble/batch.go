@@ -10,12 +10,16 @@ type pebbleDBBatch struct {
 	batch *pebble.Batch
 }
 
-var _ locketdb.Batch = (*pebbleDBBatch)(nil)
+var (
+	_ locketdb.Batch        = (*pebbleDBBatch)(nil)
+	_ locketdb.RangeDeleter = (*pebbleDBBatch)(nil)
+	_ locketdb.Merger       = (*pebbleDBBatch)(nil)
+)
 
 func newPebbleDBBatch(db *pebbleDB) *pebbleDBBatch {
 	return &pebbleDBBatch{
 		db:    db,
-		batch: new(pebble.Batch),
+		batch: db.db.NewBatch(),
 	}
 }
 
@@ -46,6 +50,40 @@ func (b *pebbleDBBatch) Delete(key []byte) error {
 	return nil
 }
 
+// Merge implements Merger. It returns ErrNotSupported if the database was opened without a
+// merger.
+func (b *pebbleDBBatch) Merge(key, operand []byte) error {
+	if len(key) == 0 {
+		return locketdb.ErrKeyEmpty
+	}
+	if operand == nil {
+		return locketdb.ErrValueNil
+	}
+	if !b.db.merge {
+		return locketdb.ErrNotSupported
+	}
+	if b.batch == nil {
+		return locketdb.ErrBatchClosed
+	}
+	return b.batch.Merge(key, operand, nil)
+}
+
+// DeleteRange implements RangeDeleter using a pebble range tombstone. With a nil end, the range
+// extends to the last key in the database or in the batch when DeleteRange is called.
+func (b *pebbleDBBatch) DeleteRange(start, end []byte) error {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return locketdb.ErrKeyEmpty
+	}
+	if b.batch == nil {
+		return locketdb.ErrBatchClosed
+	}
+	start, end, ok := b.db.tombstoneBounds(start, end, b.batch)
+	if !ok {
+		return nil
+	}
+	return b.batch.DeleteRange(start, end, nil)
+}
+
 // Write implements Batch.
 func (b *pebbleDBBatch) Write() error {
 	return b.write(false)
@@ -60,7 +98,7 @@ func (b *pebbleDBBatch) write(sync bool) error {
 	if b.batch == nil {
 		return locketdb.ErrBatchClosed
 	}
-	err := b.db.db.Flush()
+	err := b.batch.Commit(b.db.writeOptions(sync))
 	if err != nil {
 		return err
 	}
@@ -71,8 +109,9 @@ func (b *pebbleDBBatch) write(sync bool) error {
 // Close implements Batch.
 func (b *pebbleDBBatch) Close() error {
 	if b.batch != nil {
-		b.batch.Reset()
+		err := b.batch.Close()
 		b.batch = nil
+		return err
 	}
 	return nil
 }
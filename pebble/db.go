@@ -1,8 +1,11 @@
 package pebble
 
 import (
+	"bytes"
 	"fmt"
 	"path/filepath"
+	"strconv"
+	"sync"
 
 	"github.com/cockroachdb/pebble"
 	"github.com/meission/locketdb"
@@ -10,12 +13,35 @@ import (
 
 type pebbleDB struct {
 	db *pebble.DB
+	// sync makes every write durable, as if the Sync variants were used.
+	sync bool
+	// readOnly makes every write fail with ErrReadOnly.
+	readOnly bool
+	// merge enables Merge, which pebble would otherwise apply with its concatenating default.
+	merge bool
+
+	// txnMtx serializes the commits of optimistic transactions.
+	txnMtx sync.Mutex
+	// keyLocks serializes the conditional writes of each key.
+	keyLocks locketdb.KeyLocks
 }
 
-var _ locketdb.DB = (*pebbleDB)(nil)
+var (
+	_ locketdb.DB                = (*pebbleDB)(nil)
+	_ locketdb.Snapshotter       = (*pebbleDB)(nil)
+	_ locketdb.Transactional     = (*pebbleDB)(nil)
+	_ locketdb.RangeDeleter      = (*pebbleDB)(nil)
+	_ locketdb.Compacter         = (*pebbleDB)(nil)
+	_ locketdb.KVTyper           = (*pebbleDB)(nil)
+	_ locketdb.MetricsReporter   = (*pebbleDB)(nil)
+	_ locketdb.ConditionalWriter = (*pebbleDB)(nil)
+	_ locketdb.Merger            = (*pebbleDB)(nil)
+)
 
 func init() {
 	locketdb.RegisterEngine(locketdb.Pebble, NewDB)
+	locketdb.RegisterDSNEngine(locketdb.Pebble, OpenDSN)
+	locketdb.RegisterOptionsEngine(locketdb.Pebble, OpenWithOptions)
 }
 
 func NewDB(name string, dir string) (locketdb.DB, error) {
@@ -29,20 +55,54 @@ func NewDBWithOpts(name string, dir string, o *pebble.Options) (*pebbleDB, error
 		return nil, err
 	}
 	return &pebbleDB{
-		db: db,
+		db:       db,
+		readOnly: o != nil && o.ReadOnly,
+		merge:    o != nil && o.Merger != nil,
 	}, nil
 }
 
+// OpenWithOptions opens a database with the common options, see locketdb.NewDBWithOptions.
+// FileMode is not supported, pebble always creates its files with mode 0666 less the umask.
+func OpenWithOptions(name string, dir string, opts locketdb.Options) (locketdb.DB, error) {
+	if opts.FileMode != 0 {
+		return nil, locketdb.OptionNotSupported(locketdb.Pebble, "FileMode")
+	}
+	o := &pebble.Options{
+		ReadOnly:         opts.ReadOnly,
+		ErrorIfNotExists: opts.ErrorIfMissing,
+	}
+	if opts.MergeOperator != nil {
+		o.Merger = newMerger(opts.MergeOperator)
+	}
+	if opts.CacheSize > 0 {
+		cache := pebble.NewCache(opts.CacheSize)
+		// pebble.Open takes its own reference on the cache.
+		defer cache.Unref()
+		o.Cache = cache
+	}
+	db, err := NewDBWithOpts(name, dir, o)
+	if err != nil {
+		return nil, err
+	}
+	db.sync = opts.Sync
+	return db, nil
+}
+
 // Get implements DB.
 func (db *pebbleDB) Get(key []byte) ([]byte, error) {
 	if len(key) == 0 {
 		return nil, locketdb.ErrKeyEmpty
 	}
-	res, _, err := db.db.Get(key)
+	res, closer, err := db.db.Get(key)
 	if err == pebble.ErrNotFound {
 		return nil, nil
 	}
-	return res, err
+	if err != nil {
+		return nil, err
+	}
+	// res is only valid until closer is closed.
+	value := cp(res)
+	return value, closer.Close()
 }
 
 // Has implements DB.
@@ -62,7 +122,10 @@ func (db *pebbleDB) Set(key []byte, value []byte) error {
 	if value == nil {
 		return locketdb.ErrValueNil
 	}
-	return db.db.Set(key, value, nil)
+	if db.readOnly {
+		return locketdb.ErrReadOnly
+	}
+	return db.db.Set(key, value, db.writeOptions(false))
 }
 
 // SetSync implements DB.
@@ -73,7 +136,10 @@ func (db *pebbleDB) SetSync(key []byte, value []byte) error {
 	if value == nil {
 		return locketdb.ErrValueNil
 	}
-	return db.db.Set(key, value, pebble.Sync)
+	if db.readOnly {
+		return locketdb.ErrReadOnly
+	}
+	return db.db.Set(key, value, db.writeOptions(true))
 }
 
 // Delete implements DB.
@@ -81,7 +147,10 @@ func (db *pebbleDB) Delete(key []byte) error {
 	if len(key) == 0 {
 		return locketdb.ErrKeyEmpty
 	}
-	return db.db.Delete(key, nil)
+	if db.readOnly {
+		return locketdb.ErrReadOnly
+	}
+	return db.db.Delete(key, db.writeOptions(false))
 }
 
 // DeleteSync implements DB.
@@ -89,7 +158,125 @@ func (db *pebbleDB) DeleteSync(key []byte) error {
 	if len(key) == 0 {
 		return locketdb.ErrKeyEmpty
 	}
-	return db.db.Delete(key, pebble.Sync)
+	if db.readOnly {
+		return locketdb.ErrReadOnly
+	}
+	return db.db.Delete(key, db.writeOptions(true))
+}
+
+// CompareAndSwap implements ConditionalWriter. The conditional writes of a key are serialized by
+// a striped lock, plain writes racing with them are not.
+func (db *pebbleDB) CompareAndSwap(key, expected, new []byte) (bool, error) {
+	if len(key) == 0 {
+		return false, locketdb.ErrKeyEmpty
+	}
+	if db.readOnly {
+		return false, locketdb.ErrReadOnly
+	}
+	return locketdb.CompareAndSwapLocked(db, &db.keyLocks, key, expected, new)
+}
+
+// SetIfAbsent implements ConditionalWriter.
+func (db *pebbleDB) SetIfAbsent(key, value []byte) (bool, error) {
+	if len(key) == 0 {
+		return false, locketdb.ErrKeyEmpty
+	}
+	if value == nil {
+		return false, locketdb.ErrValueNil
+	}
+	return db.CompareAndSwap(key, nil, value)
+}
+
+// Merge implements Merger using a pebble merge, folded with the other operands of the key on
+// reads and compactions. It returns ErrNotSupported if the database was opened without a merger.
+func (db *pebbleDB) Merge(key, operand []byte) error {
+	if len(key) == 0 {
+		return locketdb.ErrKeyEmpty
+	}
+	if operand == nil {
+		return locketdb.ErrValueNil
+	}
+	if !db.merge {
+		return locketdb.ErrNotSupported
+	}
+	if db.readOnly {
+		return locketdb.ErrReadOnly
+	}
+	return db.db.Merge(key, operand, db.writeOptions(false))
+}
+
+// DeleteRange implements RangeDeleter using a pebble range tombstone.
+func (db *pebbleDB) DeleteRange(start, end []byte) error {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return locketdb.ErrKeyEmpty
+	}
+	if db.readOnly {
+		return locketdb.ErrReadOnly
+	}
+	start, end, ok := db.tombstoneBounds(start, end, nil)
+	if !ok {
+		return nil
+	}
+	return db.db.DeleteRange(start, end, db.writeOptions(false))
+}
+
+// Compact implements Compacter.
+func (db *pebbleDB) Compact(start, end []byte) error {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return locketdb.ErrKeyEmpty
+	}
+	if db.readOnly {
+		return locketdb.ErrReadOnly
+	}
+	start, end, ok := db.tombstoneBounds(start, end, nil)
+	if !ok {
+		return nil
+	}
+	return db.db.Compact(start, end)
+}
+
+// tombstoneBounds turns a domain into the bounds of a range tombstone, which cannot be open
+// ended. A nil end is replaced with the successor of the last key currently in the database, or
+// in batch if it is not nil. ok is false if the domain cannot contain any key.
+func (db *pebbleDB) tombstoneBounds(start, end []byte, batch *pebble.Batch) ([]byte, []byte, bool) {
+	if start == nil {
+		start = []byte{}
+	}
+	if end == nil {
+		var last []byte
+		iter := db.db.NewIter(nil)
+		if iter.Last() {
+			last = cp(iter.Key())
+		}
+		iter.Close()
+		if batch != nil {
+			r := batch.Reader()
+			for _, ukey, _, ok := r.Next(); ok; _, ukey, _, ok = r.Next() {
+				if bytes.Compare(ukey, last) > 0 {
+					last = cp(ukey)
+				}
+			}
+		}
+		if last == nil {
+			return nil, nil, false
+		}
+		end = append(last, 0)
+	}
+	return start, end, bytes.Compare(start, end) < 0
+}
+
+// writeOptions returns the options of a write, which is synced if sync is set or if the database
+// was opened with sync.
+func (db *pebbleDB) writeOptions(sync bool) *pebble.WriteOptions {
+	if sync || db.sync {
+		return pebble.Sync
+	}
+	return pebble.NoSync
+}
+
+// KVType implements KVTyper.
+func (db *pebbleDB) KVType() locketdb.KVType {
+	return locketdb.Pebble
 }
 
 // Close implements DB.
@@ -102,19 +289,96 @@ func (db *pebbleDB) Print() error {
 	fmt.Printf("%v\n", db.db.Metrics().String())
 
 	iter := db.db.NewIter(nil)
+	defer iter.Close()
 	for iter.First(); iter.Valid(); iter.Next() {
 		fmt.Printf("%s:%s\n", iter.Key(), iter.Value())
 	}
 	return nil
 }
 
-// Stats implements DB.
+// Stats implements DB, reporting the metrics of pebble.
 func (db *pebbleDB) Stats() map[string]string {
-	return nil
+	m := db.db.Metrics()
+	stats := make(map[string]string)
+	set := func(value interface{}, names ...string) {
+		stats[locketdb.StatsKey(locketdb.Pebble, names...)] = fmt.Sprint(value)
+	}
+
+	set(m.DiskSpaceUsage(), "disk", "size_bytes")
+	for level, lm := range m.Levels {
+		n := strconv.Itoa(level)
+		set(lm.NumFiles, "level", n, "tables")
+		set(lm.Size, "level", n, "size_bytes")
+		set(lm.Sublevels, "level", n, "sublevels")
+		set(strconv.FormatFloat(lm.Score, 'f', 2, 64), "level", n, "score")
+		set(lm.BytesIn, "level", n, "in_bytes")
+		set(lm.BytesRead, "level", n, "read_bytes")
+		set(lm.BytesCompacted, "level", n, "compacted_bytes")
+		set(lm.BytesFlushed, "level", n, "flushed_bytes")
+	}
+	set(m.ReadAmp(), "read_amp")
+
+	set(m.Compact.Count, "compaction", "count")
+	set(m.Compact.EstimatedDebt, "compaction", "debt_bytes")
+	set(m.Compact.InProgressBytes, "compaction", "in_progress_bytes")
+	set(m.Flush.Count, "flush", "count")
+
+	set(m.MemTable.Count, "memtable", "count")
+	set(m.MemTable.Size, "memtable", "size_bytes")
+	set(m.MemTable.ZombieCount, "memtable", "zombie_count")
+	set(m.MemTable.ZombieSize, "memtable", "zombie_size_bytes")
+
+	set(m.BlockCache.Count, "block_cache", "blocks")
+	set(m.BlockCache.Size, "block_cache", "size_bytes")
+	set(m.BlockCache.Hits, "block_cache", "hits")
+	set(m.BlockCache.Misses, "block_cache", "misses")
+	set(m.TableCache.Count, "table_cache", "tables")
+	set(m.TableCache.Size, "table_cache", "size_bytes")
+	set(m.TableCache.Hits, "table_cache", "hits")
+	set(m.TableCache.Misses, "table_cache", "misses")
+
+	set(m.Table.ObsoleteCount, "table", "obsolete_count")
+	set(m.Table.ObsoleteSize, "table", "obsolete_size_bytes")
+	set(m.Table.ZombieCount, "table", "zombie_count")
+	set(m.Table.ZombieSize, "table", "zombie_size_bytes")
+	set(m.TableIters, "table", "iterators")
+
+	set(m.WAL.Files, "wal", "files")
+	set(m.WAL.Size, "wal", "size_bytes")
+	set(m.WAL.PhysicalSize, "wal", "physical_size_bytes")
+	set(m.WAL.ObsoleteFiles, "wal", "obsolete_files")
+	set(m.WAL.BytesIn, "wal", "in_bytes")
+	set(m.WAL.BytesWritten, "wal", "written_bytes")
+	return stats
+}
+
+// Metrics implements MetricsReporter. Pebble does not count the keys, nor the iterators and
+// transactions of its users.
+func (db *pebbleDB) Metrics() locketdb.Metrics {
+	m := db.db.Metrics()
+	return locketdb.Metrics{
+		DiskBytes:              locketdb.KnownMetric(m.DiskSpaceUsage()),
+		MemtableBytes:          locketdb.KnownMetric(m.MemTable.Size),
+		CacheHits:              locketdb.KnownMetric(uint64(m.BlockCache.Hits)),
+		CacheMisses:            locketdb.KnownMetric(uint64(m.BlockCache.Misses)),
+		PendingCompactionBytes: locketdb.KnownMetric(m.Compact.EstimatedDebt),
+	}
+}
+
+// Begin implements Transactional. Transactions are optimistic: they read from a snapshot and fail
+// to commit with ErrConflict if anything they read was modified in the meantime.
+func (db *pebbleDB) Begin(writable bool) (locketdb.Txn, error) {
+	if writable && db.readOnly {
+		return nil, locketdb.ErrReadOnly
+	}
+	return locketdb.NewOptimisticTxn(db, &db.txnMtx, writable)
 }
 
 // NewBatch implements DB.
 func (db *pebbleDB) NewBatch() locketdb.Batch {
+	if db.readOnly {
+		return locketdb.NewReadOnlyBatch()
+	}
 	return newPebbleDBBatch(db)
 }
 
@@ -123,7 +387,7 @@ func (db *pebbleDB) Iterator(start, end []byte) (locketdb.Iterator, error) {
 	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
 		return nil, locketdb.ErrKeyEmpty
 	}
-	itr := db.db.NewIter(nil)
+	itr := db.db.NewIter(iterOptions(start, end))
 	return newpebbleDBIterator(itr, start, end, false), nil
 }
 
@@ -132,6 +396,6 @@ func (db *pebbleDB) ReverseIterator(start, end []byte) (locketdb.Iterator, error
 	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
 		return nil, locketdb.ErrKeyEmpty
 	}
-	itr := db.db.NewIter(nil)
+	itr := db.db.NewIter(iterOptions(start, end))
 	return newpebbleDBIterator(itr, start, end, true), nil
 }
@@ -0,0 +1,239 @@
+package pebble
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/meission/locketdb"
+	"github.com/meission/locketdb/locketdbtest"
+)
+
+func TestConformance(t *testing.T) {
+	dir := t.TempDir()
+	n := 0
+	locketdbtest.RunConformance(t, func() locketdb.DB {
+		n++
+		db, err := NewDB(fmt.Sprintf("test%d", n), dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return db
+	})
+}
+
+func TestMergeConformance(t *testing.T) {
+	dir := t.TempDir()
+	n := 0
+	locketdbtest.RunMergeConformance(t, func(op locketdb.MergeOperator) locketdb.DB {
+		n++
+		db, err := OpenWithOptions(fmt.Sprintf("test%d", n), dir, locketdb.Options{MergeOperator: op})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return db
+	})
+}
+
+// TestGetCopiesValue checks that Get returns values that outlive the cache blocks pebble reads
+// them from, which are released when Get returns.
+func TestGetCopiesValue(t *testing.T) {
+	db, err := NewDBWithOpts("test", t.TempDir(), &pebble.Options{Cache: pebble.NewCache(64 << 10)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	value := func(i int) []byte {
+		return bytes.Repeat([]byte{byte(i)}, 4096)
+	}
+	const n = 200
+	for i := 0; i < n; i++ {
+		if err := db.Set([]byte(fmt.Sprintf("key%03d", i)), value(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.db.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	var values [][]byte
+	for i := 0; i < n; i++ {
+		v, err := db.Get([]byte(fmt.Sprintf("key%03d", i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		values = append(values, v)
+	}
+	for i, v := range values {
+		if !bytes.Equal(v, value(i)) {
+			t.Fatalf("key%03d: value changed after Get returned", i)
+		}
+	}
+}
+
+func TestBatchAtomic(t *testing.T) {
+	db, err := NewDB("test", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	keys := [][]byte{[]byte("a"), []byte("b"), []byte("c"), []byte("d")}
+	done := make(chan struct{})
+	errc := make(chan error, 1)
+	go func() {
+		defer close(errc)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			itr, err := db.Iterator(nil, nil)
+			if err != nil {
+				errc <- err
+				return
+			}
+			var first []byte
+			n := 0
+			for ; itr.Valid(); itr.Next() {
+				if n == 0 {
+					first = itr.Value()
+				} else if !bytes.Equal(itr.Value(), first) {
+					errc <- fmt.Errorf("saw a partially applied batch: %q at %q, want %q", itr.Value(), itr.Key(), first)
+					itr.Close()
+					return
+				}
+				n++
+			}
+			itr.Close()
+			if n != 0 && n != len(keys) {
+				errc <- fmt.Errorf("saw %d of %d keys written by a batch", n, len(keys))
+				return
+			}
+		}
+	}()
+
+	for i := 0; i < 200; i++ {
+		batch := db.NewBatch()
+		for _, key := range keys {
+			if err := batch.Set(key, []byte(fmt.Sprint(i))); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := batch.Write(); err != nil {
+			t.Fatal(err)
+		}
+		batch.Close()
+	}
+	close(done)
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBatchDurable(t *testing.T) {
+	dir := t.TempDir()
+	for _, sync := range []bool{false, true} {
+		name := fmt.Sprintf("sync-%v", sync)
+		db, err := NewDB(name, dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		batch := db.NewBatch()
+		if err := batch.Set([]byte("key"), []byte("value")); err != nil {
+			t.Fatal(err)
+		}
+		if sync {
+			err = batch.WriteSync()
+		} else {
+			err = batch.Write()
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		batch.Close()
+		if err := db.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		db, err = NewDB(name, dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		value, err := db.Get([]byte("key"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(value) != "value" {
+			t.Errorf("%s: Get after reopen = %q, want %q", name, value, "value")
+		}
+		db.Close()
+	}
+}
+
+func TestStats(t *testing.T) {
+	db, err := NewDB("test", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for i := 0; i < 100; i++ {
+		if err := db.Set([]byte(fmt.Sprintf("key%03d", i)), []byte("value")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Compacting flushes the memtable into tables.
+	if err := locketdb.Compact(db, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Get([]byte("key042")); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := db.Stats()
+	for _, key := range []string{"pebble.block_cache.hits", "pebble.block_cache.misses", "pebble.wal.size_bytes",
+		"pebble.level.6.size_bytes", "pebble.compaction.count"} {
+		if _, ok := stats[key]; !ok {
+			t.Errorf("Stats() is missing %q", key)
+		}
+	}
+	tables := 0
+	for level := 0; level < 7; level++ {
+		n, err := strconv.Atoi(stats[fmt.Sprintf("pebble.level.%d.tables", level)])
+		if err != nil {
+			t.Fatal(err)
+		}
+		tables += n
+	}
+	if tables == 0 {
+		t.Error("expected the compacted keys to be in tables")
+	}
+	if stats["pebble.disk.size_bytes"] == "0" {
+		t.Error("expected a non-zero disk size")
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	db, err := NewDB("test", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.Set([]byte("key"), []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+
+	m := locketdb.MetricsOf(db)
+	if !m.DiskBytes.Known || !m.MemtableBytes.Known || m.MemtableBytes.Value == 0 {
+		t.Errorf("DiskBytes = %v, MemtableBytes = %v, want known and non-zero memtable", m.DiskBytes, m.MemtableBytes)
+	}
+	if !m.CacheHits.Known || !m.CacheMisses.Known || !m.PendingCompactionBytes.Known {
+		t.Errorf("expected the cache and compaction metrics to be known: %+v", m)
+	}
+	if m.LiveKeys.Known || m.OpenIterators.Known || m.OpenTxns.Known {
+		t.Errorf("expected the metrics pebble does not report to be unknown: %+v", m)
+	}
+}
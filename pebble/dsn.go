@@ -0,0 +1,47 @@
+package pebble
+
+import (
+	"fmt"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/meission/locketdb"
+)
+
+// OpenDSN opens a database from a DSN, see locketdb.Open. The supported options are:
+//
+//	cache_size      block cache capacity (size)
+//	memtable_size   memtable size (size)
+//	max_open_files  maximum number of open files (integer)
+//	sync            sync every write to disk (boolean)
+func OpenDSN(name string, dir string, params *locketdb.DSNParams) (locketdb.DB, error) {
+	if name == "" {
+		return nil, fmt.Errorf("DSN must include the database path")
+	}
+	var (
+		o                       pebble.Options
+		cacheSize, memTableSize int64
+		sync                    bool
+	)
+	params.Size("cache_size", &cacheSize)
+	params.Size("memtable_size", &memTableSize)
+	params.Int("max_open_files", &o.MaxOpenFiles)
+	params.Bool("sync", &sync)
+	if err := params.Err(); err != nil {
+		return nil, err
+	}
+
+	o.MemTableSize = int(memTableSize)
+	if cacheSize > 0 {
+		cache := pebble.NewCache(cacheSize)
+		// pebble.Open takes its own reference on the cache.
+		defer cache.Unref()
+		o.Cache = cache
+	}
+
+	db, err := NewDBWithOpts(name, dir, &o)
+	if err != nil {
+		return nil, err
+	}
+	db.sync = sync
+	return db, nil
+}
@@ -1,12 +1,12 @@
 package pebble
 
 import (
-	"bytes"
-
 	"github.com/cockroachdb/pebble"
 	"github.com/meission/locketdb"
 )
 
+// pebbleDBIterator walks a pebble iterator created with the bounds of its domain, see
+// iterOptions. Pebble clamps every move and seek to those bounds.
 type pebbleDBIterator struct {
 	iter      *pebble.Iterator
 	start     []byte
@@ -15,37 +15,26 @@ type pebbleDBIterator struct {
 	isInvalid bool
 }
 
-var _ locketdb.Iterator = (*pebbleDBIterator)(nil)
+var _ locketdb.SeekIterator = (*pebbleDBIterator)(nil)
 
-func newpebbleDBIterator(iter *pebble.Iterator, start, end []byte, isReverse bool) *pebbleDBIterator {
-	if isReverse {
-		if end == nil {
-			iter.Last()
-		} else {
-			valid := iter.SeekGE(end)
-			if valid {
-				eoakey := iter.Key() // end or after key
-				if bytes.Compare(end, eoakey) <= 0 {
-					iter.Prev()
-				}
-			} else {
-				iter.Last()
-			}
-		}
-	} else {
-		if start == nil {
-			iter.First()
-		} else {
-			iter.SeekGE(start)
-		}
+// iterOptions returns the options of a pebble iterator bounded to [start, end).
+func iterOptions(start, end []byte) *pebble.IterOptions {
+	return &pebble.IterOptions{
+		LowerBound: start,
+		UpperBound: end,
 	}
-	return &pebbleDBIterator{
+}
+
+func newpebbleDBIterator(iter *pebble.Iterator, start, end []byte, isReverse bool) *pebbleDBIterator {
+	itr := &pebbleDBIterator{
 		iter:      iter,
 		start:     start,
 		end:       end,
 		isReverse: isReverse,
 		isInvalid: false,
 	}
+	itr.First()
+	return itr
 }
 
 // Domain implements Iterator.
@@ -66,28 +55,13 @@ func (iter *pebbleDBIterator) Valid() bool {
 		iter.isInvalid = true
 		return false
 	}
-	// If iter is invalid, invalid.
+
+	// If iter is invalid, invalid. Pebble keeps it within the bounds of the domain.
 	if !iter.iter.Valid() {
 		iter.isInvalid = true
 		return false
 	}
 
-	key := iter.iter.Key()
-
-	if iter.isReverse {
-		start := iter.start
-		if start != nil && bytes.Compare(key, start) < 0 {
-			iter.isInvalid = true
-			return false
-		}
-	} else {
-		end := iter.end
-		if end != nil && bytes.Compare(end, key) < 0 {
-			iter.isInvalid = true
-			return false
-		}
-	}
-
 	// Valid
 	return true
 }
@@ -122,6 +96,47 @@ func (iter *pebbleDBIterator) Next() {
 	}
 }
 
+// Prev implements SeekIterator.
+func (iter *pebbleDBIterator) Prev() {
+	iter.assertIsValid()
+	if iter.isReverse {
+		iter.iter.Next()
+	} else {
+		iter.iter.Prev()
+	}
+}
+
+// Seek implements SeekIterator.
+func (iter *pebbleDBIterator) Seek(key []byte) {
+	iter.isInvalid = false
+	if iter.isReverse {
+		// The greatest key <= key is the greatest key < key+0x00.
+		iter.iter.SeekLT(append(cp(key), 0x00))
+	} else {
+		iter.iter.SeekGE(key)
+	}
+}
+
+// First implements SeekIterator.
+func (iter *pebbleDBIterator) First() {
+	iter.isInvalid = false
+	if iter.isReverse {
+		iter.iter.Last()
+	} else {
+		iter.iter.First()
+	}
+}
+
+// Last implements SeekIterator.
+func (iter *pebbleDBIterator) Last() {
+	iter.isInvalid = false
+	if iter.isReverse {
+		iter.iter.First()
+	} else {
+		iter.iter.Last()
+	}
+}
+
 // Error implements Iterator.
 func (iter *pebbleDBIterator) Error() error {
 	return iter.iter.Error()
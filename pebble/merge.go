@@ -0,0 +1,52 @@
+package pebble
+
+import (
+	"io"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/meission/locketdb"
+)
+
+// newMerger adapts op to a pebble Merger, named after the operator so that pebble refuses to
+// open the database with another one.
+func newMerger(op locketdb.MergeOperator) *pebble.Merger {
+	return &pebble.Merger{
+		Name: op.Name(),
+		Merge: func(key, value []byte) (pebble.ValueMerger, error) {
+			return &valueMerger{op: op, acc: append([]byte{}, value...)}, nil
+		},
+	}
+}
+
+// valueMerger folds the operands of a key as pebble hands them over, newest or oldest first.
+type valueMerger struct {
+	op  locketdb.MergeOperator
+	acc []byte
+}
+
+var _ pebble.ValueMerger = (*valueMerger)(nil)
+
+// MergeNewer implements pebble.ValueMerger.
+func (m *valueMerger) MergeNewer(value []byte) error {
+	acc, err := locketdb.MergeValue(m.op, m.acc, value)
+	if err != nil {
+		return err
+	}
+	m.acc = acc
+	return nil
+}
+
+// MergeOlder implements pebble.ValueMerger.
+func (m *valueMerger) MergeOlder(value []byte) error {
+	acc, err := m.op.Merge(value, m.acc)
+	if err != nil {
+		return err
+	}
+	m.acc = append([]byte{}, acc...)
+	return nil
+}
+
+// Finish implements pebble.ValueMerger.
+func (m *valueMerger) Finish(includesBase bool) ([]byte, io.Closer, error) {
+	return m.acc, nil, nil
+}
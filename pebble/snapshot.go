@@ -0,0 +1,66 @@
+package pebble
+
+import (
+	"github.com/cockroachdb/pebble"
+	"github.com/meission/locketdb"
+)
+
+type pebbleDBSnapshot struct {
+	snap *pebble.Snapshot
+}
+
+var _ locketdb.Snapshot = (*pebbleDBSnapshot)(nil)
+
+// NewSnapshot implements Snapshotter.
+func (db *pebbleDB) NewSnapshot() (locketdb.Snapshot, error) {
+	return &pebbleDBSnapshot{snap: db.db.NewSnapshot()}, nil
+}
+
+// Get implements Snapshot.
+func (s *pebbleDBSnapshot) Get(key []byte) ([]byte, error) {
+	if len(key) == 0 {
+		return nil, locketdb.ErrKeyEmpty
+	}
+	res, closer, err := s.snap.Get(key)
+	if err == pebble.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	// res is only valid until closer is closed.
+	value := cp(res)
+	return value, closer.Close()
+}
+
+// Has implements Snapshot.
+func (s *pebbleDBSnapshot) Has(key []byte) (bool, error) {
+	bytes, err := s.Get(key)
+	if err != nil {
+		return false, err
+	}
+	return bytes != nil, nil
+}
+
+// Iterator implements Snapshot.
+func (s *pebbleDBSnapshot) Iterator(start, end []byte) (locketdb.Iterator, error) {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return nil, locketdb.ErrKeyEmpty
+	}
+	itr := s.snap.NewIter(iterOptions(start, end))
+	return newpebbleDBIterator(itr, start, end, false), nil
+}
+
+// ReverseIterator implements Snapshot.
+func (s *pebbleDBSnapshot) ReverseIterator(start, end []byte) (locketdb.Iterator, error) {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return nil, locketdb.ErrKeyEmpty
+	}
+	itr := s.snap.NewIter(iterOptions(start, end))
+	return newpebbleDBIterator(itr, start, end, true), nil
+}
+
+// Close implements Snapshot.
+func (s *pebbleDBSnapshot) Close() error {
+	return s.snap.Close()
+}
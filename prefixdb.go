@@ -2,9 +2,11 @@ package locketdb
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"sync"
+	"time"
 )
 
 // PrefixDB wraps a namespace of another database as a logical database.
@@ -14,7 +16,18 @@ type PrefixDB struct {
 	db     DB
 }
 
-var _ DB = (*PrefixDB)(nil)
+var (
+	_ DB                = (*PrefixDB)(nil)
+	_ Snapshotter       = (*PrefixDB)(nil)
+	_ Transactional     = (*PrefixDB)(nil)
+	_ RangeDeleter      = (*PrefixDB)(nil)
+	_ Compacter         = (*PrefixDB)(nil)
+	_ KVTyper           = (*PrefixDB)(nil)
+	_ MetricsReporter   = (*PrefixDB)(nil)
+	_ TTLSetter         = (*PrefixDB)(nil)
+	_ ConditionalWriter = (*PrefixDB)(nil)
+	_ Merger            = (*PrefixDB)(nil)
+)
 
 // NewPrefixDB lets you namespace multiple DBs within a single DB.
 func NewPrefixDB(db DB, prefix []byte) *PrefixDB {
@@ -88,6 +101,64 @@ func (pdb *PrefixDB) SetSync(key []byte, value []byte) error {
 	return pdb.db.SetSync(pdb.prefixed(key), value)
 }
 
+// SetWithTTL implements TTLSetter. It returns ErrNotSupported if the underlying database is not a
+// TTLSetter.
+func (pdb *PrefixDB) SetWithTTL(key, value []byte, ttl time.Duration) error {
+	if len(key) == 0 {
+		return ErrKeyEmpty
+	}
+	pdb.mtx.Lock()
+	defer pdb.mtx.Unlock()
+
+	return SetWithTTL(pdb.db, pdb.prefixed(key), value, ttl)
+}
+
+// CompareAndSwap implements ConditionalWriter. It returns ErrNotSupported if the underlying
+// database is not a ConditionalWriter.
+func (pdb *PrefixDB) CompareAndSwap(key, expected, new []byte) (bool, error) {
+	if len(key) == 0 {
+		return false, ErrKeyEmpty
+	}
+	pdb.mtx.Lock()
+	defer pdb.mtx.Unlock()
+
+	ok, err := CompareAndSwap(pdb.db, pdb.prefixed(key), expected, new)
+	return ok, pdb.unprefixConflict(key, err)
+}
+
+// SetIfAbsent implements ConditionalWriter. It returns ErrNotSupported if the underlying database
+// is not a ConditionalWriter.
+func (pdb *PrefixDB) SetIfAbsent(key, value []byte) (bool, error) {
+	if len(key) == 0 {
+		return false, ErrKeyEmpty
+	}
+	pdb.mtx.Lock()
+	defer pdb.mtx.Unlock()
+
+	ok, err := SetIfAbsent(pdb.db, pdb.prefixed(key), value)
+	return ok, pdb.unprefixConflict(key, err)
+}
+
+// Merge implements Merger. It returns ErrNotSupported if the underlying database is not a Merger.
+func (pdb *PrefixDB) Merge(key, operand []byte) error {
+	if len(key) == 0 {
+		return ErrKeyEmpty
+	}
+	pdb.mtx.Lock()
+	defer pdb.mtx.Unlock()
+
+	return Merge(pdb.db, pdb.prefixed(key), operand)
+}
+
+// unprefixConflict reports the key of the namespace in a *ConflictError.
+func (pdb *PrefixDB) unprefixConflict(key []byte, err error) error {
+	var conflict *ConflictError
+	if errors.As(err, &conflict) {
+		conflict.Key = cp(key)
+	}
+	return err
+}
+
 // Delete implements DB.
 func (pdb *PrefixDB) Delete(key []byte) error {
 	if len(key) == 0 {
@@ -110,6 +181,38 @@ func (pdb *PrefixDB) DeleteSync(key []byte) error {
 	return pdb.db.DeleteSync(pdb.prefixed(key))
 }
 
+// DeleteRange implements RangeDeleter. It returns ErrNotSupported if the underlying database is
+// not a RangeDeleter.
+func (pdb *PrefixDB) DeleteRange(start, end []byte) error {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return ErrKeyEmpty
+	}
+	pdb.mtx.Lock()
+	defer pdb.mtx.Unlock()
+
+	pstart, pend := prefixRange(pdb.prefix, start, end)
+	return DeleteRange(pdb.db, pstart, pend)
+}
+
+// Drop deletes every key of the namespace. It returns ErrNotSupported if the underlying database
+// is not a RangeDeleter.
+func (pdb *PrefixDB) Drop() error {
+	return pdb.DeleteRange(nil, nil)
+}
+
+// Compact implements Compacter. It returns ErrNotSupported if the underlying database is not a
+// Compacter.
+func (pdb *PrefixDB) Compact(start, end []byte) error {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return ErrKeyEmpty
+	}
+	pdb.mtx.Lock()
+	defer pdb.mtx.Unlock()
+
+	pstart, pend := prefixRange(pdb.prefix, start, end)
+	return Compact(pdb.db, pstart, pend)
+}
+
 // Iterator implements DB.
 func (pdb *PrefixDB) Iterator(start, end []byte) (Iterator, error) {
 	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
@@ -118,13 +221,7 @@ func (pdb *PrefixDB) Iterator(start, end []byte) (Iterator, error) {
 	pdb.mtx.Lock()
 	defer pdb.mtx.Unlock()
 
-	var pstart, pend []byte
-	pstart = append(cp(pdb.prefix), start...)
-	if end == nil {
-		pend = cpIncr(pdb.prefix)
-	} else {
-		pend = append(cp(pdb.prefix), end...)
-	}
+	pstart, pend := prefixRange(pdb.prefix, start, end)
 	itr, err := pdb.db.Iterator(pstart, pend)
 	if err != nil {
 		return nil, err
@@ -141,13 +238,7 @@ func (pdb *PrefixDB) ReverseIterator(start, end []byte) (Iterator, error) {
 	pdb.mtx.Lock()
 	defer pdb.mtx.Unlock()
 
-	var pstart, pend []byte
-	pstart = append(cp(pdb.prefix), start...)
-	if end == nil {
-		pend = cpIncr(pdb.prefix)
-	} else {
-		pend = append(cp(pdb.prefix), end...)
-	}
+	pstart, pend := prefixRange(pdb.prefix, start, end)
 	ritr, err := pdb.db.ReverseIterator(pstart, pend)
 	if err != nil {
 		return nil, err
@@ -164,6 +255,42 @@ func (pdb *PrefixDB) NewBatch() Batch {
 	return newPrefixBatch(pdb.prefix, pdb.db.NewBatch())
 }
 
+// NewSnapshot implements Snapshotter. It returns ErrNotSupported if the underlying database is
+// not a Snapshotter.
+func (pdb *PrefixDB) NewSnapshot() (Snapshot, error) {
+	pdb.mtx.Lock()
+	defer pdb.mtx.Unlock()
+
+	snap, err := NewSnapshot(pdb.db)
+	if err != nil {
+		return nil, err
+	}
+	return newPrefixSnapshot(pdb.prefix, snap), nil
+}
+
+// Begin implements Transactional. It returns ErrNotSupported if the underlying database is not
+// Transactional.
+func (pdb *PrefixDB) Begin(writable bool) (Txn, error) {
+	pdb.mtx.Lock()
+	defer pdb.mtx.Unlock()
+
+	txn, err := Begin(pdb.db, writable)
+	if err != nil {
+		return nil, err
+	}
+	return newPrefixTxn(pdb.prefix, txn), nil
+}
+
+// KVType implements KVTyper, reporting the type of the underlying database.
+func (pdb *PrefixDB) KVType() KVType {
+	return TypeOf(pdb.db)
+}
+
+// Metrics implements MetricsReporter, reporting the metrics of the underlying database.
+func (pdb *PrefixDB) Metrics() Metrics {
+	return MetricsOf(pdb.db)
+}
+
 // Close implements DB.
 func (pdb *PrefixDB) Close() error {
 	pdb.mtx.Lock()
@@ -205,6 +332,17 @@ func (pdb *PrefixDB) prefixed(key []byte) []byte {
 	return append(cp(pdb.prefix), key...)
 }
 
+// prefixRange maps the domain [start, end) of a namespace onto the underlying database.
+func prefixRange(prefix, start, end []byte) (pstart, pend []byte) {
+	pstart = append(cp(prefix), start...)
+	if end == nil {
+		pend = cpIncr(prefix)
+	} else {
+		pend = append(cp(prefix), end...)
+	}
+	return pstart, pend
+}
+
 func cp(bz []byte) (ret []byte) {
 	ret = make([]byte, len(bz))
 	copy(ret, bz)
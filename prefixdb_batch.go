@@ -5,7 +5,11 @@ type prefixDBBatch struct {
 	source Batch
 }
 
-var _ Batch = (*prefixDBBatch)(nil)
+var (
+	_ Batch        = (*prefixDBBatch)(nil)
+	_ RangeDeleter = (*prefixDBBatch)(nil)
+	_ Merger       = (*prefixDBBatch)(nil)
+)
 
 func newPrefixBatch(prefix []byte, source Batch) prefixDBBatch {
 	return prefixDBBatch{
@@ -35,6 +39,33 @@ func (pb prefixDBBatch) Delete(key []byte) error {
 	return pb.source.Delete(pkey)
 }
 
+// Merge implements Merger. It returns ErrNotSupported if the underlying batch is not a Merger.
+func (pb prefixDBBatch) Merge(key, operand []byte) error {
+	if len(key) == 0 {
+		return ErrKeyEmpty
+	}
+	m, ok := pb.source.(Merger)
+	if !ok {
+		return ErrNotSupported
+	}
+	pkey := append(cp(pb.prefix), key...)
+	return m.Merge(pkey, operand)
+}
+
+// DeleteRange implements RangeDeleter. It returns ErrNotSupported if the underlying batch is not
+// a RangeDeleter.
+func (pb prefixDBBatch) DeleteRange(start, end []byte) error {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return ErrKeyEmpty
+	}
+	rd, ok := pb.source.(RangeDeleter)
+	if !ok {
+		return ErrNotSupported
+	}
+	pstart, pend := prefixRange(pb.prefix, start, end)
+	return rd.DeleteRange(pstart, pend)
+}
+
 // Write implements Batch.
 func (pb prefixDBBatch) Write() error {
 	return pb.source.Write()
@@ -33,7 +33,7 @@ type prefixDBIterator struct {
 	err    error
 }
 
-var _ Iterator = (*prefixDBIterator)(nil)
+var _ SeekIterator = (*prefixDBIterator)(nil)
 
 func newPrefixIterator(prefix, start, end []byte, source Iterator) (*prefixDBIterator, error) {
 	pitrInvalid := &prefixDBIterator{
@@ -99,7 +99,62 @@ func (itr *prefixDBIterator) Next() {
 	}
 }
 
-// Next implements Iterator.
+// Prev implements SeekIterator.
+func (itr *prefixDBIterator) Prev() {
+	itr.assertIsValid()
+	if source, ok := itr.seekSource(); ok {
+		source.Prev()
+		itr.settle(source.Prev)
+	}
+}
+
+// Seek implements SeekIterator. Like First and Last, it makes the iterator fail with
+// ErrNotSupported if the underlying iterator is not a SeekIterator.
+func (itr *prefixDBIterator) Seek(key []byte) {
+	if source, ok := itr.seekSource(); ok {
+		source.Seek(append(cp(itr.prefix), key...))
+		itr.settle(source.Next)
+	}
+}
+
+// First implements SeekIterator.
+func (itr *prefixDBIterator) First() {
+	if source, ok := itr.seekSource(); ok {
+		source.First()
+		itr.settle(source.Next)
+	}
+}
+
+// Last implements SeekIterator.
+func (itr *prefixDBIterator) Last() {
+	if source, ok := itr.seekSource(); ok {
+		source.Last()
+		itr.settle(source.Prev)
+	}
+}
+
+func (itr *prefixDBIterator) seekSource() (SeekIterator, bool) {
+	source, ok := itr.source.(SeekIterator)
+	if !ok {
+		itr.valid = false
+		if itr.err == nil {
+			itr.err = ErrNotSupported
+		}
+	}
+	return source, ok
+}
+
+// settle updates the validity of the iterator after the source moved. Empty keys are not allowed,
+// so a key that exactly matches the prefix is skipped with skip, which moves the source further in
+// the same direction.
+func (itr *prefixDBIterator) settle(skip func()) {
+	if itr.source.Valid() && bytes.Equal(itr.source.Key(), itr.prefix) {
+		skip()
+	}
+	itr.valid = itr.source.Valid() && bytes.HasPrefix(itr.source.Key(), itr.prefix)
+}
+
+// Key implements Iterator.
 func (itr *prefixDBIterator) Key() []byte {
 	itr.assertIsValid()
 	key := itr.source.Key()
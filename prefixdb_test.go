@@ -0,0 +1,56 @@
+package locketdb_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/meission/locketdb"
+	"github.com/meission/locketdb/locketdbtest"
+	"github.com/meission/locketdb/memdb"
+)
+
+func TestPrefixDBConformance(t *testing.T) {
+	locketdbtest.RunConformance(t, func() locketdb.DB {
+		db, err := memdb.NewDB("test", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Keys surrounding the namespace must never leak into it.
+		for _, k := range []string{"p", "o", "pa", "q"} {
+			if err := db.Set([]byte(k), []byte("outside")); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return locketdb.NewPrefixDB(db, []byte("p/"))
+	})
+}
+
+func TestPrefixDBDrop(t *testing.T) {
+	db, err := memdb.NewDB("test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	for _, k := range []string{"a/1", "a/2", "b/1", "a", "b"} {
+		if err := db.Set([]byte(k), []byte("v")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := locketdb.NewPrefixDB(db, []byte("a/")).Drop(); err != nil {
+		t.Fatal(err)
+	}
+
+	itr, err := db.Iterator(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer itr.Close()
+	var keys []string
+	for ; itr.Valid(); itr.Next() {
+		keys = append(keys, string(itr.Key()))
+	}
+	if fmt.Sprint(keys) != "[a b b/1]" {
+		t.Errorf("keys after Drop = %q, want [a b b/1]", keys)
+	}
+}
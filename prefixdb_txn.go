@@ -0,0 +1,86 @@
+package locketdb
+
+type prefixDBTxn struct {
+	prefix []byte
+	source Txn
+}
+
+var _ Txn = (*prefixDBTxn)(nil)
+
+func newPrefixTxn(prefix []byte, source Txn) *prefixDBTxn {
+	return &prefixDBTxn{
+		prefix: prefix,
+		source: source,
+	}
+}
+
+// Get implements Txn.
+func (pt *prefixDBTxn) Get(key []byte) ([]byte, error) {
+	if len(key) == 0 {
+		return nil, ErrKeyEmpty
+	}
+	return pt.source.Get(append(cp(pt.prefix), key...))
+}
+
+// Has implements Txn.
+func (pt *prefixDBTxn) Has(key []byte) (bool, error) {
+	if len(key) == 0 {
+		return false, ErrKeyEmpty
+	}
+	return pt.source.Has(append(cp(pt.prefix), key...))
+}
+
+// Set implements Txn.
+func (pt *prefixDBTxn) Set(key, value []byte) error {
+	if len(key) == 0 {
+		return ErrKeyEmpty
+	}
+	if value == nil {
+		return ErrValueNil
+	}
+	return pt.source.Set(append(cp(pt.prefix), key...), value)
+}
+
+// Delete implements Txn.
+func (pt *prefixDBTxn) Delete(key []byte) error {
+	if len(key) == 0 {
+		return ErrKeyEmpty
+	}
+	return pt.source.Delete(append(cp(pt.prefix), key...))
+}
+
+// Iterator implements Txn.
+func (pt *prefixDBTxn) Iterator(start, end []byte) (Iterator, error) {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return nil, ErrKeyEmpty
+	}
+	pstart, pend := prefixRange(pt.prefix, start, end)
+	itr, err := pt.source.Iterator(pstart, pend)
+	if err != nil {
+		return nil, err
+	}
+	return newPrefixIterator(pt.prefix, start, end, itr)
+}
+
+// ReverseIterator implements Txn.
+func (pt *prefixDBTxn) ReverseIterator(start, end []byte) (Iterator, error) {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return nil, ErrKeyEmpty
+	}
+	pstart, pend := prefixRange(pt.prefix, start, end)
+	ritr, err := pt.source.ReverseIterator(pstart, pend)
+	if err != nil {
+		return nil, err
+	}
+	return newPrefixIterator(pt.prefix, start, end, ritr)
+}
+
+// Commit implements Txn.
+func (pt *prefixDBTxn) Commit() error {
+	return pt.source.Commit()
+}
+
+// Rollback implements Txn.
+func (pt *prefixDBTxn) Rollback() error {
+	return pt.source.Rollback()
+}
@@ -0,0 +1,45 @@
+package locketdb
+
+// readOnlyBatch is the Batch of a database opened read-only.
+type readOnlyBatch struct{}
+
+var (
+	_ Batch        = readOnlyBatch{}
+	_ RangeDeleter = readOnlyBatch{}
+)
+
+// NewReadOnlyBatch returns a Batch whose write methods all return ErrReadOnly, for backends to
+// return from NewBatch when the database was opened read-only.
+func NewReadOnlyBatch() Batch {
+	return readOnlyBatch{}
+}
+
+// Set implements Batch.
+func (readOnlyBatch) Set(key, value []byte) error {
+	return ErrReadOnly
+}
+
+// Delete implements Batch.
+func (readOnlyBatch) Delete(key []byte) error {
+	return ErrReadOnly
+}
+
+// DeleteRange implements RangeDeleter.
+func (readOnlyBatch) DeleteRange(start, end []byte) error {
+	return ErrReadOnly
+}
+
+// Write implements Batch.
+func (readOnlyBatch) Write() error {
+	return ErrReadOnly
+}
+
+// WriteSync implements Batch.
+func (readOnlyBatch) WriteSync() error {
+	return ErrReadOnly
+}
+
+// Close implements Batch.
+func (readOnlyBatch) Close() error {
+	return nil
+}
@@ -0,0 +1,50 @@
+package locketdb
+
+// Snapshot is a read-only, point-in-time view of a database. Reads through a Snapshot never
+// observe writes made to the database after the Snapshot was taken, so several Gets and
+// iterators can be combined into one consistent read. Callers must call Close when done, and
+// must close every iterator obtained from the Snapshot before closing the Snapshot itself.
+//
+// As with DB, keys and values should be considered read-only, and must be copied before they are
+// modified.
+type Snapshot interface {
+	// Get fetches the value of the given key, or nil if it does not exist.
+	// CONTRACT: key, value readonly []byte
+	Get([]byte) ([]byte, error)
+
+	// Has checks if a key exists.
+	// CONTRACT: key, value readonly []byte
+	Has(key []byte) (bool, error)
+
+	// Iterator returns an iterator over a domain of keys, in ascending order. See DB.Iterator.
+	// CONTRACT: start, end readonly []byte
+	Iterator(start, end []byte) (Iterator, error)
+
+	// ReverseIterator returns an iterator over a domain of keys, in descending order. See
+	// DB.ReverseIterator.
+	// CONTRACT: start, end readonly []byte
+	ReverseIterator(start, end []byte) (Iterator, error)
+
+	// Close releases the snapshot.
+	Close() error
+}
+
+// Snapshotter is implemented by databases that can provide a Snapshot.
+//
+// An open snapshot may hold back writes to its database: on bbolt, a write that needs to grow the
+// database file blocks until every snapshot is closed. A goroutine holding a snapshot must
+// therefore not write to the same database, or it may deadlock.
+type Snapshotter interface {
+	// NewSnapshot takes a snapshot of the current state of the database.
+	NewSnapshot() (Snapshot, error)
+}
+
+// NewSnapshot takes a snapshot of db, or returns ErrNotSupported if the backend cannot provide
+// one.
+func NewSnapshot(db DB) (Snapshot, error) {
+	s, ok := db.(Snapshotter)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	return s.NewSnapshot()
+}
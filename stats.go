@@ -0,0 +1,27 @@
+package locketdb
+
+import "strings"
+
+// StatsKey returns the key of a statistic in the map returned by DB.Stats.
+//
+// Keys follow a stable scheme shared by all backends: the KVType of the backend, followed by the
+// lower case names of the groups of a metric and of the metric itself, joined with dots, as in
+// "pebble.wal.size_bytes". Words within a name are joined with underscores, sizes are in bytes
+// and end with "_bytes", and the levels of LSM trees are groups named "level.<n>". Values are
+// decimal numbers.
+//
+// The following metrics mean the same for every backend reporting them:
+//
+//	disk.size_bytes          size of the files of the database
+//	level.<n>.tables         number of tables in level n
+//	level.<n>.size_bytes     size of the tables in level n
+//	block_cache.size_bytes   size of the blocks held by the block cache
+//	block_cache.hits         number of block cache lookups that found the block
+//	block_cache.misses       number of block cache lookups that did not
+//
+// Keys are only ever added, never renamed, so that dashboards keep working across versions. The
+// keys goleveldb and bbolt reported before this scheme, such as "leveldb.stats" and "FreePageN",
+// are still reported alongside theirs.
+func StatsKey(kvType KVType, names ...string) string {
+	return string(kvType) + "." + strings.Join(names, ".")
+}
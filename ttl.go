@@ -0,0 +1,31 @@
+package locketdb
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrInvalidTTL is returned when setting a key with a time to live that is not positive.
+var ErrInvalidTTL = errors.New("ttl must be positive")
+
+// TTLSetter is implemented by databases that can set keys expiring after a time to live. Once
+// expired, a key is no longer seen by Get, Has and iterators, even if the backend deletes it from
+// storage only later.
+//
+// Badger supports it natively. Other backends can be wrapped with NewTTLDB.
+type TTLSetter interface {
+	// SetWithTTL sets the value for the given key, replacing it if it already exists, and expires
+	// it once ttl has elapsed. Setting the key again without a TTL makes it permanent.
+	// CONTRACT: key, value readonly []byte
+	SetWithTTL(key, value []byte, ttl time.Duration) error
+}
+
+// SetWithTTL sets key to value in db, expiring it after ttl, or returns ErrNotSupported if the
+// backend cannot expire keys.
+func SetWithTTL(db DB, key, value []byte, ttl time.Duration) error {
+	ts, ok := db.(TTLSetter)
+	if !ok {
+		return ErrNotSupported
+	}
+	return ts.SetWithTTL(key, value, ttl)
+}
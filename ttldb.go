@@ -0,0 +1,557 @@
+package locketdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// A TTLDB stores every key of the wrapped database in an envelope, prefixed by 'd', holding its
+// expiry time if it has one:
+//
+//	value:  0 | value                      (no expiry)
+//	value:  1 | expiry (8 bytes) | value
+//
+// Each key set with a TTL also gets an entry in the expiry index, prefixed by 'x', so that the
+// expired keys can be found without scanning the whole database:
+//
+//	index:  expiry (8 bytes) | key  ->  empty value
+//
+// where expiry is the big-endian Unix time in nanoseconds. Index entries are not removed when
+// their key is deleted or set again, the sweeper skips them once it finds the key has changed.
+const (
+	ttlNoExpiry byte = iota
+	ttlExpiry
+)
+
+var (
+	ttlDataPrefix  = []byte{'d'}
+	ttlIndexPrefix = []byte{'x'}
+)
+
+const (
+	// defaultTTLSweepInterval is the interval between sweeps when NewTTLDB is given none.
+	defaultTTLSweepInterval = time.Minute
+
+	// ttlSweepBatchKeys bounds the number of expired keys deleted by each batch of a sweep.
+	ttlSweepBatchKeys = 1000
+)
+
+// errTTLEnvelope is returned when a value of a TTLDB was not written by a TTLDB.
+var errTTLEnvelope = errors.New("malformed ttl envelope")
+
+// TTLDB wraps a database to implement TTLSetter on backends that lack native expiry. Keys are
+// stored in an envelope recording their expiry time, so expired keys are hidden as soon as they
+// expire, and deleted from the underlying database in batches by a background sweeper.
+//
+// A TTLDB owns the whole keyspace of the database it wraps, which must only be written through
+// it: the stored keys and values are not those of the TTLDB. To share a database, wrap a
+// PrefixDB.
+type TTLDB struct {
+	db    DB
+	data  *PrefixDB
+	index *PrefixDB
+
+	// mtx is held for reading by writes and for writing by the sweeper, so that it never deletes
+	// a key that was set again after it found the key expired.
+	mtx sync.RWMutex
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+var (
+	_ DB              = (*TTLDB)(nil)
+	_ TTLSetter       = (*TTLDB)(nil)
+	_ Snapshotter     = (*TTLDB)(nil)
+	_ RangeDeleter    = (*TTLDB)(nil)
+	_ Compacter       = (*TTLDB)(nil)
+	_ KVTyper         = (*TTLDB)(nil)
+	_ MetricsReporter = (*TTLDB)(nil)
+)
+
+// NewTTLDB wraps db to support SetWithTTL. Expired keys are deleted every sweepInterval, zero
+// meaning a default of one minute. A negative interval disables the background sweeper, leaving
+// it to the caller to call PurgeExpired.
+func NewTTLDB(db DB, sweepInterval time.Duration) *TTLDB {
+	t := &TTLDB{
+		db:    db,
+		data:  NewPrefixDB(db, ttlDataPrefix),
+		index: NewPrefixDB(db, ttlIndexPrefix),
+	}
+	if sweepInterval == 0 {
+		sweepInterval = defaultTTLSweepInterval
+	}
+	if sweepInterval > 0 {
+		t.stop = make(chan struct{})
+		t.done = make(chan struct{})
+		go t.sweep(sweepInterval)
+	}
+	return t
+}
+
+func (t *TTLDB) sweep(interval time.Duration) {
+	defer close(t.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.stop:
+			return
+		case <-ticker.C:
+			// A failed sweep is retried on the next tick.
+			_ = t.PurgeExpired()
+		}
+	}
+}
+
+// PurgeExpired deletes the keys expired so far from the underlying database, in batches of up to
+// a thousand keys.
+func (t *TTLDB) PurgeExpired() error {
+	now := ttlNow()
+	for {
+		n, err := t.purgeBatch(now)
+		if err != nil || n < ttlSweepBatchKeys {
+			return err
+		}
+	}
+}
+
+// purgeBatch deletes up to ttlSweepBatchKeys keys expired at now, returning the number of index
+// entries it went through.
+func (t *TTLDB) purgeBatch(now uint64) (int, error) {
+	itr, err := t.index.Iterator(nil, ttlIndexKey(now+1, nil))
+	if err != nil {
+		return 0, err
+	}
+	var entries [][]byte
+	for ; itr.Valid() && len(entries) < ttlSweepBatchKeys; itr.Next() {
+		entries = append(entries, cp(itr.Key()))
+	}
+	err = itr.Error()
+	itr.Close()
+	if err != nil || len(entries) == 0 {
+		return 0, err
+	}
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	batch := t.db.NewBatch()
+	defer batch.Close()
+	data, index := newPrefixBatch(ttlDataPrefix, batch), newPrefixBatch(ttlIndexPrefix, batch)
+	for _, entry := range entries {
+		expiry, key := binary.BigEndian.Uint64(entry), entry[8:]
+		raw, err := t.data.Get(key)
+		if err != nil {
+			return 0, err
+		}
+		if raw != nil {
+			_, keyExpiry, err := decodeTTLValue(raw)
+			if err != nil {
+				return 0, err
+			}
+			if keyExpiry == expiry {
+				if err := data.Delete(key); err != nil {
+					return 0, err
+				}
+			}
+		}
+		if err := index.Delete(entry); err != nil {
+			return 0, err
+		}
+	}
+	return len(entries), batch.Write()
+}
+
+// Get implements DB.
+func (t *TTLDB) Get(key []byte) ([]byte, error) {
+	return ttlGet(t.data.Get, key, ttlNow())
+}
+
+// Has implements DB.
+func (t *TTLDB) Has(key []byte) (bool, error) {
+	value, err := t.Get(key)
+	return value != nil, err
+}
+
+// Set implements DB.
+func (t *TTLDB) Set(key []byte, value []byte) error {
+	return t.set(t.data.Set, key, value)
+}
+
+// SetSync implements DB.
+func (t *TTLDB) SetSync(key []byte, value []byte) error {
+	return t.set(t.data.SetSync, key, value)
+}
+
+func (t *TTLDB) set(set func(key, value []byte) error, key, value []byte) error {
+	if len(key) == 0 {
+		return ErrKeyEmpty
+	}
+	if value == nil {
+		return ErrValueNil
+	}
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+
+	return set(key, encodeTTLValue(value, 0))
+}
+
+// SetWithTTL implements TTLSetter. The key and its index entry are written atomically.
+func (t *TTLDB) SetWithTTL(key, value []byte, ttl time.Duration) error {
+	if len(key) == 0 {
+		return ErrKeyEmpty
+	}
+	if value == nil {
+		return ErrValueNil
+	}
+	if ttl <= 0 {
+		return ErrInvalidTTL
+	}
+	expiry := ttlNow() + uint64(ttl)
+
+	batch := t.db.NewBatch()
+	defer batch.Close()
+	if err := newPrefixBatch(ttlDataPrefix, batch).Set(key, encodeTTLValue(value, expiry)); err != nil {
+		return err
+	}
+	if err := newPrefixBatch(ttlIndexPrefix, batch).Set(ttlIndexKey(expiry, key), []byte{}); err != nil {
+		return err
+	}
+
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+	return batch.Write()
+}
+
+// Delete implements DB.
+func (t *TTLDB) Delete(key []byte) error {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+
+	return t.data.Delete(key)
+}
+
+// DeleteSync implements DB.
+func (t *TTLDB) DeleteSync(key []byte) error {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+
+	return t.data.DeleteSync(key)
+}
+
+// DeleteRange implements RangeDeleter. It returns ErrNotSupported if the underlying database is
+// not a RangeDeleter.
+func (t *TTLDB) DeleteRange(start, end []byte) error {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+
+	return t.data.DeleteRange(start, end)
+}
+
+// Compact implements Compacter. It returns ErrNotSupported if the underlying database is not a
+// Compacter.
+func (t *TTLDB) Compact(start, end []byte) error {
+	return t.data.Compact(start, end)
+}
+
+// Iterator implements DB. Keys expiring while the iterator is open are still returned.
+func (t *TTLDB) Iterator(start, end []byte) (Iterator, error) {
+	itr, err := t.data.Iterator(start, end)
+	if err != nil {
+		return nil, err
+	}
+	return newTTLIterator(itr, ttlNow()), nil
+}
+
+// ReverseIterator implements DB. Keys expiring while the iterator is open are still returned.
+func (t *TTLDB) ReverseIterator(start, end []byte) (Iterator, error) {
+	itr, err := t.data.ReverseIterator(start, end)
+	if err != nil {
+		return nil, err
+	}
+	return newTTLIterator(itr, ttlNow()), nil
+}
+
+// NewBatch implements DB.
+func (t *TTLDB) NewBatch() Batch {
+	return &ttlBatch{t: t, source: t.db.NewBatch()}
+}
+
+// NewSnapshot implements Snapshotter. It returns ErrNotSupported if the underlying database is
+// not a Snapshotter. Keys are hidden once they expire, even if they had not when the snapshot
+// was taken.
+func (t *TTLDB) NewSnapshot() (Snapshot, error) {
+	snap, err := t.data.NewSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	return &ttlSnapshot{source: snap}, nil
+}
+
+// KVType implements KVTyper, reporting the type of the underlying database.
+func (t *TTLDB) KVType() KVType {
+	return TypeOf(t.db)
+}
+
+// Metrics implements MetricsReporter, reporting the metrics of the underlying database.
+func (t *TTLDB) Metrics() Metrics {
+	return MetricsOf(t.db)
+}
+
+// Close implements DB. It stops the sweeper before closing the underlying database.
+func (t *TTLDB) Close() error {
+	if t.stop != nil {
+		close(t.stop)
+		<-t.done
+	}
+	return t.db.Close()
+}
+
+// Print implements DB.
+func (t *TTLDB) Print() error {
+	itr, err := t.Iterator(nil, nil)
+	if err != nil {
+		return err
+	}
+	defer itr.Close()
+	for ; itr.Valid(); itr.Next() {
+		fmt.Printf("[%X]:\t[%X]\n", itr.Key(), itr.Value())
+	}
+	return itr.Error()
+}
+
+// Stats implements DB.
+func (t *TTLDB) Stats() map[string]string {
+	return t.db.Stats()
+}
+
+func ttlNow() uint64 {
+	return uint64(time.Now().UnixNano())
+}
+
+// ttlGet reads key with get, hiding it if it expired at now.
+func ttlGet(get func(key []byte) ([]byte, error), key []byte, now uint64) ([]byte, error) {
+	raw, err := get(key)
+	if err != nil || raw == nil {
+		return nil, err
+	}
+	value, expiry, err := decodeTTLValue(raw)
+	if err != nil {
+		return nil, err
+	}
+	if ttlExpired(expiry, now) {
+		return nil, nil
+	}
+	return value, nil
+}
+
+// encodeTTLValue wraps value in an envelope expiring at expiry, or never if it is zero.
+func encodeTTLValue(value []byte, expiry uint64) []byte {
+	if expiry == 0 {
+		return append([]byte{ttlNoExpiry}, value...)
+	}
+	raw := make([]byte, 9, 9+len(value))
+	raw[0] = ttlExpiry
+	binary.BigEndian.PutUint64(raw[1:], expiry)
+	return append(raw, value...)
+}
+
+// decodeTTLValue unwraps an envelope, returning a zero expiry if the value does not expire.
+func decodeTTLValue(raw []byte) (value []byte, expiry uint64, err error) {
+	switch {
+	case len(raw) >= 1 && raw[0] == ttlNoExpiry:
+		return raw[1:], 0, nil
+	case len(raw) >= 9 && raw[0] == ttlExpiry:
+		return raw[9:], binary.BigEndian.Uint64(raw[1:9]), nil
+	default:
+		return nil, 0, errTTLEnvelope
+	}
+}
+
+func ttlExpired(expiry, now uint64) bool {
+	return expiry != 0 && expiry <= now
+}
+
+func ttlIndexKey(expiry uint64, key []byte) []byte {
+	ikey := make([]byte, 8, 8+len(key))
+	binary.BigEndian.PutUint64(ikey, expiry)
+	return append(ikey, key...)
+}
+
+// ttlBatch wraps the values of a batch in envelopes without expiry.
+type ttlBatch struct {
+	t      *TTLDB
+	source Batch
+}
+
+var (
+	_ Batch        = (*ttlBatch)(nil)
+	_ RangeDeleter = (*ttlBatch)(nil)
+)
+
+// Set implements Batch.
+func (b *ttlBatch) Set(key, value []byte) error {
+	if len(key) == 0 {
+		return ErrKeyEmpty
+	}
+	if value == nil {
+		return ErrValueNil
+	}
+	return newPrefixBatch(ttlDataPrefix, b.source).Set(key, encodeTTLValue(value, 0))
+}
+
+// Delete implements Batch.
+func (b *ttlBatch) Delete(key []byte) error {
+	return newPrefixBatch(ttlDataPrefix, b.source).Delete(key)
+}
+
+// DeleteRange implements RangeDeleter. It returns ErrNotSupported if the underlying batch is not
+// a RangeDeleter.
+func (b *ttlBatch) DeleteRange(start, end []byte) error {
+	return newPrefixBatch(ttlDataPrefix, b.source).DeleteRange(start, end)
+}
+
+// Write implements Batch.
+func (b *ttlBatch) Write() error {
+	b.t.mtx.RLock()
+	defer b.t.mtx.RUnlock()
+
+	return b.source.Write()
+}
+
+// WriteSync implements Batch.
+func (b *ttlBatch) WriteSync() error {
+	b.t.mtx.RLock()
+	defer b.t.mtx.RUnlock()
+
+	return b.source.WriteSync()
+}
+
+// Close implements Batch.
+func (b *ttlBatch) Close() error {
+	return b.source.Close()
+}
+
+// ttlSnapshot hides the expired keys of a snapshot.
+type ttlSnapshot struct {
+	source Snapshot
+}
+
+var _ Snapshot = (*ttlSnapshot)(nil)
+
+// Get implements Snapshot.
+func (s *ttlSnapshot) Get(key []byte) ([]byte, error) {
+	return ttlGet(s.source.Get, key, ttlNow())
+}
+
+// Has implements Snapshot.
+func (s *ttlSnapshot) Has(key []byte) (bool, error) {
+	value, err := s.Get(key)
+	return value != nil, err
+}
+
+// Iterator implements Snapshot.
+func (s *ttlSnapshot) Iterator(start, end []byte) (Iterator, error) {
+	itr, err := s.source.Iterator(start, end)
+	if err != nil {
+		return nil, err
+	}
+	return newTTLIterator(itr, ttlNow()), nil
+}
+
+// ReverseIterator implements Snapshot.
+func (s *ttlSnapshot) ReverseIterator(start, end []byte) (Iterator, error) {
+	itr, err := s.source.ReverseIterator(start, end)
+	if err != nil {
+		return nil, err
+	}
+	return newTTLIterator(itr, ttlNow()), nil
+}
+
+// Close implements Snapshot.
+func (s *ttlSnapshot) Close() error {
+	return s.source.Close()
+}
+
+// ttlIterator unwraps the values of an iterator, skipping the keys expired at now.
+type ttlIterator struct {
+	source Iterator
+	now    uint64
+	value  []byte
+	err    error
+}
+
+var _ Iterator = (*ttlIterator)(nil)
+
+func newTTLIterator(source Iterator, now uint64) *ttlIterator {
+	itr := &ttlIterator{source: source, now: now}
+	itr.skipExpired()
+	return itr
+}
+
+// skipExpired moves the source to the next key that has not expired, and unwraps its value.
+func (itr *ttlIterator) skipExpired() {
+	for ; itr.source.Valid(); itr.source.Next() {
+		value, expiry, err := decodeTTLValue(itr.source.Value())
+		if err != nil {
+			itr.err = err
+			return
+		}
+		if !ttlExpired(expiry, itr.now) {
+			itr.value = value
+			return
+		}
+	}
+}
+
+// Domain implements Iterator.
+func (itr *ttlIterator) Domain() (start []byte, end []byte) {
+	return itr.source.Domain()
+}
+
+// Valid implements Iterator.
+func (itr *ttlIterator) Valid() bool {
+	return itr.err == nil && itr.source.Valid()
+}
+
+// Next implements Iterator.
+func (itr *ttlIterator) Next() {
+	itr.assertIsValid()
+	itr.source.Next()
+	itr.skipExpired()
+}
+
+// Key implements Iterator.
+func (itr *ttlIterator) Key() []byte {
+	itr.assertIsValid()
+	return itr.source.Key()
+}
+
+// Value implements Iterator.
+func (itr *ttlIterator) Value() []byte {
+	itr.assertIsValid()
+	return itr.value
+}
+
+// Error implements Iterator.
+func (itr *ttlIterator) Error() error {
+	if itr.err != nil {
+		return itr.err
+	}
+	return itr.source.Error()
+}
+
+// Close implements Iterator.
+func (itr *ttlIterator) Close() error {
+	return itr.source.Close()
+}
+
+func (itr *ttlIterator) assertIsValid() {
+	if !itr.Valid() {
+		panic("iterator is invalid")
+	}
+}
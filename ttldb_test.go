@@ -0,0 +1,115 @@
+package locketdb_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/meission/locketdb"
+	"github.com/meission/locketdb/locketdbtest"
+	"github.com/meission/locketdb/memdb"
+)
+
+func TestTTLDBConformance(t *testing.T) {
+	locketdbtest.RunConformance(t, func() locketdb.DB {
+		db, err := memdb.NewDB("test", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return locketdb.NewTTLDB(db, -1)
+	})
+}
+
+// countKeys returns the number of keys stored in db, expired or not.
+func countKeys(t *testing.T, db locketdb.DB) int {
+	t.Helper()
+	itr, err := db.Iterator(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer itr.Close()
+	n := 0
+	for ; itr.Valid(); itr.Next() {
+		n++
+	}
+	return n
+}
+
+func TestTTLDBPurgeExpired(t *testing.T) {
+	db, err := memdb.NewDB("test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tdb := locketdb.NewTTLDB(db, -1)
+	defer tdb.Close()
+
+	mustNoErr := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	mustNoErr(tdb.SetWithTTL([]byte("expired"), []byte("v"), time.Millisecond))
+	mustNoErr(tdb.SetWithTTL([]byte("reset"), []byte("v"), time.Millisecond))
+	mustNoErr(tdb.Set([]byte("reset"), []byte("permanent")))
+	mustNoErr(tdb.SetWithTTL([]byte("live"), []byte("v"), time.Hour))
+	time.Sleep(10 * time.Millisecond)
+
+	// Each key set with a TTL has an entry in the expiry index.
+	if n := countKeys(t, db); n != 6 {
+		t.Errorf("expected 6 stored keys before purging, got %d", n)
+	}
+	mustNoErr(tdb.PurgeExpired())
+	if n := countKeys(t, db); n != 3 {
+		t.Errorf("expected 3 stored keys after purging, got %d", n)
+	}
+	value, err := tdb.Get([]byte("reset"))
+	mustNoErr(err)
+	if string(value) != "permanent" {
+		t.Errorf("expected the key set again to survive, got %q", value)
+	}
+	if n := countKeys(t, tdb); n != 2 {
+		t.Errorf("expected 2 keys, got %d", n)
+	}
+}
+
+func TestTTLDBSweeper(t *testing.T) {
+	db, err := memdb.NewDB("test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tdb := locketdb.NewTTLDB(db, 5*time.Millisecond)
+	defer tdb.Close()
+
+	for _, key := range []string{"a", "b", "c"} {
+		if err := tdb.SetWithTTL([]byte(key), []byte("v"), time.Millisecond); err != nil {
+			t.Fatal(err)
+		}
+	}
+	deadline := time.Now().Add(time.Second)
+	for countKeys(t, db) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expired keys were not swept, %d keys left", countKeys(t, db))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestTTLDBPrefixDB(t *testing.T) {
+	db, err := memdb.NewDB("test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := locketdb.SetWithTTL(locketdb.NewPrefixDB(db, []byte("p")), []byte("k"), []byte("v"), time.Hour); err != locketdb.ErrNotSupported {
+		t.Errorf("expected ErrNotSupported without TTL support, got %v", err)
+	}
+
+	pdb := locketdb.NewPrefixDB(locketdb.NewTTLDB(db, -1), []byte("p"))
+	if err := locketdb.SetWithTTL(pdb, []byte("k"), []byte("v"), time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if ok, err := pdb.Has([]byte("k")); err != nil || ok {
+		t.Errorf("expected the key to expire, got %v, %v", ok, err)
+	}
+}
@@ -0,0 +1,62 @@
+package locketdb
+
+// Txn is a read-write transaction. Reads observe the state of the database when the transaction
+// began, plus the transaction's own writes. Writes are buffered and applied atomically by Commit,
+// which fails with ErrConflict if data read by the transaction was modified by someone else in
+// the meantime. Callers must call either Commit or Rollback when done.
+//
+// A Txn is not safe for concurrent use. Iterators obtained from a Txn must be closed before the
+// transaction is committed or rolled back, and do not observe writes made after their creation.
+//
+// As with DB, keys and values should be considered read-only, both when returned and when given,
+// and must be copied before they are modified.
+type Txn interface {
+	// Get fetches the value of the given key, or nil if it does not exist.
+	// CONTRACT: key, value readonly []byte
+	Get([]byte) ([]byte, error)
+
+	// Has checks if a key exists.
+	// CONTRACT: key, value readonly []byte
+	Has(key []byte) (bool, error)
+
+	// Set sets the value for the given key. Returns ErrTxnReadOnly on read-only transactions.
+	// CONTRACT: key, value readonly []byte
+	Set([]byte, []byte) error
+
+	// Delete deletes the key. Returns ErrTxnReadOnly on read-only transactions.
+	// CONTRACT: key readonly []byte
+	Delete([]byte) error
+
+	// Iterator returns an iterator over a domain of keys, in ascending order. See DB.Iterator.
+	// CONTRACT: start, end readonly []byte
+	Iterator(start, end []byte) (Iterator, error)
+
+	// ReverseIterator returns an iterator over a domain of keys, in descending order. See
+	// DB.ReverseIterator.
+	// CONTRACT: start, end readonly []byte
+	ReverseIterator(start, end []byte) (Iterator, error)
+
+	// Commit applies the writes of the transaction. It returns ErrConflict if the transaction
+	// conflicted with another write, in which case nothing has been written. Only Rollback can be
+	// called afterwards, other methods will return ErrTxnClosed.
+	Commit() error
+
+	// Rollback discards the transaction. It is idempotent, and may be called after Commit.
+	Rollback() error
+}
+
+// Transactional is implemented by databases that support transactions.
+type Transactional interface {
+	// Begin starts a new transaction. Read-only transactions reject writes with ErrTxnReadOnly.
+	Begin(writable bool) (Txn, error)
+}
+
+// Begin starts a transaction on db, or returns ErrNotSupported if the backend does not support
+// transactions.
+func Begin(db DB, writable bool) (Txn, error) {
+	t, ok := db.(Transactional)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	return t.Begin(writable)
+}
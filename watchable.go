@@ -0,0 +1,311 @@
+package locketdb
+
+import (
+	"bytes"
+	"context"
+	"sync"
+)
+
+// EventType is the type of an Event.
+type EventType int
+
+const (
+	// EventSet reports that a key was set.
+	EventSet EventType = iota + 1
+	// EventDelete reports that a key was deleted. It is also sent for keys that did not exist.
+	EventDelete
+	// EventOverflow reports that the subscriber did not keep up and missed events. It is the
+	// last event of the subscription, whose channel is closed right after.
+	EventOverflow
+)
+
+// String implements fmt.Stringer.
+func (t EventType) String() string {
+	switch t {
+	case EventSet:
+		return "set"
+	case EventDelete:
+		return "delete"
+	case EventOverflow:
+		return "overflow"
+	default:
+		return "unknown"
+	}
+}
+
+// Event is a change made through a Watchable.
+type Event struct {
+	Type EventType
+	Key  []byte
+	// Value is the new value of the key for EventSet, and nil otherwise.
+	Value []byte
+}
+
+// defaultWatchBufferSize is the number of events buffered per subscriber when NewWatchable is
+// given no size.
+const defaultWatchBufferSize = 256
+
+// Watchable wraps a database to publish the changes made through it. Every Set and Delete, as well
+// as every operation of a written Batch, is sent as an Event to the subscribers watching a prefix
+// of its key, in commit order.
+//
+// To guarantee that order, writes through a Watchable are serialized. Writes made to the
+// underlying database directly, or through transactions, are not seen.
+type Watchable struct {
+	db         DB
+	bufferSize int
+
+	// writeMtx serializes the writes and the publication of their events.
+	writeMtx sync.Mutex
+
+	// mtx guards subs and closed.
+	mtx    sync.Mutex
+	subs   map[*watcher]struct{}
+	closed bool
+}
+
+var (
+	_ DB              = (*Watchable)(nil)
+	_ Snapshotter     = (*Watchable)(nil)
+	_ RangeDeleter    = (*Watchable)(nil)
+	_ Compacter       = (*Watchable)(nil)
+	_ KVTyper         = (*Watchable)(nil)
+	_ MetricsReporter = (*Watchable)(nil)
+)
+
+// watcher is a subscription to the events of a prefix.
+type watcher struct {
+	prefix []byte
+	// ch has room for one more event than the buffer size, to always fit EventOverflow.
+	ch   chan Event
+	done chan struct{}
+}
+
+// NewWatchable wraps db to publish its changes. Each subscriber buffers up to bufferSize events,
+// zero meaning a default of 256.
+func NewWatchable(db DB, bufferSize int) *Watchable {
+	if bufferSize <= 0 {
+		bufferSize = defaultWatchBufferSize
+	}
+	return &Watchable{
+		db:         db,
+		bufferSize: bufferSize,
+		subs:       make(map[*watcher]struct{}),
+	}
+}
+
+// Watch subscribes to the changes of the keys starting with prefix, all keys if it is empty. The
+// channel is closed when ctx is done, when the database is closed, or right after an
+// EventOverflow: writers never block on subscribers, so one that lets its buffer fill up is sent
+// EventOverflow and unsubscribed, and must watch again and re-read what it needs.
+func (w *Watchable) Watch(ctx context.Context, prefix []byte) <-chan Event {
+	sub := &watcher{
+		prefix: cp(prefix),
+		ch:     make(chan Event, w.bufferSize+1),
+		done:   make(chan struct{}),
+	}
+
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	if w.closed {
+		close(sub.ch)
+		return sub.ch
+	}
+	w.subs[sub] = struct{}{}
+	go func() {
+		select {
+		case <-ctx.Done():
+			w.mtx.Lock()
+			w.unsubscribe(sub)
+			w.mtx.Unlock()
+		case <-sub.done:
+		}
+	}()
+	return sub.ch
+}
+
+// unsubscribe closes the channel of sub if it is still subscribed. The caller must hold mtx.
+func (w *Watchable) unsubscribe(sub *watcher) {
+	if _, ok := w.subs[sub]; !ok {
+		return
+	}
+	delete(w.subs, sub)
+	close(sub.ch)
+	close(sub.done)
+}
+
+// publish sends events to their subscribers. The caller must hold writeMtx.
+func (w *Watchable) publish(events ...Event) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	for _, e := range events {
+		for sub := range w.subs {
+			if !bytes.HasPrefix(e.Key, sub.prefix) {
+				continue
+			}
+			// Only publish sends on ch, under mtx, so the length cannot grow meanwhile.
+			if len(sub.ch) >= w.bufferSize {
+				sub.ch <- Event{Type: EventOverflow}
+				w.unsubscribe(sub)
+				continue
+			}
+			sub.ch <- e
+		}
+	}
+}
+
+// Get implements DB.
+func (w *Watchable) Get(key []byte) ([]byte, error) {
+	return w.db.Get(key)
+}
+
+// Has implements DB.
+func (w *Watchable) Has(key []byte) (bool, error) {
+	return w.db.Has(key)
+}
+
+// Set implements DB.
+func (w *Watchable) Set(key []byte, value []byte) error {
+	return w.write(w.db.Set, key, value)
+}
+
+// SetSync implements DB.
+func (w *Watchable) SetSync(key []byte, value []byte) error {
+	return w.write(w.db.SetSync, key, value)
+}
+
+func (w *Watchable) write(set func(key, value []byte) error, key, value []byte) error {
+	w.writeMtx.Lock()
+	defer w.writeMtx.Unlock()
+
+	if err := set(key, value); err != nil {
+		return err
+	}
+	w.publish(Event{Type: EventSet, Key: cp(key), Value: cp(value)})
+	return nil
+}
+
+// Delete implements DB.
+func (w *Watchable) Delete(key []byte) error {
+	return w.delete(w.db.Delete, key)
+}
+
+// DeleteSync implements DB.
+func (w *Watchable) DeleteSync(key []byte) error {
+	return w.delete(w.db.DeleteSync, key)
+}
+
+func (w *Watchable) delete(del func(key []byte) error, key []byte) error {
+	w.writeMtx.Lock()
+	defer w.writeMtx.Unlock()
+
+	if err := del(key); err != nil {
+		return err
+	}
+	w.publish(Event{Type: EventDelete, Key: cp(key)})
+	return nil
+}
+
+// DeleteRange implements RangeDeleter, sending an EventDelete for every key the range held. It
+// returns ErrNotSupported if the underlying database is not a RangeDeleter.
+func (w *Watchable) DeleteRange(start, end []byte) error {
+	if (start != nil && len(start) == 0) || (end != nil && len(end) == 0) {
+		return ErrKeyEmpty
+	}
+	if _, ok := w.db.(RangeDeleter); !ok {
+		return ErrNotSupported
+	}
+	w.writeMtx.Lock()
+	defer w.writeMtx.Unlock()
+
+	keys, err := w.rangeKeys(start, end)
+	if err != nil {
+		return err
+	}
+	if err := DeleteRange(w.db, start, end); err != nil {
+		return err
+	}
+	events := make([]Event, len(keys))
+	for i, key := range keys {
+		events[i] = Event{Type: EventDelete, Key: key}
+	}
+	w.publish(events...)
+	return nil
+}
+
+// rangeKeys returns the keys within [start, end). The caller must hold writeMtx, so that they do
+// not change before being deleted.
+func (w *Watchable) rangeKeys(start, end []byte) ([][]byte, error) {
+	if IsEmptyRange(start, end) {
+		return nil, nil
+	}
+	itr, err := w.db.Iterator(start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer itr.Close()
+	var keys [][]byte
+	for ; itr.Valid(); itr.Next() {
+		keys = append(keys, cp(itr.Key()))
+	}
+	return keys, itr.Error()
+}
+
+// Compact implements Compacter. It returns ErrNotSupported if the underlying database is not a
+// Compacter.
+func (w *Watchable) Compact(start, end []byte) error {
+	return Compact(w.db, start, end)
+}
+
+// Iterator implements DB.
+func (w *Watchable) Iterator(start, end []byte) (Iterator, error) {
+	return w.db.Iterator(start, end)
+}
+
+// ReverseIterator implements DB.
+func (w *Watchable) ReverseIterator(start, end []byte) (Iterator, error) {
+	return w.db.ReverseIterator(start, end)
+}
+
+// NewBatch implements DB.
+func (w *Watchable) NewBatch() Batch {
+	return newWatchableBatch(w)
+}
+
+// NewSnapshot implements Snapshotter. It returns ErrNotSupported if the underlying database is
+// not a Snapshotter.
+func (w *Watchable) NewSnapshot() (Snapshot, error) {
+	return NewSnapshot(w.db)
+}
+
+// KVType implements KVTyper, reporting the type of the underlying database.
+func (w *Watchable) KVType() KVType {
+	return TypeOf(w.db)
+}
+
+// Metrics implements MetricsReporter, reporting the metrics of the underlying database.
+func (w *Watchable) Metrics() Metrics {
+	return MetricsOf(w.db)
+}
+
+// Close implements DB. It also closes the channels of all subscribers.
+func (w *Watchable) Close() error {
+	w.mtx.Lock()
+	w.closed = true
+	for sub := range w.subs {
+		w.unsubscribe(sub)
+	}
+	w.mtx.Unlock()
+
+	return w.db.Close()
+}
+
+// Print implements DB.
+func (w *Watchable) Print() error {
+	return w.db.Print()
+}
+
+// Stats implements DB.
+func (w *Watchable) Stats() map[string]string {
+	return w.db.Stats()
+}
@@ -0,0 +1,131 @@
+package locketdb
+
+type watchableBatch struct {
+	w      *Watchable
+	source Batch
+	ops    []watchableOp
+}
+
+var (
+	_ Batch        = (*watchableBatch)(nil)
+	_ RangeDeleter = (*watchableBatch)(nil)
+)
+
+// watchableOp is an operation of a batch, turned into events once the batch is written. A range
+// deletion has no key, but the bounds of its domain.
+type watchableOp struct {
+	event      Event
+	start, end []byte
+	isRange    bool
+}
+
+func newWatchableBatch(w *Watchable) *watchableBatch {
+	return &watchableBatch{
+		w:      w,
+		source: w.db.NewBatch(),
+	}
+}
+
+// Set implements Batch.
+func (b *watchableBatch) Set(key, value []byte) error {
+	if err := b.source.Set(key, value); err != nil {
+		return err
+	}
+	b.ops = append(b.ops, watchableOp{event: Event{Type: EventSet, Key: cp(key), Value: cp(value)}})
+	return nil
+}
+
+// Delete implements Batch.
+func (b *watchableBatch) Delete(key []byte) error {
+	if err := b.source.Delete(key); err != nil {
+		return err
+	}
+	b.ops = append(b.ops, watchableOp{event: Event{Type: EventDelete, Key: cp(key)}})
+	return nil
+}
+
+// DeleteRange implements RangeDeleter. It returns ErrNotSupported if the underlying batch is not
+// a RangeDeleter.
+func (b *watchableBatch) DeleteRange(start, end []byte) error {
+	rd, ok := b.source.(RangeDeleter)
+	if !ok {
+		return ErrNotSupported
+	}
+	if err := rd.DeleteRange(start, end); err != nil {
+		return err
+	}
+	op := watchableOp{isRange: true}
+	if start != nil {
+		op.start = cp(start)
+	}
+	if end != nil {
+		op.end = cp(end)
+	}
+	b.ops = append(b.ops, op)
+	return nil
+}
+
+// Write implements Batch.
+func (b *watchableBatch) Write() error {
+	return b.write(b.source.Write)
+}
+
+// WriteSync implements Batch.
+func (b *watchableBatch) WriteSync() error {
+	return b.write(b.source.WriteSync)
+}
+
+func (b *watchableBatch) write(write func() error) error {
+	b.w.writeMtx.Lock()
+	defer b.w.writeMtx.Unlock()
+
+	events, err := b.events()
+	if err != nil {
+		return err
+	}
+	if err := write(); err != nil {
+		return err
+	}
+	b.ops = nil
+	b.w.publish(events...)
+	return nil
+}
+
+// events turns the operations of the batch into events. A range deletion becomes an EventDelete
+// for every key of the range, either in the database or set earlier in the batch. The caller
+// must hold writeMtx, so that the database does not change before the batch is written.
+func (b *watchableBatch) events() ([]Event, error) {
+	var events []Event
+	for i, op := range b.ops {
+		if !op.isRange {
+			events = append(events, op.event)
+			continue
+		}
+		start, end := op.start, op.end
+		keys, err := b.w.rangeKeys(start, end)
+		if err != nil {
+			return nil, err
+		}
+		seen := make(map[string]bool, len(keys))
+		for _, key := range keys {
+			seen[string(key)] = true
+		}
+		for _, prev := range b.ops[:i] {
+			if !prev.isRange && prev.event.Type == EventSet && !seen[string(prev.event.Key)] &&
+				IsKeyInDomain(prev.event.Key, start, end) {
+				seen[string(prev.event.Key)] = true
+				keys = append(keys, prev.event.Key)
+			}
+		}
+		for _, key := range keys {
+			events = append(events, Event{Type: EventDelete, Key: key})
+		}
+	}
+	return events, nil
+}
+
+// Close implements Batch.
+func (b *watchableBatch) Close() error {
+	b.ops = nil
+	return b.source.Close()
+}
@@ -0,0 +1,136 @@
+package locketdb_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/meission/locketdb"
+	"github.com/meission/locketdb/locketdbtest"
+	"github.com/meission/locketdb/memdb"
+)
+
+func newWatchable(t *testing.T, bufferSize int) *locketdb.Watchable {
+	db, err := memdb.NewDB("test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return locketdb.NewWatchable(db, bufferSize)
+}
+
+func TestWatchableConformance(t *testing.T) {
+	locketdbtest.RunConformance(t, func() locketdb.DB {
+		return newWatchable(t, 0)
+	})
+}
+
+// drain returns the events buffered in ch, failing if it holds fewer than n.
+func drain(t *testing.T, ch <-chan locketdb.Event, n int) []locketdb.Event {
+	t.Helper()
+	var events []locketdb.Event
+	for len(events) < n {
+		select {
+		case e, ok := <-ch:
+			if !ok {
+				t.Fatalf("channel closed after %d events, expected %d", len(events), n)
+			}
+			events = append(events, e)
+		case <-time.After(time.Second):
+			t.Fatalf("got %d events, expected %d", len(events), n)
+		}
+	}
+	return events
+}
+
+func set(key, value string) locketdb.Event {
+	return locketdb.Event{Type: locketdb.EventSet, Key: []byte(key), Value: []byte(value)}
+}
+
+func del(key string) locketdb.Event {
+	return locketdb.Event{Type: locketdb.EventDelete, Key: []byte(key)}
+}
+
+func TestWatch(t *testing.T) {
+	w := newWatchable(t, 0)
+	defer w.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	all := w.Watch(ctx, nil)
+	users := w.Watch(ctx, []byte("user/"))
+
+	mustNoErr := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	mustNoErr(w.Set([]byte("user/1"), []byte("a")))
+	mustNoErr(w.Set([]byte("group/1"), []byte("b")))
+	mustNoErr(w.Delete([]byte("user/1")))
+
+	batch := w.NewBatch()
+	mustNoErr(batch.Set([]byte("user/2"), []byte("c")))
+	mustNoErr(batch.Set([]byte("user/3"), []byte("d")))
+	mustNoErr(batch.Delete([]byte("group/1")))
+	mustNoErr(batch.(locketdb.RangeDeleter).DeleteRange([]byte("user/3"), nil))
+	mustNoErr(batch.Write())
+	mustNoErr(batch.Close())
+
+	mustNoErr(w.Set([]byte("user/4"), []byte("e")))
+	mustNoErr(w.DeleteRange([]byte("user/"), []byte("user0")))
+
+	want := []locketdb.Event{
+		set("user/1", "a"), set("group/1", "b"), del("user/1"),
+		set("user/2", "c"), set("user/3", "d"), del("group/1"), del("user/3"),
+		set("user/4", "e"), del("user/2"), del("user/4"),
+	}
+	if got := drain(t, all, len(want)); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	var wantUsers []locketdb.Event
+	for _, e := range want {
+		if string(e.Key[:5]) == "user/" {
+			wantUsers = append(wantUsers, e)
+		}
+	}
+	if got := drain(t, users, len(wantUsers)); !reflect.DeepEqual(got, wantUsers) {
+		t.Errorf("expected %v, got %v", wantUsers, got)
+	}
+
+	cancel()
+	select {
+	case _, ok := <-all:
+		if ok {
+			t.Error("expected no more events")
+		}
+	case <-time.After(time.Second):
+		t.Error("expected the channel to be closed once ctx is done")
+	}
+}
+
+func TestWatchOverflow(t *testing.T) {
+	w := newWatchable(t, 2)
+	ch := w.Watch(context.Background(), nil)
+	other := w.Watch(context.Background(), []byte("other"))
+
+	for _, k := range []string{"a", "b", "c", "d"} {
+		if err := w.Set([]byte(k), []byte("v")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []locketdb.Event{set("a", "v"), set("b", "v"), {Type: locketdb.EventOverflow}}
+	if got := drain(t, ch, len(want)); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	if _, ok := <-ch; ok {
+		t.Error("expected the channel to be closed after an overflow")
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := <-other; ok {
+		t.Error("expected Close to close the channels")
+	}
+}